@@ -0,0 +1,796 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/******************************************************************************
+
+File is structured as so:
+
+	Annotation checks - quality control checks that flag suspicious features.
+	Sequence checks - checks that flag bad characters and low complexity in sequences, and checksums.
+	Sequence comparison - identity and consensus of aligned sequences, searching records for a query, contamination screening, and record equality.
+	Assembly statistics - length, GC content, and contiguity summaries of records and assemblies.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Annotation check related things begin here.
+
+******************************************************************************/
+
+// CDSFrameErrors returns every CDS feature whose length isn't a multiple of 3. Partial CDS are skipped since they aren't
+// expected to contain whole codons, and so are pseudogenes since they're often frameshifted. Anything returned is likely
+// an annotation error or an unmarked pseudogene.
+func (annotatedSequence AnnotatedSequence) CDSFrameErrors() []Feature {
+	var frameErrors []Feature
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != "CDS" || feature.IsPartial() || feature.IsPseudo() {
+			continue
+		}
+		if feature.Length()%3 != 0 {
+			frameErrors = append(frameErrors, feature)
+		}
+	}
+	return frameErrors
+}
+
+// RequireQualifiers returns an error for every feature of featureType that is missing any of the required qualifiers,
+// e.g. every CDS having a product and translation for an NCBI submission.
+func (annotatedSequence AnnotatedSequence) RequireQualifiers(featureType string, required []string) []error {
+	var errs []error
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != featureType {
+			continue
+		}
+		for _, qualifier := range required {
+			if _, ok := feature.Attributes[qualifier]; !ok {
+				errs = append(errs, fmt.Errorf("%s is missing required qualifier %s", describeFeature(feature), qualifier))
+			}
+		}
+	}
+	return errs
+}
+
+// DuplicateLocusTags returns every /locus_tag used by more than one feature of the same type, mapped to all the features
+// with that locus_tag in record order. A gene and its CDS or mRNA are expected to share a locus_tag so they aren't
+// duplicates on their own, but two genes or two CDS with the same locus_tag usually mean records were merged badly and
+// NCBI will reject them.
+func (annotatedSequence AnnotatedSequence) DuplicateLocusTags() map[string][]Feature {
+	featuresByLocusTag := make(map[string][]Feature)
+	typeCounts := make(map[string]map[string]int)
+	duplicated := make(map[string]bool)
+	for _, feature := range annotatedSequence.Features {
+		locusTag, ok := feature.Attributes["locus_tag"]
+		if !ok {
+			continue
+		}
+		featuresByLocusTag[locusTag] = append(featuresByLocusTag[locusTag], feature)
+		if typeCounts[locusTag] == nil {
+			typeCounts[locusTag] = make(map[string]int)
+		}
+		typeCounts[locusTag][feature.Type]++
+		if typeCounts[locusTag][feature.Type] > 1 {
+			duplicated[locusTag] = true
+		}
+	}
+
+	duplicates := make(map[string][]Feature)
+	for locusTag := range duplicated {
+		duplicates[locusTag] = featuresByLocusTag[locusTag]
+	}
+	return duplicates
+}
+
+// CheckStartStopCodons returns an error for every CDS that doesn't begin with a start codon or end with a stop codon in
+// the given NCBI translation table. The reading frame honors /codon_start, and ends that are marked partial in the
+// location aren't checked since their codons aren't in the sequence. Pseudogenes aren't checked.
+func (annotatedSequence AnnotatedSequence) CheckStartStopCodons(table int) []error {
+	geneticCode, err := getCodonTable(table)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != "CDS" || feature.IsPseudo() {
+			continue
+		}
+		featureLocation, err := feature.location()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", describeFeature(feature), err))
+			continue
+		}
+		codingSequence, err := annotatedSequence.CodingSequence(feature)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", describeFeature(feature), err))
+			continue
+		}
+		codingSequence = strings.ToUpper(codingSequence)
+		if len(codingSequence) < 3 {
+			errs = append(errs, fmt.Errorf("%s is too short to contain a codon", describeFeature(feature)))
+			continue
+		}
+
+		missingStart, missingStop := featureLocation.missingEnds()
+
+		startCodon := codingSequence[:3]
+		if !missingStart && !geneticCode.StartCodons[startCodon] {
+			errs = append(errs, fmt.Errorf("%s starts with %s which is not a start codon in table %d", describeFeature(feature), startCodon, table))
+		}
+		lastCodonEnd := len(codingSequence) - len(codingSequence)%3
+		stopCodon := codingSequence[lastCodonEnd-3 : lastCodonEnd]
+		if !missingStop && geneticCode.Translations[stopCodon] != '*' {
+			errs = append(errs, fmt.Errorf("%s ends with %s which is not a stop codon in table %d", describeFeature(feature), stopCodon, table))
+		}
+	}
+	return errs
+}
+
+// DefaultQualifierLengthLimit is the longest qualifier value ValidateForSubmission allows for qualifiers that aren't in
+// QualifierLengthLimits.
+var DefaultQualifierLengthLimit = 4000
+
+// QualifierLengthLimits holds the longest value ValidateForSubmission allows for specific qualifiers. It can be changed to
+// match the rules of whichever database a record is being submitted to.
+var QualifierLengthLimits = map[string]int{
+	"gene":      100,
+	"locus_tag": 100,
+}
+
+// ValidateForSubmission returns an error for every feature type and qualifier that would likely get a record rejected
+// by NCBI: feature types and qualifier names that aren't INSDC ones or registered with RegisterFeatureType and
+// RegisterQualifier, values longer than their limit in QualifierLengthLimits (or DefaultQualifierLengthLimit), values with non-ASCII
+// characters, /translation values that contain whitespace or non amino acid characters, /locus_tag values with whitespace,
+// /codon_start values other than 1, 2, or 3, and /transl_table values that aren't NCBI translation tables. Features are
+// checked in order and their qualifiers in alphabetical order.
+func (annotatedSequence AnnotatedSequence) ValidateForSubmission() []error {
+	var errs []error
+	for _, feature := range annotatedSequence.Features {
+		if !IsValidFeatureType(feature.Type) {
+			errs = append(errs, fmt.Errorf("%s is not a known feature type", describeFeature(feature)))
+		}
+
+		qualifiers := make([]string, 0, len(feature.Attributes))
+		for qualifier := range feature.Attributes {
+			qualifiers = append(qualifiers, qualifier)
+		}
+		sort.Strings(qualifiers)
+
+		for _, qualifier := range qualifiers {
+			if !IsValidQualifier(qualifier) {
+				errs = append(errs, fmt.Errorf("%s qualifier %s is not a known qualifier", describeFeature(feature), qualifier))
+				continue
+			}
+			for _, value := range feature.QualifierValues(qualifier) {
+				if err := validateQualifierValue(qualifier, value); err != nil {
+					errs = append(errs, fmt.Errorf("%s qualifier %s %w", describeFeature(feature), qualifier, err))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// returns an error describing the first submission rule a qualifier value breaks, or nil if it breaks none.
+func validateQualifierValue(qualifier, value string) error {
+	lengthLimit, ok := QualifierLengthLimits[qualifier]
+	if !ok {
+		lengthLimit = DefaultQualifierLengthLimit
+	}
+	if len(value) > lengthLimit {
+		return fmt.Errorf("is %d characters long, longer than the limit of %d", len(value), lengthLimit)
+	}
+	for _, character := range value {
+		if character > unicode.MaxASCII {
+			return fmt.Errorf("contains non-ASCII character %q", character)
+		}
+	}
+
+	switch qualifier {
+	case "translation":
+		if strings.ContainsAny(value, " \t\n") {
+			return fmt.Errorf("contains whitespace")
+		}
+		if invalidPositions := invalidCharacterPositions(value, alphabetCharacters[ProteinAlphabet]); invalidPositions != nil {
+			return fmt.Errorf("contains %q which is not an amino acid", value[invalidPositions[0]])
+		}
+	case "locus_tag":
+		if strings.ContainsAny(value, " \t\n") {
+			return fmt.Errorf("contains whitespace")
+		}
+	case "codon_start":
+		if value != "1" && value != "2" && value != "3" {
+			return fmt.Errorf("is %q, expected 1, 2, or 3", value)
+		}
+	case "transl_table":
+		table, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("is %q, expected an NCBI translation table number", value)
+		}
+		if _, err := getCodonTable(table); err != nil {
+			return fmt.Errorf("is %d which is not an NCBI translation table", table)
+		}
+	}
+	return nil
+}
+
+// describes a feature by its type and location for use in error messages.
+func describeFeature(feature Feature) string {
+	if feature.Location != "" {
+		return feature.Type + " at " + feature.Location
+	}
+	return feature.Type + " at " + strconv.Itoa(feature.Start) + ".." + strconv.Itoa(feature.End)
+}
+
+/******************************************************************************
+
+Annotation check related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Sequence check related things begin here.
+
+******************************************************************************/
+
+// Names of the alphabets ValidateSequence accepts.
+const (
+	DNAAlphabet     = "dna"
+	RNAAlphabet     = "rna"
+	IUPACAlphabet   = "iupac"
+	ProteinAlphabet = "protein"
+)
+
+// alphabetCharacters is the set of characters allowed in a sequence of each alphabet.
+var alphabetCharacters = map[string]string{
+	DNAAlphabet:     "ACGT",
+	RNAAlphabet:     "ACGU",
+	IUPACAlphabet:   "ACGTURYSWKMBDHVN",           // nucleotides plus IUPAC ambiguity codes.
+	ProteinAlphabet: "ACDEFGHIKLMNOPQRSTUVWYBZX*", // amino acids plus ambiguity codes and stop.
+}
+
+// ValidateSequence returns the 0-based index of every character in a sequence that isn't in the named alphabet, one of
+// dna, rna, iupac, or protein, so the exact problem positions can be reported. Checks are case insensitive, for alphabet
+// names too. Returns nil if the whole sequence is valid, or an error if the alphabet isn't one of these.
+func ValidateSequence(sequence string, alphabet string) ([]int, error) {
+	characters, ok := alphabetCharacters[strings.ToLower(strings.TrimSpace(alphabet))]
+	if !ok {
+		return nil, fmt.Errorf("unknown alphabet %q, expected dna, rna, iupac, or protein", alphabet)
+	}
+	return invalidCharacterPositions(sequence, characters), nil
+}
+
+// returns the 0-based index of every character in a sequence that isn't one of characters, ignoring case.
+func invalidCharacterPositions(sequence string, characters string) []int {
+	var invalidPositions []int
+	upperCharacters := strings.ToUpper(characters)
+	for position, character := range strings.ToUpper(sequence) {
+		if !strings.ContainsRune(upperCharacters, character) {
+			invalidPositions = append(invalidPositions, position)
+		}
+	}
+	return invalidPositions
+}
+
+// CheckMoleculeConsistency returns an error for every way the sequence disagrees with its declared Locus.MoleculeType:
+// RNA (like mRNA or rRNA) containing T, DNA containing U, either containing characters that aren't IUPAC nucleotide
+// codes, or a protein (an aa length or AA molecule type) made up entirely of nucleotides. Positions in errors are 1-based.
+// Records without a molecule type or sequence aren't checked.
+func (annotatedSequence AnnotatedSequence) CheckMoleculeConsistency() []error {
+	locus := annotatedSequence.Meta.Locus
+	moleculeType := strings.ToUpper(locus.MoleculeType)
+	sequence := annotatedSequence.Sequence.Sequence
+	if moleculeType == "" || sequence == "" {
+		return nil
+	}
+
+	if moleculeType == "AA" || moleculeType == "PROTEIN" || strings.HasSuffix(locus.SequenceLength, "aa") {
+		if invalidCharacterPositions(sequence, "ACGTUN") == nil {
+			return []error{fmt.Errorf("sequence is declared %s but is made up entirely of nucleotides", locus.MoleculeType)}
+		}
+		return nil
+	}
+
+	var wrongBase string
+	switch {
+	case strings.Contains(moleculeType, "RNA"):
+		wrongBase = "T"
+	case strings.Contains(moleculeType, "DNA"):
+		wrongBase = "U"
+	default:
+		return nil
+	}
+	var errs []error
+	if invalidPositions := invalidCharacterPositions(sequence, strings.Replace(alphabetCharacters[IUPACAlphabet], wrongBase, "", 1)); invalidPositions != nil {
+		var wrongBasePositions, nonNucleotidePositions []int
+		for _, position := range invalidPositions {
+			if strings.EqualFold(string(sequence[position]), wrongBase) {
+				wrongBasePositions = append(wrongBasePositions, position)
+			} else {
+				nonNucleotidePositions = append(nonNucleotidePositions, position)
+			}
+		}
+		if wrongBasePositions != nil {
+			errs = append(errs, fmt.Errorf("sequence is declared %s but contains %d %s bases, the first at position %d", locus.MoleculeType, len(wrongBasePositions), wrongBase, wrongBasePositions[0]+1))
+		}
+		if nonNucleotidePositions != nil {
+			firstPosition := nonNucleotidePositions[0]
+			errs = append(errs, fmt.Errorf("sequence is declared %s but contains %d characters that aren't nucleotides, the first %q at position %d", locus.MoleculeType, len(nonNucleotidePositions), sequence[firstPosition], firstPosition+1))
+		}
+	}
+	return errs
+}
+
+// SequenceEntropy returns the Shannon entropy of the base composition of every window of a sequence, sliding one base at
+// a time, so element i covers sequence[i:i+window]. Entropy is in bits (log base 2), so a window of a single repeated base
+// is 0 and a window with equal amounts of A, C, G, and T is 2. Low entropy windows like homopolymers and simple repeats
+// are candidates for masking. Counting is case insensitive. Returns nil if window is less than 1 or longer than the sequence.
+func SequenceEntropy(sequence string, window int) []float64 {
+	if window < 1 || window > len(sequence) {
+		return nil
+	}
+	sequence = strings.ToUpper(sequence)
+
+	var counts [256]int
+	entropy := func() float64 {
+		var windowEntropy float64
+		for _, count := range counts {
+			if count > 0 {
+				frequency := float64(count) / float64(window)
+				windowEntropy -= frequency * math.Log2(frequency)
+			}
+		}
+		return windowEntropy
+	}
+
+	for index := 0; index < window; index++ {
+		counts[sequence[index]]++
+	}
+	entropies := make([]float64, 0, len(sequence)-window+1)
+	entropies = append(entropies, entropy())
+	for windowStart := 1; windowStart+window <= len(sequence); windowStart++ {
+		counts[sequence[windowStart-1]]--
+		counts[sequence[windowStart+window-1]]++
+		entropies = append(entropies, entropy())
+	}
+	return entropies
+}
+
+// Seguid returns the SEGUID checksum of a sequence: the base64 encoded SHA-1 hash of the uppercased sequence with its
+// trailing "=" padding removed. Any two identical sequences share a SEGUID regardless of case, so it's a compact way to
+// check that a sequence hasn't been truncated or corrupted.
+func Seguid(sequence string) string {
+	hash := sha1.Sum([]byte(strings.ToUpper(sequence)))
+	return strings.TrimRight(base64.StdEncoding.EncodeToString(hash[:]), "=")
+}
+
+// VerifySeguid returns an error if an AnnotatedSequence carries a SEGUID checksum, embedded by a writer's IncludeChecksum
+// option, that doesn't match its sequence, meaning the sequence was changed or corrupted after it was written. Records
+// without a checksum pass.
+func (annotatedSequence AnnotatedSequence) VerifySeguid() error {
+	expected := annotatedSequence.Sequence.Seguid
+	if expected == "" {
+		return nil
+	}
+	if seguid := Seguid(annotatedSequence.Sequence.Sequence); seguid != expected {
+		return fmt.Errorf("sequence has SEGUID %s, expected %s", seguid, expected)
+	}
+	return nil
+}
+
+/******************************************************************************
+
+Sequence check related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Sequence comparison related things begin here.
+
+******************************************************************************/
+
+// Identity returns the percent identity of two aligned sequences of equal length, from 0 to 100, as the percentage of
+// aligned columns where both have the same character. Columns where both sequences have a gap ("-" or ".") are never
+// counted. If ignoreGaps is true columns with a gap in only one sequence aren't counted either, otherwise they count as
+// mismatches. Comparison is case insensitive. Returns 0 if the sequences differ in length or have no columns to count.
+func Identity(first, second string, ignoreGaps bool) float64 {
+	if len(first) != len(second) {
+		return 0
+	}
+	first, second = strings.ToUpper(first), strings.ToUpper(second)
+
+	isGap := func(character byte) bool { return character == '-' || character == '.' }
+	var matches, columns int
+	for index := 0; index < len(first); index++ {
+		firstGap, secondGap := isGap(first[index]), isGap(second[index])
+		if (firstGap && secondGap) || (ignoreGaps && (firstGap || secondGap)) {
+			continue
+		}
+		columns++
+		if first[index] == second[index] {
+			matches++
+		}
+	}
+	if columns == 0 {
+		return 0
+	}
+	return 100 * float64(matches) / float64(columns)
+}
+
+// Consensus returns the consensus of aligned nucleotide sequences of equal length. Each column gets its most common
+// character if at least threshold (a fraction from 0 to 1) of the sequences have it, and otherwise the IUPAC ambiguity
+// code for the most common bases that together reach threshold, or for every base in the column if they never do. Gaps
+// ("-" or ".") are only used if they're the most common character and reach threshold, and are never part of an
+// ambiguity code. U is counted as T, ambiguity codes in the sequences count as every base they stand for, and unknown
+// characters count as N. Returns "" if the sequences differ in length.
+func Consensus(sequences []string, threshold float64) string {
+	if len(sequences) == 0 {
+		return ""
+	}
+	for _, sequence := range sequences {
+		if len(sequence) != len(sequences[0]) {
+			return ""
+		}
+	}
+
+	var consensusBuilder strings.Builder
+	for column := 0; column < len(sequences[0]); column++ {
+		// counts of every base mask, with gaps at mask 0.
+		var counts [16]int
+		for _, sequence := range sequences {
+			character := strings.ToUpper(string(sequence[column]))
+			switch {
+			case character == "-" || character == ".":
+				counts[0]++
+			case character == "U":
+				counts[baseMask('T')]++
+			case baseMask(character[0]) > 0:
+				counts[baseMask(character[0])]++
+			default:
+				counts[baseMask('N')]++
+			}
+		}
+
+		masks := make([]int, 16)
+		for mask := range masks {
+			masks[mask] = mask
+		}
+		// ties go to bases over gaps and then to the lower mask, so A over C over G over T.
+		sort.Slice(masks, func(i, j int) bool {
+			if counts[masks[i]] != counts[masks[j]] {
+				return counts[masks[i]] > counts[masks[j]]
+			}
+			if (masks[i] == 0) != (masks[j] == 0) {
+				return masks[j] == 0
+			}
+			return masks[i] < masks[j]
+		})
+
+		needed := threshold * float64(len(sequences))
+		if float64(counts[masks[0]]) >= needed {
+			consensusBuilder.WriteByte(iupacCodesByBaseMask[masks[0]])
+			continue
+		}
+		consensusMask, covered := 0, 0
+		for _, mask := range masks {
+			if mask == 0 || counts[mask] == 0 {
+				continue
+			}
+			consensusMask |= mask
+			covered += counts[mask]
+			if float64(covered) >= needed {
+				break
+			}
+		}
+		consensusBuilder.WriteByte(iupacCodesByBaseMask[consensusMask])
+	}
+	return consensusBuilder.String()
+}
+
+// SearchHit is a region of a record that matches a SearchSequence query.
+type SearchHit struct {
+	RecordName string  // Meta.Name of the record, or its Locus.Name or Accession if it has no Name.
+	Start      int     // 1-based start of the hit on the record's forward strand.
+	End        int     // 1-based inclusive end of the hit on the record's forward strand.
+	Strand     string  // "+", or "-" if the reverse complement of the query matched.
+	Identity   float64 // percent identity from 0 to 100.
+}
+
+// SearchSequence finds every region of each record's sequence that matches query, or its reverse complement, with at least
+// minIdentity percent identity. Alignments are ungapped, so each hit is exactly as long as the query, and matching is case
+// insensitive. Overlapping hits on the same strand of a record are merged into one locus as they're found and only the
+// best, or the first of the best, is kept, so a query matching inside a repeat isn't reported once per base of the
+// repeat. A query that is its own reverse complement, like the EcoRI site GAATTC, is only searched for on the + strand so
+// each match isn't reported twice. Hits are ranked by identity, then by the order of the records, then by start.
+func SearchSequence(records []AnnotatedSequence, query string, minIdentity float64) []SearchHit {
+	query = strings.ToUpper(query)
+	if query == "" {
+		return nil
+	}
+	queries := map[string]string{"+": query, "-": ReverseComplement(query)}
+	strands := []string{"+", "-"}
+	if strings.EqualFold(queries["-"], query) {
+		strands = strands[:1]
+	}
+	// windows are abandoned as soon as they have more mismatches than minIdentity allows.
+	maxMismatches := int(float64(len(query)) * (100 - minIdentity) / 100)
+
+	type rankedHit struct {
+		SearchHit
+		recordIndex int
+	}
+	var hits []rankedHit
+	for recordIndex, record := range records {
+		recordName, _, _ := gffSequenceRegion(record.Meta)
+		sequence := strings.ToUpper(record.Sequence.Sequence)
+		for _, strand := range strands {
+			strandQuery := queries[strand]
+			// the best hit of the locus being scanned and the end of its last overlapping hit.
+			var best rankedHit
+			locusEnd := 0
+			for start := 0; start+len(strandQuery) <= len(sequence); start++ {
+				mismatches := 0
+				for index := 0; index < len(strandQuery) && mismatches <= maxMismatches; index++ {
+					if sequence[start+index] != strandQuery[index] {
+						mismatches++
+					}
+				}
+				identity := 100 * float64(len(strandQuery)-mismatches) / float64(len(strandQuery))
+				if mismatches > maxMismatches || identity < minIdentity {
+					continue
+				}
+				hit := rankedHit{SearchHit{RecordName: recordName, Start: start + 1, End: start + len(strandQuery), Strand: strand, Identity: identity}, recordIndex}
+				if locusEnd == 0 || hit.Start > locusEnd {
+					if locusEnd != 0 {
+						hits = append(hits, best)
+					}
+					best = hit
+				} else if hit.Identity > best.Identity {
+					best = hit
+				}
+				locusEnd = hit.End
+			}
+			if locusEnd != 0 {
+				hits = append(hits, best)
+			}
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Identity != hits[j].Identity {
+			return hits[i].Identity > hits[j].Identity
+		}
+		if hits[i].recordIndex != hits[j].recordIndex {
+			return hits[i].recordIndex < hits[j].recordIndex
+		}
+		return hits[i].Start < hits[j].Start
+	})
+
+	var searchHits []SearchHit
+	for _, hit := range hits {
+		searchHits = append(searchHits, hit.SearchHit)
+	}
+	return searchHits
+}
+
+// ContaminationHit is a region of a query that shares k-mers with a DetectContamination reference.
+type ContaminationHit struct {
+	Reference   string // Description of the reference that matched, without any leading ">".
+	Start       int    // 1-based start of the region on the query.
+	End         int    // 1-based inclusive end of the region on the query.
+	SharedKmers int    // how many of the region's k-mers are in the reference.
+}
+
+// DetectContamination screens a query sequence, like an assembled contig, for regions matching known contaminants such as
+// adapters, PhiX, or a host genome. Every k-mer of the query found in a reference, or in its reverse complement, marks
+// its bases as matching that reference, and runs of overlapping matching k-mers are reported as one hit. Matching is
+// case insensitive and k-mers containing anything other than A, C, G, or T are ignored so runs of N don't match each
+// other. Hits are sorted by start and then by the order of the references. Returns nil if kmer is less than 1.
+func DetectContamination(query string, references []Sequence, kmer int) []ContaminationHit {
+	if kmer < 1 || kmer > len(query) {
+		return nil
+	}
+	query = strings.ToUpper(query)
+	isUnambiguous := func(sequence string) bool {
+		return strings.Trim(sequence, "ACGT") == ""
+	}
+
+	var hits []ContaminationHit
+	for _, reference := range references {
+		referenceSequence := strings.ToUpper(reference.Sequence)
+		referenceKmers := make(map[string]bool)
+		for _, strand := range []string{referenceSequence, ReverseComplement(referenceSequence)} {
+			for kmerStart := 0; kmerStart+kmer <= len(strand); kmerStart++ {
+				if referenceKmer := strand[kmerStart : kmerStart+kmer]; isUnambiguous(referenceKmer) {
+					referenceKmers[referenceKmer] = true
+				}
+			}
+		}
+
+		var hit *ContaminationHit
+		for kmerStart := 0; kmerStart+kmer <= len(query); kmerStart++ {
+			if !referenceKmers[query[kmerStart:kmerStart+kmer]] {
+				continue
+			}
+			// hit.End is 1-based inclusive, which is the 0-based start of the first k-mer that doesn't overlap the hit.
+			if hit == nil || kmerStart >= hit.End {
+				hits = append(hits, ContaminationHit{Reference: strings.TrimPrefix(reference.Description, ">"), Start: kmerStart + 1})
+				hit = &hits[len(hits)-1]
+			}
+			hit.End = kmerStart + kmer
+			hit.SharedKmers++
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Start < hits[j].Start })
+	return hits
+}
+
+// Equal reports whether two AnnotatedSequences hold the same record: the same Meta, sequence description, bases, quality,
+// and features in the same order. Differences that don't change the record are ignored, which are the case of bases,
+// whitespace in feature Locations, nil versus empty maps and slices, feature Provenance, and resolved feature Sequences.
+func Equal(first, second AnnotatedSequence) bool {
+	return reflect.DeepEqual(normalizedMeta(first.Meta), normalizedMeta(second.Meta)) &&
+		first.Sequence.Description == second.Sequence.Description &&
+		EqualSequenceOnly(first, second)
+}
+
+// EqualSequenceOnly reports whether two AnnotatedSequences have the same bases, quality, and features, following the same
+// rules as Equal but ignoring Meta and the sequence description.
+func EqualSequenceOnly(first, second AnnotatedSequence) bool {
+	if !strings.EqualFold(first.Sequence.Sequence, second.Sequence.Sequence) || len(first.Features) != len(second.Features) {
+		return false
+	}
+	if len(first.Sequence.Quality) != 0 || len(second.Sequence.Quality) != 0 {
+		if !reflect.DeepEqual(first.Sequence.Quality, second.Sequence.Quality) {
+			return false
+		}
+	}
+	for featureIndex := range first.Features {
+		if !reflect.DeepEqual(normalizedFeature(first.Features[featureIndex]), normalizedFeature(second.Features[featureIndex])) {
+			return false
+		}
+	}
+	return true
+}
+
+// returns a copy of meta with empty slices set to nil so they compare equal.
+func normalizedMeta(meta Meta) Meta {
+	if len(meta.Taxonomy) == 0 {
+		meta.Taxonomy = nil
+	}
+	if len(meta.References) == 0 {
+		meta.References = nil
+	}
+	if len(meta.Primaries) == 0 {
+		meta.Primaries = nil
+	}
+	return meta
+}
+
+// returns a copy of a feature with everything Equal ignores cleared or normalized.
+func normalizedFeature(feature Feature) Feature {
+	feature.Location = strings.Join(strings.Fields(feature.Location), "")
+	feature.Provenance = nil
+	feature.Sequence = ""
+	if len(feature.Attributes) == 0 {
+		feature.Attributes = nil
+	}
+	if len(feature.RepeatedQualifiers) == 0 {
+		feature.RepeatedQualifiers = nil
+	}
+	return feature
+}
+
+/******************************************************************************
+
+Sequence comparison related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Assembly statistics related things begin here.
+
+******************************************************************************/
+
+// Length returns the number of bases in an AnnotatedSequence's sequence.
+func (annotatedSequence AnnotatedSequence) Length() int {
+	return len(annotatedSequence.Sequence.Sequence)
+}
+
+// GCContent returns the percentage of G and C bases in an AnnotatedSequence's sequence. Only A, C, G, T, and U are
+// counted so gaps and ambiguous bases like N don't dilute it. Counting is case insensitive. Returns 0 if there are no
+// bases to count.
+func (annotatedSequence AnnotatedSequence) GCContent() float64 {
+	gcBases, countedBases := gcCounts(annotatedSequence.Sequence.Sequence)
+	if countedBases == 0 {
+		return 0
+	}
+	return 100 * float64(gcBases) / float64(countedBases)
+}
+
+// returns the number of G and C bases and the number of A, C, G, T, and U bases in a sequence.
+func gcCounts(sequence string) (gcBases, countedBases int) {
+	for _, base := range sequence {
+		switch unicode.ToUpper(base) {
+		case 'G', 'C':
+			gcBases++
+			countedBases++
+		case 'A', 'T', 'U':
+			countedBases++
+		}
+	}
+	return gcBases, countedBases
+}
+
+// AssemblyStatistics is the standard quality summary of an assembly returned by AssemblyStats. Lengths are in bases and
+// GCContent is a percentage of the whole assembly.
+type AssemblyStatistics struct {
+	TotalLength    int
+	ContigCount    int
+	N50            int
+	L50            int
+	LargestContig  int
+	SmallestContig int
+	GCContent      float64
+}
+
+// AssemblyStats summarizes the records of a multi-contig assembly, treating each record as one contig. N50 is the length
+// of the shortest contig among the largest ones that together cover at least half the assembly, and L50 is how many
+// contigs that takes. GCContent is pooled over every base rather than averaged per contig so short contigs don't skew it.
+// Returns a zero AssemblyStatistics for no records.
+func AssemblyStats(records []AnnotatedSequence) AssemblyStatistics {
+	var statistics AssemblyStatistics
+	if len(records) == 0 {
+		return statistics
+	}
+
+	lengths := make([]int, 0, len(records))
+	var gcBases, countedBases int
+	for _, record := range records {
+		lengths = append(lengths, record.Length())
+		statistics.TotalLength += record.Length()
+		recordGCBases, recordCountedBases := gcCounts(record.Sequence.Sequence)
+		gcBases += recordGCBases
+		countedBases += recordCountedBases
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(lengths)))
+
+	statistics.ContigCount = len(lengths)
+	statistics.LargestContig = lengths[0]
+	statistics.SmallestContig = lengths[len(lengths)-1]
+	if countedBases > 0 {
+		statistics.GCContent = 100 * float64(gcBases) / float64(countedBases)
+	}
+
+	var cumulativeLength int
+	for index, length := range lengths {
+		cumulativeLength += length
+		if 2*cumulativeLength >= statistics.TotalLength {
+			statistics.N50 = length
+			statistics.L50 = index + 1
+			break
+		}
+	}
+	return statistics
+}
+
+/******************************************************************************
+
+Assembly statistics related things end here.
+
+******************************************************************************/
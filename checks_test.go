@@ -0,0 +1,373 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCDSFrameErrors(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Features: []Feature{
+			{Type: "CDS", Location: "join(1..10,20..30)"}, // 21 bases.
+			{Type: "CDS", Location: "join(1..10,20..31)"}, // 22 bases.
+			{Type: "CDS", Location: "<1..31"},             // partial.
+			{Type: "CDS", Start: 1, End: 10, Strand: "+"}, // gff, 10 bases.
+			{Type: "gene", Location: "1..31"},             // not a CDS.
+			{Type: "CDS", Location: "1..10", Attributes: map[string]string{"partial": ""}},
+		},
+	}
+
+	frameErrors := annotatedSequence.CDSFrameErrors()
+	if len(frameErrors) != 2 {
+		t.Fatalf("CDSFrameErrors() expected 2 frame errors. Got %d: %v", len(frameErrors), frameErrors)
+	}
+	if frameErrors[0].Location != "join(1..10,20..31)" || frameErrors[1].End != 10 {
+		t.Errorf("CDSFrameErrors() returned the wrong features: %v", frameErrors)
+	}
+}
+
+func TestRequireQualifiers(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Features: []Feature{
+			{Type: "CDS", Location: "1..9", Attributes: map[string]string{"product": "thing", "translation": "MK*"}},
+			{Type: "CDS", Location: "10..18", Attributes: map[string]string{"product": "thing"}},
+			{Type: "CDS", Start: 19, End: 27, Attributes: map[string]string{}},
+			{Type: "gene", Location: "1..27", Attributes: map[string]string{}},
+		},
+	}
+
+	errs := annotatedSequence.RequireQualifiers("CDS", []string{"product", "translation"})
+	if len(errs) != 3 {
+		t.Fatalf("RequireQualifiers() expected 3 errors. Got %d: %v", len(errs), errs)
+	}
+	if errs[0].Error() != "CDS at 10..18 is missing required qualifier translation" {
+		t.Errorf("RequireQualifiers() returned an unexpected error: %s", errs[0])
+	}
+}
+
+func TestCheckStartStopCodons(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: "ATGAAATAAGGGTTATTTCAT"},
+		Features: []Feature{
+			{Type: "CDS", Location: "1..9"},
+			{Type: "CDS", Location: "1..6"},                                                    // no stop.
+			{Type: "CDS", Location: "4..12"},                                                   // no start or stop.
+			{Type: "CDS", Location: "<4..9"},                                                   // partial start.
+			{Type: "CDS", Location: "complement(13..21)"},                                      // ATGAAATAA on the minus strand.
+			{Type: "CDS", Location: "complement(13..>18)"},                                     // partial start on the minus strand.
+			{Type: "gene", Location: "4..12"},                                                  // not a CDS.
+			{Type: "CDS", Location: "2..9", Attributes: map[string]string{"codon_start": "3"}}, // frame starts on base 4.
+		},
+	}
+
+	errs := annotatedSequence.CheckStartStopCodons(11)
+	if len(errs) != 4 {
+		t.Fatalf("CheckStartStopCodons() expected 4 errors. Got %d: %v", len(errs), errs)
+	}
+	if errs[0].Error() != "CDS at 1..6 ends with AAA which is not a stop codon in table 11" {
+		t.Errorf("CheckStartStopCodons() returned an unexpected error: %s", errs[0])
+	}
+	if errs[3].Error() != "CDS at 2..9 starts with AAA which is not a start codon in table 11" {
+		t.Errorf("CheckStartStopCodons() did not honor codon_start: %s", errs[3])
+	}
+
+	if errs := annotatedSequence.CheckStartStopCodons(99); len(errs) != 1 {
+		t.Errorf("CheckStartStopCodons() should return a single error for an unknown table. Got %v", errs)
+	}
+}
+
+func TestValidateSequence(t *testing.T) {
+	if invalidPositions, err := ValidateSequence("acgtACGT", DNAAlphabet); invalidPositions != nil || err != nil {
+		t.Errorf("ValidateSequence() flagged valid dna: %v, %v", invalidPositions, err)
+	}
+
+	invalidPositions, _ := ValidateSequence("ACGUNT-A", "DNA")
+	if len(invalidPositions) != 3 || invalidPositions[0] != 3 || invalidPositions[1] != 4 || invalidPositions[2] != 6 {
+		t.Errorf("ValidateSequence() expected invalid positions [3 4 6]. Got %v", invalidPositions)
+	}
+
+	if invalidPositions, _ := ValidateSequence("ACGURYN", IUPACAlphabet); invalidPositions != nil {
+		t.Errorf("ValidateSequence() flagged valid iupac: %v", invalidPositions)
+	}
+	if invalidPositions, _ := ValidateSequence("ACGU", "rna"); invalidPositions != nil {
+		t.Errorf("ValidateSequence() flagged valid rna: %v", invalidPositions)
+	}
+	if invalidPositions, _ := ValidateSequence("MKV*", ProteinAlphabet); invalidPositions != nil {
+		t.Errorf("ValidateSequence() flagged a valid protein: %v", invalidPositions)
+	}
+	// a string of characters isn't an alphabet name.
+	if _, err := ValidateSequence("ACGT", "ACGT"); err == nil {
+		t.Errorf("ValidateSequence() should return an error for an unknown alphabet")
+	}
+}
+
+func TestSequenceEntropy(t *testing.T) {
+	entropies := SequenceEntropy("AAAAacgtAA", 4)
+	expected := []float64{0, 0, 0.8112781244591328, 1.5, 2, 2, 1.5}
+	if len(entropies) != len(expected) {
+		t.Fatalf("SequenceEntropy() expected %d windows. Got %d", len(expected), len(entropies))
+	}
+	for index := range expected {
+		if math.Abs(entropies[index]-expected[index]) > 1e-9 {
+			t.Errorf("SequenceEntropy() window %d expected %f. Got %f", index, expected[index], entropies[index])
+		}
+	}
+
+	if SequenceEntropy("ACGT", 5) != nil || SequenceEntropy("ACGT", 0) != nil {
+		t.Errorf("SequenceEntropy() should return nil for windows that don't fit the sequence")
+	}
+}
+
+func TestSeguid(t *testing.T) {
+	if seguid := Seguid("atgcATGC"); seguid != "ib39AZ7gpKDhfR2sKvOg4lCj2Ow" {
+		t.Errorf("Seguid() expected ib39AZ7gpKDhfR2sKvOg4lCj2Ow. Got %s", seguid)
+	}
+	if Seguid("ATGCATG") == Seguid("ATGCATGC") {
+		t.Errorf("Seguid() should differ for a truncated sequence")
+	}
+}
+
+func TestValidateForSubmission(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Features: []Feature{
+			{Type: "CDS", Location: "1..9", Attributes: map[string]string{"translation": "MK*", "codon_start": "1", "transl_table": "11", "locus_tag": "b0001"}},
+			{Type: "CDS", Location: "10..18", Attributes: map[string]string{"translation": "MK VL", "codon_start": "4"}},
+			{Type: "CDS", Location: "19..27", Attributes: map[string]string{"transl_table": "99", "locus_tag": "b 0003", "product": "café"}},
+			{Type: "gene", Location: "1..27", Attributes: map[string]string{"gene": strings.Repeat("a", 101)}},
+		},
+	}
+
+	errs := annotatedSequence.ValidateForSubmission()
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	expected := []string{
+		`CDS at 10..18 qualifier codon_start is "4", expected 1, 2, or 3`,
+		"CDS at 10..18 qualifier translation contains whitespace",
+		"CDS at 19..27 qualifier locus_tag contains whitespace",
+		`CDS at 19..27 qualifier product contains non-ASCII character 'é'`,
+		"CDS at 19..27 qualifier transl_table is 99 which is not an NCBI translation table",
+		"gene at 1..27 qualifier gene is 101 characters long, longer than the limit of 100",
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("ValidateForSubmission() returned the wrong errors. Diff: %s", diff)
+	}
+}
+
+func TestDuplicateLocusTags(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Features: []Feature{
+			{Type: "gene", Location: "1..9", Attributes: map[string]string{"locus_tag": "b0001"}},
+			{Type: "CDS", Location: "1..9", Attributes: map[string]string{"locus_tag": "b0001"}},
+			{Type: "gene", Location: "10..18", Attributes: map[string]string{"locus_tag": "b0002"}},
+			{Type: "gene", Location: "19..27", Attributes: map[string]string{"locus_tag": "b0002"}},
+			{Type: "misc_feature", Location: "1..27"},
+		},
+	}
+	expected := map[string][]Feature{"b0002": annotatedSequence.Features[2:4]}
+	if diff := cmp.Diff(expected, annotatedSequence.DuplicateLocusTags()); diff != "" {
+		t.Errorf("DuplicateLocusTags() mismatch (-want +got):\n%s", diff)
+	}
+
+	if duplicates := ReadGbk("data/bsub.gbk").DuplicateLocusTags(); len(duplicates) != 0 {
+		t.Errorf("DuplicateLocusTags() expected no duplicates in bsub. Got %d", len(duplicates))
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	first, second := "ACGT-ACGTa", "ACGA-AC--A"
+	if identity := Identity(first, second, true); identity != 100*6/7.0 {
+		t.Errorf("Identity() ignoring gaps expected %f. Got %f", 100*6/7.0, identity)
+	}
+	if identity := Identity(first, second, false); identity != 100*6/9.0 {
+		t.Errorf("Identity() counting gaps expected %f. Got %f", 100*6/9.0, identity)
+	}
+	if Identity("ACGT", "ACG", true) != 0 || Identity("--", "..", false) != 0 {
+		t.Errorf("Identity() should return 0 for unequal lengths or no aligned columns")
+	}
+}
+
+func TestSearchSequence(t *testing.T) {
+	records := []AnnotatedSequence{
+		{Meta: Meta{Name: "first"}, Sequence: Sequence{Sequence: "ccccGAATTCGGATCCcccccccccGAATTCGGTTCCcccc"}},
+		{Meta: Meta{Locus: Locus{Name: "second"}}, Sequence: Sequence{Sequence: "ttttGGATCCGAATTCtttt"}},
+	}
+
+	hits := SearchSequence(records, "GAATTCGGATCC", 90)
+	expected := []SearchHit{
+		{RecordName: "first", Start: 5, End: 16, Strand: "+", Identity: 100},
+		{RecordName: "second", Start: 5, End: 16, Strand: "-", Identity: 100},
+		{RecordName: "first", Start: 26, End: 37, Strand: "+", Identity: 100 * 11 / 12.0},
+	}
+	if diff := cmp.Diff(expected, hits); diff != "" {
+		t.Errorf("SearchSequence() mismatch (-want +got):\n%s", diff)
+	}
+
+	if hits := SearchSequence(records, "GAATTCGGATCC", 100); len(hits) != 2 {
+		t.Errorf("SearchSequence() with a minimum identity of 100 expected 2 hits. Got %v", hits)
+	}
+	var starts []int
+	for _, hit := range SearchSequence(records, "CCCC", 100) {
+		starts = append(starts, hit.Start)
+	}
+	if diff := cmp.Diff([]int{1, 15, 36}, starts); diff != "" {
+		t.Errorf("SearchSequence() should keep only the best hit of each run of overlapping hits (-want +got):\n%s", diff)
+	}
+
+	// GAATTC is its own reverse complement, so each site should only be reported once.
+	expected = []SearchHit{
+		{RecordName: "first", Start: 5, End: 10, Strand: "+", Identity: 100},
+		{RecordName: "first", Start: 26, End: 31, Strand: "+", Identity: 100},
+		{RecordName: "second", Start: 11, End: 16, Strand: "+", Identity: 100},
+	}
+	if diff := cmp.Diff(expected, SearchSequence(records, "gaattc", 100)); diff != "" {
+		t.Errorf("SearchSequence() should only search the + strand for a palindromic query (-want +got):\n%s", diff)
+	}
+}
+
+func TestDetectContamination(t *testing.T) {
+	adapter := "AGATCGGAAGAGC"
+	references := []Sequence{
+		{Description: ">adapter", Sequence: adapter},
+		{Description: "phix", Sequence: "GAGTTTTATCGCTTCCATGACGCAGAAGTTAACACTTTCGGATATTTCTGATGAGTCGAA"},
+	}
+	query := "CCCCCCCCCCCCCCCCCCCC" + adapter + "GGGGGGGGGGGGGGGGGGGG" + ReverseComplement(references[1].Sequence[10:40]) + "NNNNNNNNNNNNNNNNNNNNNNNN"
+	hits := DetectContamination(strings.ToLower(query), references, 12)
+	expected := []ContaminationHit{
+		{Reference: "adapter", Start: 21, End: 33, SharedKmers: 2},
+		{Reference: "phix", Start: 54, End: 83, SharedKmers: 19},
+	}
+	if diff := cmp.Diff(expected, hits); diff != "" {
+		t.Errorf("DetectContamination() mismatch (-want +got):\n%s", diff)
+	}
+
+	if hits := DetectContamination(query, []Sequence{{Description: "Ns", Sequence: strings.Repeat("N", 30)}}, 12); hits != nil {
+		t.Errorf("DetectContamination() should ignore k-mers of N. Got %v", hits)
+	}
+	if hits := DetectContamination(query, references, 0); hits != nil {
+		t.Errorf("DetectContamination() should return nil for a k-mer size below 1. Got %v", hits)
+	}
+}
+
+func TestCheckMoleculeConsistency(t *testing.T) {
+	if errs := ReadGbk("data/bsub.gbk").CheckMoleculeConsistency(); errs != nil {
+		t.Errorf("CheckMoleculeConsistency() flagged a consistent record: %v", errs)
+	}
+
+	tests := []struct {
+		locus    Locus
+		sequence string
+		expected []string
+	}{
+		{Locus{MoleculeType: "mRNA"}, "AUGCAUGC", nil},
+		{Locus{MoleculeType: "mRNA"}, "AUGtATGC", []string{"sequence is declared mRNA but contains 2 T bases, the first at position 4"}},
+		{Locus{MoleculeType: "DNA"}, "ATGCUAEFG", []string{
+			"sequence is declared DNA but contains 1 U bases, the first at position 5",
+			`sequence is declared DNA but contains 2 characters that aren't nucleotides, the first 'E' at position 7`,
+		}},
+		{Locus{MoleculeType: "linear", SequenceLength: "8 aa"}, "ACGTACGT", []string{"sequence is declared linear but is made up entirely of nucleotides"}},
+		{Locus{MoleculeType: "AA"}, "MKVLAAGT", nil},
+		{Locus{}, "ATGU", nil},
+	}
+	for _, test := range tests {
+		annotatedSequence := AnnotatedSequence{Meta: Meta{Locus: test.locus}, Sequence: Sequence{Sequence: test.sequence}}
+		var got []string
+		for _, err := range annotatedSequence.CheckMoleculeConsistency() {
+			got = append(got, err.Error())
+		}
+		if diff := cmp.Diff(test.expected, got); diff != "" {
+			t.Errorf("CheckMoleculeConsistency() on %s mismatch (-want +got):\n%s", test.sequence, diff)
+		}
+	}
+}
+
+func TestConsensus(t *testing.T) {
+	sequences := []string{
+		"ACGTA-GU",
+		"ACGTC-G-",
+		"ACCTG-GT",
+		"ACCTT-AT",
+	}
+	if consensus := Consensus([]string{"A-", "--", "-T"}, 0.9); consensus != "AT" {
+		t.Errorf("Consensus() should prefer bases over gaps below the threshold. Got %s", consensus)
+	}
+	if consensus := Consensus(sequences, 0.75); consensus != "ACSTV-GT" {
+		t.Errorf("Consensus() at 0.75 expected ACSTV-GT. Got %s", consensus)
+	}
+	if consensus := Consensus(sequences, 0.5); consensus != "ACCTM-GT" {
+		t.Errorf("Consensus() at 0.5 expected ACCTM-GT. Got %s", consensus)
+	}
+	if consensus := Consensus([]string{"ACGT", "ACG"}, 0.5); consensus != "" {
+		t.Errorf("Consensus() should return an empty string for sequences of different lengths. Got %s", consensus)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	bsub := ReadGbk("data/bsub.gbk")
+	if !Equal(bsub, ReadGbk("data/bsub.gbk")) {
+		t.Errorf("Equal() should be true for the same file read twice")
+	}
+
+	changed := ReadGbk("data/bsub.gbk")
+	changed.Sequence.Sequence = strings.ToUpper(changed.Sequence.Sequence)
+	changed.Features[0].Provenance = &Provenance{Tool: "something else"}
+	changed.Features[0].Sequence = "ATG"
+	changed.Features[1].Location = " " + changed.Features[1].Location
+	if !Equal(bsub, changed) {
+		t.Errorf("Equal() should ignore case, Provenance, resolved sequences, and whitespace in locations")
+	}
+
+	changed.Meta.Definition = "something else"
+	if Equal(bsub, changed) || !EqualSequenceOnly(bsub, changed) {
+		t.Errorf("Equal() should compare Meta and EqualSequenceOnly() should not")
+	}
+
+	changed.Features[2].Attributes = copyAttributes(changed.Features[2].Attributes)
+	changed.Features[2].Attributes["note"] = "changed"
+	if EqualSequenceOnly(bsub, changed) {
+		t.Errorf("EqualSequenceOnly() should compare feature attributes")
+	}
+
+	empty := AnnotatedSequence{Features: []Feature{{Type: "gene", Attributes: map[string]string{}}}}
+	if !Equal(empty, AnnotatedSequence{Meta: Meta{Taxonomy: []string{}}, Features: []Feature{{Type: "gene"}}}) {
+		t.Errorf("Equal() should treat nil and empty maps and slices the same")
+	}
+}
+
+func TestAssemblyStats(t *testing.T) {
+	records := []AnnotatedSequence{
+		{Sequence: Sequence{Sequence: "GGGGGCCCCC"}},
+		{Sequence: Sequence{Sequence: strings.Repeat("at", 20)}},
+		{Sequence: Sequence{Sequence: "ACGTNNNNNNNNACGT"}},
+		{Sequence: Sequence{Sequence: "GCAT"}},
+	}
+	if records[2].GCContent() != 50 {
+		t.Errorf("GCContent() should ignore N. Expected 50, got %f", records[2].GCContent())
+	}
+
+	expected := AssemblyStatistics{
+		TotalLength:    70,
+		ContigCount:    4,
+		N50:            40,
+		L50:            1,
+		LargestContig:  40,
+		SmallestContig: 4,
+		GCContent:      100 * 16 / 62.0,
+	}
+	if diff := cmp.Diff(expected, AssemblyStats(records)); diff != "" {
+		t.Errorf("AssemblyStats() mismatch (-want +got):\n%s", diff)
+	}
+
+	expected = AssemblyStatistics{TotalLength: 30, ContigCount: 2, N50: 16, L50: 1, LargestContig: 16, SmallestContig: 14, GCContent: 50}
+	if diff := cmp.Diff(expected, AssemblyStats([]AnnotatedSequence{{Sequence: Sequence{Sequence: "ACGTNNNNNNNNACGT"}}, {Sequence: Sequence{Sequence: "ACGTACGTACGTAC"}}})); diff != "" {
+		t.Errorf("AssemblyStats() mismatch (-want +got):\n%s", diff)
+	}
+
+	if AssemblyStats(nil) != (AssemblyStatistics{}) {
+		t.Errorf("AssemblyStats() of no records should be empty")
+	}
+}
@@ -48,9 +48,16 @@ func convert(c *cli.Context) error {
 		} else if c.String("i") == "gbk" || c.String("i") == "gb" {
 			annotatedSequence = ParseGbk(stdinToString(os.Stdin))
 		} else if c.String("i") == "gff" {
-			annotatedSequence = ParseGff(stdinToString(os.Stdin))
+			var err error
+			annotatedSequence, err = ParseGff(stdinToString(os.Stdin))
+			if err != nil {
+				return err
+			}
 		}
 
+		// translating qualifier names between the gff and genbank vocabularies.
+		annotatedSequence = convertQualifierNames(annotatedSequence, formatsByExtension["."+c.String("i")], formatsByExtension["."+c.String("o")])
+
 		var output []byte
 
 		// logic for chosing output format, then builds string to be output.
@@ -58,6 +65,8 @@ func convert(c *cli.Context) error {
 			output, _ = json.MarshalIndent(annotatedSequence, "", " ")
 		} else if c.String("o") == "gff" {
 			output = BuildGff(annotatedSequence)
+		} else if c.String("o") == "gbk" || c.String("o") == "gb" {
+			output = BuildGbk(annotatedSequence)
 		}
 
 		// output to stdout
@@ -94,24 +103,33 @@ func convert(c *cli.Context) error {
 			go func(match string) {
 				extension := filepath.Ext(match)
 				var annotatedSequence AnnotatedSequence
+				var inputFormat string
 
 				// determining which reader to use and parse into AnnotatedSequence struct.
 				if extension == ".gff" || c.String("i") == "gff" {
 					annotatedSequence = ReadGff(match)
+					inputFormat = "gff"
 				} else if extension == ".gbk" || extension == ".gb" || c.String("i") == "gbk" || c.String("i") == "gb" {
 					annotatedSequence = ReadGbk(match)
+					inputFormat = "gbk"
 				} else if extension == ".json" || c.String("i") == "json" {
 					annotatedSequence = ReadJSON(match)
+					inputFormat = "json"
 				} else {
 					// TODO put default error handling here.
 				}
 
+				// translating qualifier names between the gff and genbank vocabularies.
+				annotatedSequence = convertQualifierNames(annotatedSequence, inputFormat, formatsByExtension["."+c.String("o")])
+
 				// determining output format and name, then writing out to name.
 				outputPath := match[0 : len(match)-len(extension)]
 				if c.String("o") == "json" {
 					WriteJSON(annotatedSequence, outputPath+".json")
 				} else if c.String("o") == "gff" {
 					WriteGff(annotatedSequence, outputPath+".gff")
+				} else if c.String("o") == "gbk" || c.String("o") == "gb" {
+					WriteGbk(annotatedSequence, outputPath+".gbk")
 				}
 
 				// decrementing wait group.
@@ -0,0 +1,1589 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+/******************************************************************************
+
+File is structured as so:
+
+	Queries - methods for asking questions about the features of an AnnotatedSequence.
+	Extraction - getting the sequence a feature covers.
+	Indexing - an interval index for fast coordinate queries.
+	Merging - helpers for combining features from multiple AnnotatedSequences.
+	Updating - copy on write helpers for changing a single feature.
+	Repeats and gaps - finding tandem and inverted repeats and runs of N in a sequence and returning them as features.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Feature query related things begin here.
+
+******************************************************************************/
+
+// QualifierKeys returns the sorted set of every attribute key used by any feature in an AnnotatedSequence.
+func (annotatedSequence AnnotatedSequence) QualifierKeys() []string {
+	keySet := make(map[string]bool)
+	for _, feature := range annotatedSequence.Features {
+		for key := range feature.Attributes {
+			keySet[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// QualifierValues returns every value of a qualifier on a feature. Genbank qualifiers that are repeated, like /db_xref,
+// return each value separately and in order. Returns nil if the feature doesn't have the qualifier.
+func (feature Feature) QualifierValues(key string) []string {
+	value, ok := feature.Attributes[key]
+	if !ok {
+		return nil
+	}
+	return append([]string{value}, feature.RepeatedQualifiers[key]...)
+}
+
+// FeaturesByQualifierRegExp returns every feature with a value of the qualifier key matching the regular expression
+// pattern, like every feature whose product matches (?i)transposase. Repeated qualifiers match if any of their values
+// do. Returns an error if pattern doesn't compile.
+func (annotatedSequence AnnotatedSequence) FeaturesByQualifierRegExp(key, pattern string) ([]Feature, error) {
+	expression, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Feature
+	for _, feature := range annotatedSequence.Features {
+		for _, value := range feature.QualifierValues(key) {
+			if expression.MatchString(value) {
+				matches = append(matches, feature)
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// Length returns the number of bases a feature covers. Joined features only count the bases in their segments, so introns
+// aren't included. Returns 0 if the feature's location can't be parsed or has an UndefinedCoordinate, or if it runs
+// backwards like a gff feature that wraps around the origin of a circular sequence, since its length depends on the
+// sequence's. LengthDistribution counts those.
+func (feature Feature) Length() int {
+	length, _ := feature.lengthOn(0)
+	return length
+}
+
+// returns the number of bases a feature covers on a circular sequence circularLength bases long, or on a linear sequence
+// if circularLength is 0. ok is false if the feature's location can't be parsed, has an UndefinedCoordinate, or runs
+// backwards on a linear sequence.
+func (feature Feature) lengthOn(circularLength int) (length int, ok bool) {
+	featureLocation, err := feature.location()
+	if err != nil {
+		return 0, false
+	}
+	if length = featureLocation.length(circularLength); length < 0 {
+		return 0, false
+	}
+	return length, true
+}
+
+// returns the number of bases covered by a location and all of its segments. A segment whose end comes before its start
+// wraps around the origin of a circular sequence circularLength bases long, and makes the length -1 if circularLength is
+// 0 since the sequence is then linear. A segment with an UndefinedCoordinate has no length, so it makes the length -1 too.
+func (featureLocation Location) length(circularLength int) int {
+	if featureLocation.Join {
+		length := 0
+		for _, subLocation := range featureLocation.SubLocations {
+			subLength := subLocation.length(circularLength)
+			if subLength < 0 {
+				return -1
+			}
+			length += subLength
+		}
+		return length
+	}
+	if featureLocation.Start == UndefinedCoordinate || featureLocation.End == UndefinedCoordinate {
+		return -1
+	}
+	if featureLocation.End >= featureLocation.Start {
+		return featureLocation.End - featureLocation.Start + 1
+	}
+	if circularLength > 0 {
+		return circularLength - featureLocation.Start + 1 + featureLocation.End
+	}
+	return -1
+}
+
+// Contains reports whether a 1-based position falls within a feature, bounds included. Positions that fall between the
+// segments of a joined feature (like an intron) are not contained.
+func (feature Feature) Contains(position int) bool {
+	featureLocation, err := feature.location()
+	if err != nil {
+		return false
+	}
+	return featureLocation.contains(position)
+}
+
+// reports whether a 1-based position falls within a location or any of its segments.
+func (featureLocation Location) contains(position int) bool {
+	if featureLocation.Join {
+		for _, subLocation := range featureLocation.SubLocations {
+			if subLocation.contains(position) {
+				return true
+			}
+		}
+		return false
+	}
+	return featureLocation.Start <= position && position <= featureLocation.End
+}
+
+// IsPartial reports whether a feature is known to be incomplete. Genbank features are partial if their location has a "<"
+// or ">" marker or they carry a /partial qualifier. Gff features are partial if they have a partial, start_range, or end_range attribute.
+func (feature Feature) IsPartial() bool {
+	for _, partialAttribute := range []string{"partial", "start_range", "end_range"} {
+		if value, ok := feature.Attributes[partialAttribute]; ok && value != "false" {
+			return true
+		}
+	}
+	featureLocation, err := feature.location()
+	if err != nil {
+		return false
+	}
+	return featureLocation.FivePrimePartial || featureLocation.ThreePrimePartial
+}
+
+// IsPseudo reports whether a feature is a pseudogene that shouldn't be translated. Genbank marks these with a /pseudo flag
+// or a /pseudogene="type" qualifier and gff with a pseudo or pseudogene attribute, any of which count unless set to "false".
+func (feature Feature) IsPseudo() bool {
+	for _, pseudoQualifier := range []string{"pseudo", "pseudogene"} {
+		if value, ok := feature.Attributes[pseudoQualifier]; ok && value != "false" {
+			return true
+		}
+	}
+	return false
+}
+
+// reports whether a location's 5' start and 3' end are marked partial. "<" and ">" mark the lower and higher coordinate,
+// which are the 3' and 5' ends of a minus strand feature.
+func (featureLocation Location) missingEnds() (missingStart, missingEnd bool) {
+	missingStart, missingEnd = featureLocation.FivePrimePartial, featureLocation.ThreePrimePartial
+	if featureLocation.isMinusStrand() {
+		missingStart, missingEnd = missingEnd, missingStart
+	}
+	return missingStart, missingEnd
+}
+
+// reports whether a location is on the minus strand, either because it's complemented as a whole or because it's a join of
+// complemented segments like join(complement(5..8),complement(1..3)).
+func (featureLocation Location) isMinusStrand() bool {
+	if featureLocation.Join && len(featureLocation.SubLocations) > 0 {
+		return featureLocation.Complement != featureLocation.SubLocations[0].Complement
+	}
+	return featureLocation.Complement
+}
+
+// LengthDistribution returns the length of every feature of featureType, in the order the features appear, for summaries
+// like the mean CDS length or for spotting implausibly short or long features. Joined features are the total length of
+// their segments, so introns aren't counted. On circular sequences a segment whose end comes before its start, like a
+// gff feature running from 4000 to 100, wraps around the origin. Partial features are the length of the part that's
+// annotated. Features that can't be parsed, that have an UndefinedCoordinate, or that run backwards on a linear sequence,
+// are skipped.
+func (annotatedSequence AnnotatedSequence) LengthDistribution(featureType string) []int {
+	circularLength := 0
+	if annotatedSequence.Meta.Locus.Circular {
+		circularLength = len(annotatedSequence.Sequence.Sequence)
+	}
+
+	var lengths []int
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != featureType {
+			continue
+		}
+		if length, ok := feature.lengthOn(circularLength); ok {
+			lengths = append(lengths, length)
+		}
+	}
+	return lengths
+}
+
+// DistanceMatrix returns the pairwise distances between every feature of featureType, in the order the features appear.
+// The distance between two features is the number of bases in the gap between their outer bounds, so overlapping and
+// directly adjacent features are 0 apart. On circular sequences the shorter way around the origin is used. Features
+// whose location can't be parsed are -1 away from everything.
+func (annotatedSequence AnnotatedSequence) DistanceMatrix(featureType string) [][]int {
+	var spans []Location
+	var parsed []bool
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != featureType {
+			continue
+		}
+		featureLocation, err := feature.location()
+		spans = append(spans, featureLocation)
+		parsed = append(parsed, err == nil)
+	}
+
+	sequenceLength := len(annotatedSequence.Sequence.Sequence)
+	circular := annotatedSequence.Meta.Locus.Circular && sequenceLength > 0
+	distances := make([][]int, len(spans))
+	for firstIndex := range spans {
+		distances[firstIndex] = make([]int, len(spans))
+		for secondIndex := range spans {
+			if !parsed[firstIndex] || !parsed[secondIndex] {
+				distances[firstIndex][secondIndex] = -1
+				continue
+			}
+			distances[firstIndex][secondIndex] = spanDistance(spans[firstIndex], spans[secondIndex], sequenceLength, circular)
+		}
+	}
+	return distances
+}
+
+// returns the number of bases between the outer bounds of two locations, going around the origin if it's shorter.
+func spanDistance(first, second Location, sequenceLength int, circular bool) int {
+	if first.Start <= second.End && second.Start <= first.End {
+		return 0
+	}
+	if first.Start > second.Start {
+		first, second = second, first
+	}
+	distance := second.Start - first.End - 1
+	if circular {
+		distance = minInt(distance, sequenceLength-second.End+first.Start-1)
+	}
+	return maxInt(distance, 0)
+}
+
+// OverlappingGenePairs returns every pair of gene features that share at least one base, with the pair in the order the
+// genes appear. If oppositeStrands is true only pairs on opposite strands are returned. Prokaryotic genes rarely overlap
+// by more than a few bases, so long overlaps are usually annotation errors. Genes whose location can't be parsed are skipped.
+func (annotatedSequence AnnotatedSequence) OverlappingGenePairs(oppositeStrands bool) [][2]Feature {
+	type geneSpan struct {
+		index      int
+		start, end int
+		segments   [][2]int
+		minus      bool
+	}
+	var genes []geneSpan
+	for index, feature := range annotatedSequence.Features {
+		if feature.Type != "gene" {
+			continue
+		}
+		featureLocation, err := feature.location()
+		if err != nil {
+			continue
+		}
+		segments := featureLocation.segments()
+		gene := geneSpan{index: index, start: segments[0][0], end: segments[0][1], segments: segments, minus: featureLocation.isMinusStrand()}
+		for _, segment := range segments {
+			gene.start, gene.end = minInt(gene.start, segment[0]), maxInt(gene.end, segment[1])
+		}
+		genes = append(genes, gene)
+	}
+
+	// sweep genes in order of their start so each is only compared against genes that begin before it ends.
+	sort.SliceStable(genes, func(i, j int) bool { return genes[i].start < genes[j].start })
+	var pairs [][2]int
+	for firstIndex, first := range genes {
+		for _, second := range genes[firstIndex+1:] {
+			if second.start > first.end {
+				break
+			}
+			if oppositeStrands && first.minus == second.minus {
+				continue
+			}
+			if segmentsOverlap(first.segments, second.segments) {
+				pairs = append(pairs, [2]int{minInt(first.index, second.index), maxInt(first.index, second.index)})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	genePairs := make([][2]Feature, len(pairs))
+	for index, pair := range pairs {
+		genePairs[index] = [2]Feature{annotatedSequence.Features[pair[0]], annotatedSequence.Features[pair[1]]}
+	}
+	return genePairs
+}
+
+// reports whether any 1-based inclusive segment of one location overlaps any segment of another.
+func segmentsOverlap(first, second [][2]int) bool {
+	for _, firstSegment := range first {
+		for _, secondSegment := range second {
+			if firstSegment[0] <= secondSegment[1] && secondSegment[0] <= firstSegment[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reports whether every base of the inner segments lies within one of the outer segments.
+func segmentsContain(outer, inner [][2]int) bool {
+	for _, innerSegment := range inner {
+		inSegment := false
+		for _, outerSegment := range outer {
+			inSegment = inSegment || (outerSegment[0] <= innerSegment[0] && innerSegment[1] <= outerSegment[1])
+		}
+		if !inSegment {
+			return false
+		}
+	}
+	return true
+}
+
+// LongestIsoforms returns one transcript per gene: the one with the longest spliced CDS, as is usual before extracting a
+// proteome. Gff genes are linked to their transcripts, and transcripts to their CDS, through Parent attributes, with the
+// CDS of a transcript spread across one feature per exon. Genes whose CDS name the gene itself as Parent, as NCBI's
+// prokaryotic gffs do, have no mRNA, so their CDS are their single transcript and the first of them is returned for
+// it. Genbank files don't link features this way, so for them the
+// longest CDS of each /gene (or /locus_tag if there's no /gene) is returned instead, and a CDS with neither is returned
+// on its own. Genes without a CDS are left out and ties go to whichever isoform comes first.
+func (annotatedSequence AnnotatedSequence) LongestIsoforms() []Feature {
+	children := make(map[string][]Feature)
+	for _, feature := range annotatedSequence.Features {
+		for _, parent := range feature.parents() {
+			children[parent] = append(children[parent], feature)
+		}
+	}
+
+	var longestIsoforms []Feature
+	if len(children) == 0 {
+		// genbank, where each CDS is already spliced.
+		longestCDS := make(map[string]int)
+		for _, feature := range annotatedSequence.Features {
+			if feature.Type != "CDS" {
+				continue
+			}
+			var gene string
+			if geneName, ok := feature.Attributes["gene"]; ok {
+				gene = "gene " + geneName
+			} else if locusTag, ok := feature.Attributes["locus_tag"]; ok {
+				gene = "locus_tag " + locusTag
+			} else {
+				longestIsoforms = append(longestIsoforms, feature)
+				continue
+			}
+			if isoformIndex, seen := longestCDS[gene]; !seen {
+				longestCDS[gene] = len(longestIsoforms)
+				longestIsoforms = append(longestIsoforms, feature)
+			} else if feature.Length() > longestIsoforms[isoformIndex].Length() {
+				longestIsoforms[isoformIndex] = feature
+			}
+		}
+		return longestIsoforms
+	}
+
+	for _, gene := range annotatedSequence.Features {
+		if gene.Type != "gene" {
+			continue
+		}
+		var longestTranscript, geneCDS Feature
+		longestLength, geneCDSLength := 0, 0
+		for _, transcript := range children[gene.Attributes["ID"]] {
+			if transcript.Type == "CDS" {
+				if geneCDSLength == 0 {
+					geneCDS = transcript
+				}
+				geneCDSLength += transcript.Length()
+				continue
+			}
+			splicedLength := 0
+			for _, child := range children[transcript.Attributes["ID"]] {
+				if child.Type == "CDS" {
+					splicedLength += child.Length()
+				}
+			}
+			if splicedLength > longestLength {
+				longestTranscript, longestLength = transcript, splicedLength
+			}
+		}
+		if geneCDSLength > longestLength {
+			longestTranscript, longestLength = geneCDS, geneCDSLength
+		}
+		if longestLength > 0 {
+			longestIsoforms = append(longestIsoforms, longestTranscript)
+		}
+	}
+	return longestIsoforms
+}
+
+// GeneIntervals returns one gene feature per gene spanning from the lowest to the highest coordinate of its CDS and exon
+// features, the envelope of a gene model drawn in genome plots. Gff parts are grouped by the gene at the top of their
+// Parent attributes and genbank parts by their /gene, or /locus_tag if there's no /gene. Parts with neither are a gene on
+// their own. Each interval takes its strand from the gene's first part and its attributes from the gff gene if it's in
+// the record, otherwise from the grouping qualifier. Genes are in the order their first part appears.
+func (annotatedSequence AnnotatedSequence) GeneIntervals() []Feature {
+	featuresByID := make(map[string]Feature)
+	for _, feature := range annotatedSequence.Features {
+		if id, ok := feature.Attributes["ID"]; ok {
+			featuresByID[id] = feature
+		}
+	}
+	// follows the first Parent of each feature up to the top of its tree, guarding against Parent cycles.
+	rootID := func(feature Feature) string {
+		var id string
+		for visited := make(map[string]bool); len(feature.parents()) > 0; {
+			id = feature.parents()[0]
+			parent, ok := featuresByID[id]
+			if !ok || visited[id] {
+				break
+			}
+			visited[id] = true
+			feature = parent
+		}
+		return id
+	}
+
+	var intervals []Feature
+	intervalIndexes := make(map[string]int)
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != "CDS" && feature.Type != "exon" {
+			continue
+		}
+		featureLocation, err := feature.location()
+		if err != nil {
+			continue
+		}
+		segments := featureLocation.segments()
+		start, end := segments[0][0], segments[0][1]
+		for _, segment := range segments {
+			start, end = minInt(start, segment[0]), maxInt(end, segment[1])
+		}
+
+		geneKey, attributes := rootID(feature), map[string]string{}
+		var repeatedQualifiers map[string][]string
+		if gene, ok := featuresByID[geneKey]; ok {
+			gene = gene.clone()
+			attributes, repeatedQualifiers = gene.Attributes, gene.RepeatedQualifiers
+		} else if geneKey != "" {
+			attributes["ID"] = geneKey
+		} else if gene, ok := feature.Attributes["gene"]; ok {
+			geneKey, attributes["gene"] = "gene "+gene, gene
+		} else if locusTag, ok := feature.Attributes["locus_tag"]; ok {
+			geneKey, attributes["locus_tag"] = "locus_tag "+locusTag, locusTag
+		} else {
+			geneKey = "part " + strconv.Itoa(len(intervals))
+		}
+
+		intervalIndex, seen := intervalIndexes[geneKey]
+		if !seen {
+			intervalIndexes[geneKey] = len(intervals)
+			interval := Feature{Name: feature.Name, Source: feature.Source, Type: "gene", Attributes: attributes, RepeatedQualifiers: repeatedQualifiers}
+			if feature.Location != "" {
+				interval.setLocation(Location{Start: start, End: end, Complement: featureLocation.isMinusStrand()})
+			} else {
+				interval.Start, interval.End, interval.Score, interval.Strand, interval.Phase = start, end, ".", "+", "."
+				if featureLocation.isMinusStrand() {
+					interval.Strand = "-"
+				}
+			}
+			intervals = append(intervals, interval)
+			continue
+		}
+
+		interval := &intervals[intervalIndex]
+		if interval.Location != "" {
+			intervalLocation, _ := interval.location()
+			intervalLocation.Start, intervalLocation.End = minInt(intervalLocation.Start, start), maxInt(intervalLocation.End, end)
+			interval.setLocation(intervalLocation)
+		} else {
+			interval.Start, interval.End = minInt(interval.Start, start), maxInt(interval.End, end)
+		}
+	}
+	return intervals
+}
+
+// PeptideParts returns the sig_peptide, transit_peptide, propeptide, and mat_peptide features that lie within a CDS on
+// its strand, in the order they appear, so the processed protein can be reconstructed from its parts. A part only counts
+// if all of its bases are in the CDS's segments.
+func (annotatedSequence AnnotatedSequence) PeptideParts(cdsFeature Feature) []Feature {
+	cdsLocation, err := cdsFeature.location()
+	if err != nil {
+		return nil
+	}
+	cdsSegments := cdsLocation.segments()
+
+	var peptideParts []Feature
+	for _, feature := range annotatedSequence.Features {
+		switch feature.Type {
+		case "sig_peptide", "transit_peptide", "propeptide", "mat_peptide":
+		default:
+			continue
+		}
+		partLocation, err := feature.location()
+		if err != nil || partLocation.isMinusStrand() != cdsLocation.isMinusStrand() {
+			continue
+		}
+		if segmentsContain(cdsSegments, partLocation.segments()) {
+			peptideParts = append(peptideParts, feature)
+		}
+	}
+	return peptideParts
+}
+
+// returns the IDs in a gff feature's Parent attribute, which can list more than one parent separated by commas.
+func (feature Feature) parents() []string {
+	parent, ok := feature.Attributes["Parent"]
+	if !ok || parent == "" {
+		return nil
+	}
+	return strings.Split(parent, ",")
+}
+
+// CoverageDepth returns how many features of featureType cover each base of the sequence, where element i is the
+// sequence's base i+1. Gffs of a region of a larger contig have their coordinates offset by the region's start, the same
+// way FeatureSequence does. Only the segments of joined features count, so introns aren't covered. Features that can't
+// be parsed, and segments with an UndefinedCoordinate, are skipped and anything past the ends of the sequence is ignored.
+func (annotatedSequence AnnotatedSequence) CoverageDepth(featureType string) []int {
+	var intervals [][2]int
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != featureType {
+			continue
+		}
+		featureLocation, err := feature.location()
+		if err != nil {
+			continue
+		}
+		for _, segment := range featureLocation.segments() {
+			if segment[0] != UndefinedCoordinate && segment[1] != UndefinedCoordinate {
+				intervals = append(intervals, segment)
+			}
+		}
+	}
+	if offset := annotatedSequence.regionOffset(); offset != 0 {
+		for intervalIndex := range intervals {
+			intervals[intervalIndex][0] -= offset
+			intervals[intervalIndex][1] -= offset
+		}
+	}
+	return IntervalCoverage(intervals, len(annotatedSequence.Sequence.Sequence))
+}
+
+// returns the 1-based inclusive start and end of every contiguous segment of a location.
+func (featureLocation Location) segments() [][2]int {
+	if !featureLocation.Join {
+		return [][2]int{{featureLocation.Start, featureLocation.End}}
+	}
+	var segments [][2]int
+	for _, subLocation := range featureLocation.SubLocations {
+		segments = append(segments, subLocation.segments()...)
+	}
+	return segments
+}
+
+// IntervalCoverage counts how many 1-based inclusive intervals cover each position of a sequence of length bases, where
+// element i of the result is position i+1. It uses a difference array so millions of intervals take time proportional to
+// the number of intervals plus the length rather than their product. Parts of intervals outside 1..length are ignored,
+// as are intervals that start or end on an UndefinedCoordinate.
+func IntervalCoverage(intervals [][2]int, length int) []int {
+	// one extra slot so an interval ending on the last base has somewhere to record its end.
+	difference := make([]int, length+1)
+	for _, interval := range intervals {
+		if interval[0] == UndefinedCoordinate || interval[1] == UndefinedCoordinate {
+			continue
+		}
+		start, end := maxInt(interval[0], 1), minInt(interval[1], length)
+		if start > end {
+			continue
+		}
+		difference[start-1]++
+		difference[end]--
+	}
+
+	coverage := make([]int, length)
+	depth := 0
+	for position := range coverage {
+		depth += difference[position]
+		coverage[position] = depth
+	}
+	return coverage
+}
+
+/******************************************************************************
+
+Feature query related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Feature extraction related things begin here.
+
+******************************************************************************/
+
+// FeatureSequence returns the nucleotide sequence covered by a feature. Genbank features are resolved from their Location
+// string and gff features from their Start, End, and Strand. Minus strand features are reverse complemented. Gap features
+// with a numeric /estimated_length are a run of that many Ns rather than whatever their location spans. Gffs of a region
+// of a larger contig have their coordinates offset by the region's start.
+func (annotatedSequence AnnotatedSequence) FeatureSequence(feature Feature) (string, error) {
+	gapLength, ok, err := feature.estimatedGapLength()
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return strings.Repeat("N", gapLength), nil
+	}
+
+	featureLocation, err := feature.location()
+	if err != nil {
+		return "", err
+	}
+	return annotatedSequence.locationSequence(featureLocation)
+}
+
+// CodingSequence returns the sequence of a CDS starting from its first whole codon. Genbank CDS features that begin
+// mid-codon carry a /codon_start qualifier of 1, 2, or 3 giving the base their reading frame starts on, and the bases
+// before it are skipped. Features without /codon_start start on their first base.
+func (annotatedSequence AnnotatedSequence) CodingSequence(feature Feature) (string, error) {
+	sequence, err := annotatedSequence.FeatureSequence(feature)
+	if err != nil {
+		return "", err
+	}
+	codonStart, err := feature.codonStart()
+	if err != nil {
+		return "", err
+	}
+	if codonStart-1 > len(sequence) {
+		return "", fmt.Errorf("codon_start %d is past the end of a %d base feature", codonStart, len(sequence))
+	}
+	return sequence[codonStart-1:], nil
+}
+
+// ErrPseudo is returned when asked to translate a pseudogene, since its translation would be meaningless.
+var ErrPseudo = errors.New("feature is a pseudogene")
+
+// TranslateFeature returns the protein sequence encoded by a CDS using the given NCBI translation table. The reading
+// frame is offset by the feature's /codon_start qualifier in the same way as CodingSequence. A CDS that begins with its
+// start codon, so one that isn't 5' partial and has no /codon_start offset, is translated with TranslateCDS so an
+// alternative start codon becomes M. Pseudogenes aren't translated and return an error wrapping ErrPseudo.
+func (annotatedSequence AnnotatedSequence) TranslateFeature(feature Feature, table int) (string, error) {
+	if feature.IsPseudo() {
+		return "", fmt.Errorf("could not translate %s: %w", describeFeature(feature), ErrPseudo)
+	}
+	codingSequence, err := annotatedSequence.CodingSequence(feature)
+	if err != nil {
+		return "", err
+	}
+	featureLocation, err := feature.location()
+	if err != nil {
+		return "", err
+	}
+	codonStart, _ := feature.codonStart()
+	if missingStart, _ := featureLocation.missingEnds(); feature.Type == "CDS" && codonStart == 1 && !missingStart {
+		return TranslateCDS(codingSequence, table)
+	}
+	return Translate(codingSequence, table)
+}
+
+// TranslateAll translates every CDS in an AnnotatedSequence with the given NCBI translation table, spread across a pool of
+// workers goroutines. The result maps each CDS's identifier (its ID, locus_tag, or protein_id, whichever is found first)
+// to its protein. Pseudogenes are skipped. Returns an error if two CDS share an identifier, or if any CDS can't be
+// translated, in which case no more CDS are handed to the workers and the error is that of the first CDS in the record
+// that failed, however many workers there are.
+func (annotatedSequence AnnotatedSequence) TranslateAll(table, workers int) (map[string]string, error) {
+	if _, err := getCodonTable(table); err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var codingFeatures []Feature
+	seenIdentifiers := make(map[string]bool)
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != "CDS" || feature.IsPseudo() {
+			continue
+		}
+		identifier := feature.identifier()
+		if seenIdentifiers[identifier] {
+			return nil, fmt.Errorf("more than one CDS has the identifier %q", identifier)
+		}
+		seenIdentifiers[identifier] = true
+		codingFeatures = append(codingFeatures, feature)
+	}
+
+	// each worker writes only its own CDS's slots, so the results need no locking and keep the record's order.
+	proteins := make([]string, len(codingFeatures))
+	errs := make([]error, len(codingFeatures))
+	var failed int32
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for featureIndex := range jobs {
+				proteins[featureIndex], errs[featureIndex] = annotatedSequence.TranslateFeature(codingFeatures[featureIndex], table)
+				if errs[featureIndex] != nil {
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	// CDS are handed out in order, so every CDS before one that failed has been translated and the lowest failing index
+	// is the same whether or not later CDS were.
+	for featureIndex := range codingFeatures {
+		if atomic.LoadInt32(&failed) != 0 {
+			break
+		}
+		jobs <- featureIndex
+	}
+	close(jobs)
+	wg.Wait()
+
+	proteinsByIdentifier := make(map[string]string, len(codingFeatures))
+	for featureIndex, feature := range codingFeatures {
+		if errs[featureIndex] != nil {
+			return nil, fmt.Errorf("could not translate %s: %w", feature.identifier(), errs[featureIndex])
+		}
+		proteinsByIdentifier[feature.identifier()] = proteins[featureIndex]
+	}
+	return proteinsByIdentifier, nil
+}
+
+// CDSOptions controls what ConcatenatedCDSWithOptions keeps of each CDS.
+type CDSOptions struct {
+	KeepStopCodons bool // keep each CDS's final stop codon rather than removing it.
+}
+
+// ConcatenatedCDS returns the coding sequence of every CDS joined end to end in the order the features appear, for
+// genome wide codon usage. Each CDS is strand corrected and starts at its /codon_start, any trailing bases that don't make
+// a whole codon are dropped, and a final stop codon in the given NCBI translation table is removed, so the result is
+// always in frame. Pseudogenes and CDS whose sequence can't be extracted are skipped. Returns "" for an unknown table.
+// Use ConcatenatedCDSWithOptions to keep the stop codons.
+func (annotatedSequence AnnotatedSequence) ConcatenatedCDS(table int) string {
+	return annotatedSequence.ConcatenatedCDSWithOptions(table, CDSOptions{})
+}
+
+// ConcatenatedCDSWithOptions is ConcatenatedCDS with CDSOptions, like keeping stop codons so they're counted in a codon
+// usage table.
+func (annotatedSequence AnnotatedSequence) ConcatenatedCDSWithOptions(table int, options CDSOptions) string {
+	geneticCode, err := getCodonTable(table)
+	if err != nil {
+		return ""
+	}
+
+	var concatenatedBuilder strings.Builder
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != "CDS" || feature.IsPseudo() {
+			continue
+		}
+		codingSequence, err := annotatedSequence.CodingSequence(feature)
+		if err != nil {
+			continue
+		}
+		codingSequence = codingSequence[:len(codingSequence)-len(codingSequence)%3]
+		if !options.KeepStopCodons && len(codingSequence) >= 3 && geneticCode.Translations[strings.ToUpper(codingSequence[len(codingSequence)-3:])] == '*' {
+			codingSequence = codingSequence[:len(codingSequence)-3]
+		}
+		concatenatedBuilder.WriteString(codingSequence)
+	}
+	return concatenatedBuilder.String()
+}
+
+// CodonPositionFrequencies returns the frequency of each base at the first, second, and third positions of codons across
+// every CDS, for picking substitution models in phylogenetics where third positions evolve fastest. Codons are taken from
+// ConcatenatedCDS with the given NCBI translation table, so partial codons and final stop codons aren't counted. Bases
+// are uppercased and each map's frequencies sum to 1. The maps are empty if there are no codons.
+func (annotatedSequence AnnotatedSequence) CodonPositionFrequencies(table int) [3]map[rune]float64 {
+	frequencies := [3]map[rune]float64{{}, {}, {}}
+	codingSequence := strings.ToUpper(annotatedSequence.ConcatenatedCDS(table))
+	codonCount := len(codingSequence) / 3
+	if codonCount == 0 {
+		return frequencies
+	}
+	for baseIndex, base := range codingSequence {
+		frequencies[baseIndex%3][base]++
+	}
+	for _, positionFrequencies := range frequencies {
+		for base := range positionFrequencies {
+			positionFrequencies[base] /= float64(codonCount)
+		}
+	}
+	return frequencies
+}
+
+// Promoters returns every regulatory feature with a /regulatory_class of promoter, with each feature's Sequence filled in
+// with the bases it covers. Promoter features from before /regulatory_class was introduced, which have a Type of
+// promoter instead, are returned as well. Sequence is left empty for promoters whose location can't be resolved.
+func (annotatedSequence AnnotatedSequence) Promoters() []Feature {
+	var promoters []Feature
+	for _, feature := range annotatedSequence.Features {
+		isPromoter := feature.Type == "promoter"
+		if feature.Type == "regulatory" {
+			for _, regulatoryClass := range feature.QualifierValues("regulatory_class") {
+				isPromoter = isPromoter || regulatoryClass == "promoter"
+			}
+		}
+		if !isPromoter {
+			continue
+		}
+		if promoterSequence, err := annotatedSequence.FeatureSequence(feature); err == nil {
+			feature.Sequence = promoterSequence
+		}
+		promoters = append(promoters, feature)
+	}
+	return promoters
+}
+
+// ExtractWithContext returns a new AnnotatedSequence of just the bases a feature spans plus flank bases on either side,
+// fewer where the sequence ends first, for zooming in on a gene and its neighborhood. Every feature overlapping those
+// bases is carried over with its coordinates rebased onto the new sequence. Features that stick out of it are cut at its
+// edges, with the cut ends of genbank Locations marked partial, and segments of joined Locations that fall outside it are
+// dropped. The new sequence is always linear, so the window doesn't wrap around the origin of circular sequences.
+func (annotatedSequence AnnotatedSequence) ExtractWithContext(feature Feature, flank int) (AnnotatedSequence, error) {
+	if flank < 0 {
+		return AnnotatedSequence{}, fmt.Errorf("flank must not be negative, got %d", flank)
+	}
+	featureLocation, err := feature.location()
+	if err != nil {
+		return AnnotatedSequence{}, err
+	}
+	offset := annotatedSequence.regionOffset()
+	sequenceLength := len(annotatedSequence.Sequence.Sequence)
+	segments := featureLocation.segments()
+	featureStart, featureEnd := segments[0][0], segments[0][1]
+	for _, segment := range segments {
+		featureStart, featureEnd = minInt(featureStart, segment[0]), maxInt(featureEnd, segment[1])
+	}
+	if featureStart-offset < 1 || featureEnd-offset > sequenceLength || featureStart > featureEnd {
+		return AnnotatedSequence{}, fmt.Errorf("location %d..%d is outside of a sequence of length %d", featureStart, featureEnd, sequenceLength)
+	}
+	windowStart := maxInt(featureStart-flank, offset+1)
+	windowEnd := minInt(featureEnd+flank, offset+sequenceLength)
+
+	var features []Feature
+	for featureIndex, contextFeature := range annotatedSequence.Features {
+		contextLocation, err := contextFeature.location()
+		if err != nil {
+			return AnnotatedSequence{}, fmt.Errorf("could not rebase %s feature %d: %w", contextFeature.Type, featureIndex, err)
+		}
+		windowedLocation, ok := contextLocation.window(windowStart, windowEnd)
+		if !ok {
+			continue
+		}
+		if contextFeature.Location != "" {
+			contextFeature.setLocation(windowedLocation)
+		} else if contextFeature.Start > 0 && contextFeature.End > 0 {
+			contextFeature.Start = maxInt(contextFeature.Start, windowStart) - windowStart + 1
+			contextFeature.End = minInt(contextFeature.End, windowEnd) - windowStart + 1
+		}
+		contextFeature.Sequence = ""
+		features = append(features, contextFeature)
+	}
+
+	context := annotatedSequence
+	context.Features = features
+	context.Sequence.Sequence = annotatedSequence.Sequence.Sequence[windowStart-offset-1 : windowEnd-offset]
+	context.Sequence.Seguid = ""
+	// quality that isn't one score per base can't be lined up with the window, so it's dropped.
+	context.Sequence.Quality = nil
+	if len(annotatedSequence.Sequence.Quality) == sequenceLength {
+		context.Sequence.Quality = annotatedSequence.Sequence.Quality[windowStart-offset-1 : windowEnd-offset]
+	}
+	context.Meta.RegionStart, context.Meta.RegionEnd = 0, 0
+	context.Meta.Locus.Circular = false
+	if context.Meta.Locus.SequenceLength != "" {
+		context.Meta.Locus.SequenceLength = strconv.Itoa(len(context.Sequence.Sequence)) + " bp"
+	}
+	return context, nil
+}
+
+// ExtractFeatureByName returns the feature whose ID, /gene, or /locus_tag is name, plus flank bases on either side, as a
+// new AnnotatedSequence in the same way as ExtractWithContext. A gene and its CDS usually share a /gene and /locus_tag,
+// so a matching gene feature is preferred over other matches, and otherwise the first match is used. Returns an error if
+// no feature has the name.
+func (annotatedSequence AnnotatedSequence) ExtractFeatureByName(name string, flank int) (AnnotatedSequence, error) {
+	if name == "" {
+		return AnnotatedSequence{}, fmt.Errorf("no feature name given")
+	}
+	var match *Feature
+	for featureIndex, feature := range annotatedSequence.Features {
+		if feature.Attributes["ID"] != name && feature.Attributes["gene"] != name && feature.Attributes["locus_tag"] != name {
+			continue
+		}
+		if match == nil || (feature.Type == "gene" && match.Type != "gene") {
+			match = &annotatedSequence.Features[featureIndex]
+		}
+	}
+	if match == nil {
+		return AnnotatedSequence{}, fmt.Errorf("no feature has the ID, gene, or locus_tag %q", name)
+	}
+	return annotatedSequence.ExtractWithContext(*match, flank)
+}
+
+// returns the part of a location inside windowStart..windowEnd with its coordinates rebased so windowStart is 1. Ends
+// cut off by the window are marked partial. Returns false if no part of the location is inside the window.
+func (featureLocation Location) window(windowStart, windowEnd int) (Location, bool) {
+	if featureLocation.Join {
+		var subLocations []Location
+		for _, subLocation := range featureLocation.SubLocations {
+			if windowedSubLocation, ok := subLocation.window(windowStart, windowEnd); ok {
+				subLocations = append(subLocations, windowedSubLocation)
+			}
+		}
+		if len(subLocations) == 0 {
+			return Location{}, false
+		}
+		if len(subLocations) == 1 {
+			subLocations[0].Complement = subLocations[0].Complement != featureLocation.Complement
+			return subLocations[0], true
+		}
+		featureLocation.SubLocations = subLocations
+		return featureLocation, true
+	}
+
+	if featureLocation.End < windowStart || featureLocation.Start > windowEnd {
+		return Location{}, false
+	}
+	if featureLocation.Start < windowStart {
+		featureLocation.Start = windowStart
+		featureLocation.FivePrimePartial = true
+	}
+	if featureLocation.End > windowEnd {
+		featureLocation.End = windowEnd
+		featureLocation.ThreePrimePartial = true
+	}
+	featureLocation.Start -= windowStart - 1
+	featureLocation.End -= windowStart - 1
+	return featureLocation, true
+}
+
+// returns the identifier a feature is best known by: its gff ID, or else its genbank locus_tag or protein_id. Features
+// with none of these are identified by their type and location.
+func (feature Feature) identifier() string {
+	for _, identifierKey := range []string{"ID", "locus_tag", "protein_id"} {
+		if identifier, ok := feature.Attributes[identifierKey]; ok && identifier != "" {
+			return identifier
+		}
+	}
+	return describeFeature(feature)
+}
+
+// returns the 1-based base a feature's reading frame starts on from its /codon_start qualifier, defaulting to 1.
+func (feature Feature) codonStart() (int, error) {
+	codonStartString, ok := feature.Attributes["codon_start"]
+	if !ok {
+		return 1, nil
+	}
+	codonStart, err := strconv.Atoi(strings.TrimSpace(codonStartString))
+	if err != nil || codonStart < 1 || codonStart > 3 {
+		return 0, fmt.Errorf("invalid codon_start %q, expected 1, 2, or 3", codonStartString)
+	}
+	return codonStart, nil
+}
+
+// returns the /estimated_length of an assembly_gap or gap feature. ok is false for other features and for gaps of unknown
+// length, which should be extracted from their location like any other feature.
+func (feature Feature) estimatedGapLength() (gapLength int, ok bool, err error) {
+	if feature.Type != "assembly_gap" && feature.Type != "gap" {
+		return 0, false, nil
+	}
+	estimatedLength, hasEstimatedLength := feature.Attributes["estimated_length"]
+	estimatedLength = strings.TrimSpace(estimatedLength)
+	if !hasEstimatedLength || estimatedLength == "unknown" {
+		return 0, false, nil
+	}
+	gapLength, err = strconv.Atoi(estimatedLength)
+	if err != nil || gapLength < 0 {
+		return 0, false, fmt.Errorf("invalid estimated_length %q, expected a number or unknown", estimatedLength)
+	}
+	return gapLength, true, nil
+}
+
+// returns the parsed genbank Location of a feature, or a location built from Start, End, and Strand for gff features.
+func (feature Feature) location() (Location, error) {
+	if feature.Location != "" {
+		return parseLocation(feature.Location)
+	}
+	return Location{Start: feature.Start, End: feature.End, Complement: feature.Strand == "-"}, nil
+}
+
+// sets the Location of a genbank feature and brings its Start, End, and Strand in line with it, the same way getFeatures
+// does when parsing, so gff output of rewritten features doesn't keep their old span.
+func (feature *Feature) setLocation(featureLocation Location) {
+	feature.Location = formatLocation(featureLocation)
+	// reading the written location back gets the outer span of joins whose segments have moved.
+	featureLocation = ParseLocation(feature.Location)
+	feature.Start, feature.End = featureLocation.Start, featureLocation.End
+	feature.Strand = locationStrand(featureLocation)
+}
+
+// returns the gff strand, "+" or "-", of a location.
+func locationStrand(featureLocation Location) string {
+	if featureLocation.isMinusStrand() {
+		return "-"
+	}
+	return "+"
+}
+
+// FeatureQuality returns the quality scores of the bases a feature covers, in the same order as FeatureSequence returns
+// the bases, so minus strand features have their scores reversed. Gap features with a numeric /estimated_length score
+// each of the Ns FeatureSequence returns for them 0, since nothing is known about those bases. Returns nil if the
+// sequence has no quality scores.
+func (annotatedSequence AnnotatedSequence) FeatureQuality(feature Feature) ([]int, error) {
+	quality := annotatedSequence.Sequence.Quality
+	if quality == nil {
+		return nil, nil
+	}
+	if len(quality) != len(annotatedSequence.Sequence.Sequence) {
+		return nil, fmt.Errorf("sequence has %d bases but %d quality scores", len(annotatedSequence.Sequence.Sequence), len(quality))
+	}
+	gapLength, ok, err := feature.estimatedGapLength()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return make([]int, gapLength), nil
+	}
+	featureLocation, err := feature.location()
+	if err != nil {
+		return nil, err
+	}
+	return locationQuality(featureLocation.mapCoordinates(func(coordinate int) int {
+		return coordinate - annotatedSequence.regionOffset()
+	}), quality)
+}
+
+// returns the quality scores covered by a location, following the same rules as locationSequence.
+func locationQuality(featureLocation Location, quality []int) ([]int, error) {
+	var scores []int
+	if featureLocation.Join {
+		for _, subLocation := range featureLocation.SubLocations {
+			subScores, err := locationQuality(subLocation, quality)
+			if err != nil {
+				return nil, err
+			}
+			scores = append(scores, subScores...)
+		}
+	} else {
+		if featureLocation.Start < 1 || featureLocation.End > len(quality) || featureLocation.Start > featureLocation.End {
+			return nil, fmt.Errorf("location %d..%d is outside of a sequence of length %d", featureLocation.Start, featureLocation.End, len(quality))
+		}
+		scores = append(scores, quality[featureLocation.Start-1:featureLocation.End]...)
+	}
+
+	if featureLocation.Complement {
+		for left, right := 0, len(scores)-1; left < right; left, right = left+1, right-1 {
+			scores[left], scores[right] = scores[right], scores[left]
+		}
+	}
+	return scores, nil
+}
+
+// returns how far feature coordinates are ahead of positions in the sequence. A gff describing a region of a larger
+// contig, like ##sequence-region chr1 1000 2000, has coordinates on the contig but only the region's bases in its
+// ##FASTA block, so the first base is coordinate 1000 rather than 1. The offset only applies if the sequence is no longer
+// than the region, otherwise the sequence is taken to be the whole contig.
+func (annotatedSequence AnnotatedSequence) regionOffset() int {
+	meta := annotatedSequence.Meta
+	if meta.RegionStart <= 1 {
+		return 0
+	}
+	if meta.RegionEnd != 0 && len(annotatedSequence.Sequence.Sequence) > meta.RegionEnd-meta.RegionStart+1 {
+		return 0
+	}
+	return meta.RegionStart - 1
+}
+
+// returns the sequence covered by a location. Joined locations are concatenated in the order their segments were written.
+func (annotatedSequence AnnotatedSequence) locationSequence(featureLocation Location) (string, error) {
+	var sequence string
+	if featureLocation.Join {
+		var sequenceBuilder strings.Builder
+		for _, subLocation := range featureLocation.SubLocations {
+			subSequence, err := annotatedSequence.locationSequence(subLocation)
+			if err != nil {
+				return "", err
+			}
+			sequenceBuilder.WriteString(subSequence)
+		}
+		sequence = sequenceBuilder.String()
+	} else {
+		parentSequence := annotatedSequence.Sequence.Sequence
+		offset := annotatedSequence.regionOffset()
+		start, end := featureLocation.Start-offset, featureLocation.End-offset
+		if start < 1 || end > len(parentSequence) || start > end {
+			return "", fmt.Errorf("location %d..%d is outside of a sequence of length %d", featureLocation.Start, featureLocation.End, len(parentSequence))
+		}
+		sequence = parentSequence[start-1 : end]
+	}
+
+	if featureLocation.Complement {
+		sequence = ReverseComplement(sequence)
+	}
+	return sequence, nil
+}
+
+/******************************************************************************
+
+Feature extraction related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Feature indexing related things begin here.
+
+******************************************************************************/
+
+// FeatureIndex answers coordinate queries against the features of an AnnotatedSequence without scanning every feature.
+// It's an interval tree laid out over the features sorted by start: the feature in the middle of any range is the root
+// of that range's subtree, and each root keeps the largest end in its subtree so queries can skip whole subtrees, even
+// when a full length source feature overlaps everything. It's immutable once built, so a single FeatureIndex can be
+// shared by any number of goroutines calling Query at the same time. Features are copied into the index but their
+// Attributes maps are shared with the AnnotatedSequence it was built from, so neither the AnnotatedSequence nor the
+// returned features should be mutated while the index is in use.
+type FeatureIndex struct {
+	features       []Feature
+	starts         []int // outer start of each feature, sorted.
+	ends           []int // outer end of each feature.
+	subtreeMaxEnds []int // largest end in the subtree rooted at each feature.
+}
+
+// BuildFeatureIndex indexes every feature of an AnnotatedSequence by the outer bounds of its location. Features whose
+// location can't be parsed or that have undefined coordinates are left out.
+func BuildFeatureIndex(annotatedSequence AnnotatedSequence) FeatureIndex {
+	type span struct {
+		feature    Feature
+		start, end int
+	}
+	var spans []span
+	for _, feature := range annotatedSequence.Features {
+		featureLocation, err := feature.location()
+		if err != nil || featureLocation.Start == UndefinedCoordinate || featureLocation.End == UndefinedCoordinate {
+			continue
+		}
+		spans = append(spans, span{feature, featureLocation.Start, featureLocation.End})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	index := FeatureIndex{
+		features:       make([]Feature, len(spans)),
+		starts:         make([]int, len(spans)),
+		ends:           make([]int, len(spans)),
+		subtreeMaxEnds: make([]int, len(spans)),
+	}
+	for spanIndex, span := range spans {
+		index.features[spanIndex] = span.feature
+		index.starts[spanIndex] = span.start
+		index.ends[spanIndex] = span.end
+	}
+	if len(spans) > 0 {
+		index.buildSubtree(0, len(spans))
+	}
+	return index
+}
+
+// fills in subtreeMaxEnds for the subtree holding features[low:high] and returns the largest end in it. The range must
+// not be empty.
+func (index FeatureIndex) buildSubtree(low, high int) int {
+	root := (low + high) / 2
+	maxEnd := index.ends[root]
+	if low < root {
+		maxEnd = maxInt(maxEnd, index.buildSubtree(low, root))
+	}
+	if root+1 < high {
+		maxEnd = maxInt(maxEnd, index.buildSubtree(root+1, high))
+	}
+	index.subtreeMaxEnds[root] = maxEnd
+	return maxEnd
+}
+
+// Query returns every indexed feature whose outer bounds overlap the 1-based inclusive range start..end, sorted by start.
+// It's safe to call from multiple goroutines at once.
+func (index FeatureIndex) Query(start, end int) []Feature {
+	if len(index.features) == 0 {
+		return nil
+	}
+	var overlapping []Feature
+	index.querySubtree(0, len(index.features), start, end, &overlapping)
+	return overlapping
+}
+
+// appends the features of the subtree holding features[low:high] that overlap start..end to overlapping, in start order.
+func (index FeatureIndex) querySubtree(low, high, start, end int, overlapping *[]Feature) {
+	if low >= high {
+		return
+	}
+	root := (low + high) / 2
+	// nothing in this subtree reaches start.
+	if index.subtreeMaxEnds[root] < start {
+		return
+	}
+	index.querySubtree(low, root, start, end, overlapping)
+	// the root and everything to its right start after end.
+	if index.starts[root] > end {
+		return
+	}
+	if index.ends[root] >= start {
+		*overlapping = append(*overlapping, index.features[root])
+	}
+	index.querySubtree(root+1, high, start, end, overlapping)
+}
+
+// CountOverlaps maps the index of every feature in a to how many features in b overlap it, like bedtools intersect -c.
+// Features overlap if any of their segments share a base, so a feature in b that falls in an intron of a joined feature
+// in a doesn't count. Strand is ignored. Gff features on different seqids (Name) never overlap. b is indexed with a
+// FeatureIndex so large sets don't take a scan of b per feature of a. Features in a whose location can't be parsed map
+// to 0 and features in b whose location can't be parsed are never counted.
+func CountOverlaps(a, b []Feature) map[int]int {
+	index := BuildFeatureIndex(AnnotatedSequence{Features: b})
+	counts := make(map[int]int, len(a))
+	for featureIndex, feature := range a {
+		counts[featureIndex] = 0
+		featureLocation, err := feature.location()
+		if err != nil {
+			continue
+		}
+		segments := featureLocation.segments()
+		for _, candidate := range index.Query(featureLocation.Start, featureLocation.End) {
+			if feature.Name != "" && candidate.Name != "" && feature.Name != candidate.Name {
+				continue
+			}
+			candidateLocation, _ := candidate.location()
+			if segmentsOverlap(segments, candidateLocation.segments()) {
+				counts[featureIndex]++
+			}
+		}
+	}
+	return counts
+}
+
+/******************************************************************************
+
+Feature indexing related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Feature merging related things begin here.
+
+******************************************************************************/
+
+// gff attributes whose values are references to the ID attribute of other features.
+var gffIDReferenceAttributes = []string{"Parent", "Derives_from"}
+
+// UniquifyIDs prefixes the ID attribute of every feature with a per record prefix so that features from all records can be
+// merged without ID collisions. References to IDs in the same record (Parent, Derives_from) are remapped with the same
+// prefix so parent/child links within a record are preserved, while references to IDs the record doesn't have, like
+// features in another file, are left as they are. The input records are left untouched.
+func UniquifyIDs(records []AnnotatedSequence) []AnnotatedSequence {
+	uniquified := make([]AnnotatedSequence, len(records))
+	for recordIndex, record := range records {
+		name := record.Meta.Name
+		if name == "" {
+			name = "record"
+		}
+		// the index guarantees uniqueness even when records share a name.
+		prefix := name + "_" + strconv.Itoa(recordIndex+1) + "_"
+
+		renamedIDs := make(map[string]bool)
+		for _, feature := range record.Features {
+			if id, ok := feature.Attributes["ID"]; ok {
+				renamedIDs[id] = true
+			}
+		}
+
+		features := make([]Feature, len(record.Features))
+		for featureIndex, feature := range record.Features {
+			feature = feature.clone()
+			if id, ok := feature.Attributes["ID"]; ok {
+				feature.Attributes["ID"] = prefix + id
+			}
+			for _, referenceAttribute := range gffIDReferenceAttributes {
+				references, ok := feature.Attributes[referenceAttribute]
+				if !ok {
+					continue
+				}
+				// gff allows multiple references separated by commas. IDs are scoped to their record so every reference to one
+				// of its IDs gets the same prefix as the ID it points to.
+				referenceIDs := strings.Split(references, ",")
+				for referenceIndex, referenceID := range referenceIDs {
+					if renamedIDs[referenceID] {
+						referenceIDs[referenceIndex] = prefix + referenceID
+					}
+				}
+				feature.Attributes[referenceAttribute] = strings.Join(referenceIDs, ",")
+			}
+			features[featureIndex] = feature
+		}
+
+		record.Features = features
+		uniquified[recordIndex] = record
+	}
+	return uniquified
+}
+
+// StampProvenance returns a copy of an AnnotatedSequence with provenance set on every feature, e.g. to record the
+// predictor and parameters that produced a set of features before merging them with others. Features that already have
+// provenance are overwritten.
+func (annotatedSequence AnnotatedSequence) StampProvenance(provenance Provenance) AnnotatedSequence {
+	features := make([]Feature, len(annotatedSequence.Features))
+	for featureIndex, feature := range annotatedSequence.Features {
+		// every feature gets its own copy so changing one doesn't change the rest, or the original.
+		feature = feature.clone()
+		featureProvenance := provenance.clone()
+		feature.Provenance = &featureProvenance
+		features[featureIndex] = feature
+	}
+	annotatedSequence.Features = features
+	return annotatedSequence
+}
+
+// copyAttributes returns a deep copy of a feature's attribute map so the copy can be changed without aliasing the original.
+func copyAttributes(attributes map[string]string) map[string]string {
+	if attributes == nil {
+		return nil
+	}
+	attributesCopy := make(map[string]string, len(attributes))
+	for key, value := range attributes {
+		attributesCopy[key] = value
+	}
+	return attributesCopy
+}
+
+/******************************************************************************
+
+Feature merging related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Feature updating related things begin here.
+
+******************************************************************************/
+
+// WithStrand returns a copy of a feature on strand, "+" or "-". Genbank features have their Location complemented if
+// that's needed to put them on the new strand.
+func (feature Feature) WithStrand(strand string) Feature {
+	updated := feature.clone()
+	updated.Strand = strand
+	if updated.Location != "" {
+		featureLocation, err := parseLocation(updated.Location)
+		if err == nil && featureLocation.isMinusStrand() != (strand == "-") {
+			featureLocation.Complement = !featureLocation.Complement
+			updated.setLocation(featureLocation)
+		}
+	}
+	return updated
+}
+
+// WithType returns a copy of a feature with its Type changed to featureType.
+func (feature Feature) WithType(featureType string) Feature {
+	updated := feature.clone()
+	updated.Type = featureType
+	return updated
+}
+
+// WithAttribute returns a copy of a feature with the attribute key set to value, replacing every value it had before.
+func (feature Feature) WithAttribute(key, value string) Feature {
+	updated := feature.clone()
+	if updated.Attributes == nil {
+		updated.Attributes = make(map[string]string)
+	}
+	updated.Attributes[key] = value
+	delete(updated.RepeatedQualifiers, key)
+	return updated
+}
+
+// returns a deep copy of a feature, so changing the copy's maps or pointers can't change the original.
+func (feature Feature) clone() Feature {
+	feature.Attributes = copyAttributes(feature.Attributes)
+	if feature.RepeatedQualifiers != nil {
+		repeatedQualifiers := make(map[string][]string, len(feature.RepeatedQualifiers))
+		for key, values := range feature.RepeatedQualifiers {
+			repeatedQualifiers[key] = append([]string(nil), values...)
+		}
+		feature.RepeatedQualifiers = repeatedQualifiers
+	}
+	if feature.FlagQualifiers != nil {
+		flags := make(map[string]bool, len(feature.FlagQualifiers))
+		for key := range feature.FlagQualifiers {
+			flags[key] = true
+		}
+		feature.FlagQualifiers = flags
+	}
+	if feature.Provenance != nil {
+		provenance := feature.Provenance.clone()
+		feature.Provenance = &provenance
+	}
+	if feature.Target != nil {
+		target := *feature.Target
+		feature.Target = &target
+	}
+	return feature
+}
+
+// returns a deep copy of a provenance, so features stamped with it don't share its Parameters or Timestamp.
+func (provenance Provenance) clone() Provenance {
+	provenance.Parameters = copyAttributes(provenance.Parameters)
+	if provenance.Timestamp != nil {
+		timestamp := *provenance.Timestamp
+		provenance.Timestamp = &timestamp
+	}
+	return provenance
+}
+
+/******************************************************************************
+
+Feature updating related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Repeat and gap finding related things begin here.
+
+******************************************************************************/
+
+// maxTandemRepeatUnit is the longest repeat unit FindTandemRepeats searches for. Capping it keeps the search linear in the
+// length of the sequence so it can run over whole genomes.
+const maxTandemRepeatUnit = 100
+
+// FindTandemRepeats finds exact tandem repeats of a unit at least minUnit bases long repeated at least minCopies times
+// back to back, like the microsatellite CACACACA. Each is returned as a repeat_region feature covering the whole copies,
+// with the unit in /rpt_unit_seq and /rpt_type set to tandem. Units are always reported in their shortest form, so ATATATAT
+// is reported once with a unit of AT rather than also as ATAT, and units longer than maxTandemRepeatUnit or containing
+// anything other than A, C, G, or T aren't searched for. Matching is case insensitive and features are sorted by start.
+func FindTandemRepeats(sequence string, minUnit, minCopies int) []Feature {
+	sequence = strings.ToUpper(sequence)
+	minUnit, minCopies = maxInt(minUnit, 1), maxInt(minCopies, 2)
+
+	type tandemRepeat struct{ start, end, unit int }
+	var repeats []tandemRepeat
+	for unit := minUnit; unit <= minInt(maxTandemRepeatUnit, len(sequence)/minCopies); unit++ {
+		// every base that matches the base one unit further on extends the repeat by a base.
+		runStart := 0
+		for position := 0; position <= len(sequence)-unit; position++ {
+			if position+unit < len(sequence) && sequence[position] == sequence[position+unit] {
+				continue
+			}
+			copies := (position - runStart + unit) / unit
+			repeatUnit := sequence[runStart : runStart+unit]
+			if copies >= minCopies && strings.Trim(repeatUnit, "ACGT") == "" && isPrimitiveUnit(repeatUnit) {
+				repeats = append(repeats, tandemRepeat{start: runStart, end: runStart + copies*unit, unit: unit})
+			}
+			runStart = position + 1
+		}
+	}
+	sort.SliceStable(repeats, func(i, j int) bool { return repeats[i].start < repeats[j].start })
+
+	features := make([]Feature, len(repeats))
+	for index, repeat := range repeats {
+		features[index] = Feature{
+			Type: "repeat_region",
+			Attributes: map[string]string{
+				"rpt_type":     "tandem",
+				"rpt_unit_seq": sequence[repeat.start : repeat.start+repeat.unit],
+			},
+		}
+		features[index].setLocation(Location{Start: repeat.start + 1, End: repeat.end})
+	}
+	return features
+}
+
+// reports whether a repeat unit isn't itself made of a shorter unit repeated, like ATAT is of AT.
+func isPrimitiveUnit(unit string) bool {
+	for period := 1; period < len(unit); period++ {
+		if len(unit)%period == 0 && strings.Repeat(unit[:period], len(unit)/period) == unit {
+			return false
+		}
+	}
+	return true
+}
+
+// FindInvertedRepeats finds stems of at least minStem bases followed within maxLoop bases by their reverse complement,
+// which can fold into hairpins like rho independent transcription terminators. Perfect palindromes like the EcoRI site
+// GAATTC are stems with a loop of 0. Each hairpin is returned once as a stem_loop feature spanning both halves of the stem,
+// with its stem extended as far as the bases keep pairing and so its loop as short as possible. Matching is case insensitive
+// and only A, C, G, and T pair. Features are sorted by start.
+func FindInvertedRepeats(sequence string, minStem, maxLoop int) []Feature {
+	sequence = strings.ToUpper(sequence)
+	minStem = maxInt(minStem, 1)
+
+	var features []Feature
+	for loopStart := 1; loopStart < len(sequence); loopStart++ {
+		for loop := 0; loop <= maxLoop && loopStart+loop < len(sequence); loop++ {
+			// if the ends of the loop pair then the same hairpin has a longer stem with a shorter loop.
+			if loop >= 2 && basesPair(sequence[loopStart], sequence[loopStart+loop-1]) {
+				continue
+			}
+			stem := 0
+			for loopStart-stem-1 >= 0 && loopStart+loop+stem < len(sequence) && basesPair(sequence[loopStart-stem-1], sequence[loopStart+loop+stem]) {
+				stem++
+			}
+			if stem < minStem {
+				continue
+			}
+			stemLoop := Feature{
+				Type:       "stem_loop",
+				Attributes: map[string]string{"note": fmt.Sprintf("%d base stem with a %d base loop", stem, loop)},
+			}
+			stemLoop.setLocation(Location{Start: loopStart - stem + 1, End: loopStart + loop + stem})
+			features = append(features, stemLoop)
+		}
+	}
+	sort.SliceStable(features, func(i, j int) bool { return features[i].Start < features[j].Start })
+	return features
+}
+
+// reports whether two uppercase bases are Watson-Crick complements.
+func basesPair(first, second byte) bool {
+	complement, ok := complementBaseRuneMap[rune(first)]
+	return ok && complement == rune(second)
+}
+
+// GapFeatures returns a gap feature for every run of at least minRun Ns (or ns) in the sequence, like the scaffold gaps
+// left in a draft assembly, with /estimated_length set to the length of the run. Gap is used rather than assembly_gap
+// since assembly_gap also requires the gap type and linkage evidence, which can't be known from the sequence alone.
+func (annotatedSequence AnnotatedSequence) GapFeatures(minRun int) []Feature {
+	minRun = maxInt(minRun, 1)
+	sequence := annotatedSequence.Sequence.Sequence
+	offset := annotatedSequence.regionOffset()
+
+	var gaps []Feature
+	runStart := -1
+	for position := 0; position <= len(sequence); position++ {
+		isN := position < len(sequence) && (sequence[position] == 'N' || sequence[position] == 'n')
+		if isN && runStart == -1 {
+			runStart = position
+		}
+		if isN || runStart == -1 {
+			continue
+		}
+		if runLength := position - runStart; runLength >= minRun {
+			gap := Feature{Type: "gap", Attributes: map[string]string{"estimated_length": strconv.Itoa(runLength)}}
+			gap.setLocation(Location{Start: offset + runStart + 1, End: offset + position})
+			gaps = append(gaps, gap)
+		}
+		runStart = -1
+	}
+	return gaps
+}
+
+/******************************************************************************
+
+Repeat and gap finding related things end here.
+
+******************************************************************************/
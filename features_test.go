@@ -0,0 +1,931 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQualifierKeys(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Features: []Feature{
+			{Type: "gene", Attributes: map[string]string{"gene": "thrL", "locus_tag": "b0001"}},
+			{Type: "CDS", Attributes: map[string]string{"gene": "thrL", "product": "thr operon leader peptide"}},
+			{Type: "misc_feature"},
+		},
+	}
+
+	expected := []string{"gene", "locus_tag", "product"}
+	if diff := cmp.Diff(expected, annotatedSequence.QualifierKeys()); diff != "" {
+		t.Errorf("QualifierKeys() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestUniquifyIDs(t *testing.T) {
+	record := AnnotatedSequence{
+		Meta: Meta{Name: "chr1"},
+		Features: []Feature{
+			{Type: "mRNA", Attributes: map[string]string{"ID": "mrna1", "Parent": "gene1"}},
+			{Type: "gene", Attributes: map[string]string{"ID": "gene1"}},
+			{Type: "exon", Attributes: map[string]string{"Parent": "mrna1,mrna2"}},
+		},
+	}
+
+	uniquified := UniquifyIDs([]AnnotatedSequence{record, record})
+
+	first := uniquified[0].Features
+	second := uniquified[1].Features
+	if first[1].Attributes["ID"] == second[1].Attributes["ID"] {
+		t.Errorf("UniquifyIDs() did not make IDs unique across records. Both are %q", first[1].Attributes["ID"])
+	}
+	if first[0].Attributes["Parent"] != first[1].Attributes["ID"] {
+		t.Errorf("UniquifyIDs() broke the parent link. Parent is %q but gene ID is %q", first[0].Attributes["Parent"], first[1].Attributes["ID"])
+	}
+	if first[2].Attributes["Parent"] != "chr1_1_mrna1,mrna2" {
+		t.Errorf("UniquifyIDs() should remap only the parents in a multi-parent list whose IDs are in the record. Got %q", first[2].Attributes["Parent"])
+	}
+	if record.Features[1].Attributes["ID"] != "gene1" {
+		t.Errorf("UniquifyIDs() mutated its input")
+	}
+}
+
+func TestFeatureContains(t *testing.T) {
+	joinedFeature := Feature{Type: "CDS", Location: "complement(join(10..20,30..40))"}
+	for position, expected := range map[int]bool{9: false, 10: true, 20: true, 25: false, 30: true, 40: true, 41: false} {
+		if joinedFeature.Contains(position) != expected {
+			t.Errorf("Contains(%d) on %s expected %t", position, joinedFeature.Location, expected)
+		}
+	}
+
+	gffFeature := Feature{Type: "gene", Start: 5, End: 7, Strand: "+"}
+	if !gffFeature.Contains(5) || !gffFeature.Contains(7) || gffFeature.Contains(8) {
+		t.Errorf("Contains() did not use inclusive bounds for a gff feature")
+	}
+}
+
+func TestTranslateFeatureCodonStart(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{Sequence: Sequence{Sequence: "GGATGAAATAA"}}
+	minusStrand := AnnotatedSequence{Sequence: Sequence{Sequence: "TTATTTCATCC"}}
+
+	tests := []struct {
+		annotatedSequence AnnotatedSequence
+		feature           Feature
+	}{
+		{annotatedSequence, Feature{Type: "CDS", Location: "3..11"}},
+		{annotatedSequence, Feature{Type: "CDS", Location: "2..11", Attributes: map[string]string{"codon_start": "2"}}},
+		{annotatedSequence, Feature{Type: "CDS", Location: "<1..11", Attributes: map[string]string{"codon_start": "3"}}},
+		{minusStrand, Feature{Type: "CDS", Location: "complement(1..>11)", Attributes: map[string]string{"codon_start": "3"}}},
+	}
+	for _, test := range tests {
+		protein, err := test.annotatedSequence.TranslateFeature(test.feature, 11)
+		if err != nil {
+			t.Errorf("TranslateFeature() on %s returned an unexpected error: %s", test.feature.Location, err)
+			continue
+		}
+		if protein != "MK*" {
+			t.Errorf("TranslateFeature() on %s expected MK*. Got %s", test.feature.Location, protein)
+		}
+	}
+
+	badFrame := Feature{Type: "CDS", Location: "1..11", Attributes: map[string]string{"codon_start": "4"}}
+	if _, err := annotatedSequence.CodingSequence(badFrame); err == nil {
+		t.Errorf("CodingSequence() should return an error for codon_start 4")
+	}
+}
+
+func TestDistanceMatrix(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: strings.Repeat("A", 100)},
+		Features: []Feature{
+			{Type: "gene", Location: "1..10"},
+			{Type: "CDS", Location: "5..8"},
+			{Type: "gene", Location: "complement(15..20)"},
+			{Type: "gene", Start: 90, End: 99, Strand: "+"},
+		},
+	}
+
+	linear := [][]int{
+		{0, 4, 79},
+		{4, 0, 69},
+		{79, 69, 0},
+	}
+	if diff := cmp.Diff(linear, annotatedSequence.DistanceMatrix("gene")); diff != "" {
+		t.Errorf("DistanceMatrix() mismatch on a linear sequence (-want +got):\n%s", diff)
+	}
+
+	annotatedSequence.Meta.Locus.Circular = true
+	circular := [][]int{
+		{0, 4, 1},
+		{4, 0, 15},
+		{1, 15, 0},
+	}
+	if diff := cmp.Diff(circular, annotatedSequence.DistanceMatrix("gene")); diff != "" {
+		t.Errorf("DistanceMatrix() mismatch on a circular sequence (-want +got):\n%s", diff)
+	}
+}
+
+func TestLengthDistribution(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: strings.Repeat("A", 100)},
+		Features: []Feature{
+			{Type: "CDS", Location: "1..30"},
+			{Type: "gene", Location: "1..40"},
+			{Type: "CDS", Location: "complement(join(41..50,61..>72))"},
+			{Type: "CDS", Start: 91, End: 9, Strand: "+"},
+			{Type: "CDS", Location: "join(1..)"},
+			{Type: "CDS", Start: UndefinedCoordinate, End: 6, Strand: "+"},
+		},
+	}
+
+	if diff := cmp.Diff([]int{30, 22}, annotatedSequence.LengthDistribution("CDS")); diff != "" {
+		t.Errorf("LengthDistribution() mismatch on a linear sequence (-want +got):\n%s", diff)
+	}
+
+	annotatedSequence.Meta.Locus.Circular = true
+	if diff := cmp.Diff([]int{30, 22, 19}, annotatedSequence.LengthDistribution("CDS")); diff != "" {
+		t.Errorf("LengthDistribution() mismatch on a circular sequence (-want +got):\n%s", diff)
+	}
+
+	if length := annotatedSequence.Features[3].Length(); length != 0 {
+		t.Errorf("Length() of a feature wrapping around the origin expected 0 since the sequence length isn't known. Got %d", length)
+	}
+}
+
+func TestFeatureSequenceEstimatedGapLength(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{Sequence: Sequence{Sequence: "ACGTNNNNNNNNNNACGT"}}
+
+	tests := []struct {
+		feature  Feature
+		expected string
+	}{
+		{Feature{Type: "assembly_gap", Location: "5..14", Attributes: map[string]string{"estimated_length": "25", "gap_type": "within scaffold"}}, strings.Repeat("N", 25)},
+		{Feature{Type: "assembly_gap", Location: "5..14", Attributes: map[string]string{"estimated_length": "unknown"}}, strings.Repeat("N", 10)},
+		{Feature{Type: "gap", Location: "5..14", Attributes: map[string]string{"estimated_length": "3"}}, "NNN"},
+		{Feature{Type: "misc_feature", Location: "1..4", Attributes: map[string]string{"estimated_length": "3"}}, "ACGT"},
+	}
+	for _, test := range tests {
+		sequence, err := annotatedSequence.FeatureSequence(test.feature)
+		if err != nil {
+			t.Errorf("FeatureSequence() on a %s returned an unexpected error: %s", test.feature.Type, err)
+			continue
+		}
+		if sequence != test.expected {
+			t.Errorf("FeatureSequence() on a %s with estimated_length %s expected %q. Got %q", test.feature.Type, test.feature.Attributes["estimated_length"], test.expected, sequence)
+		}
+	}
+
+	badGap := Feature{Type: "assembly_gap", Location: "5..14", Attributes: map[string]string{"estimated_length": "lots"}}
+	if _, err := annotatedSequence.FeatureSequence(badGap); err == nil {
+		t.Errorf("FeatureSequence() should return an error for a non-numeric estimated_length")
+	}
+}
+
+func TestStampProvenance(t *testing.T) {
+	annotatedSequence := ReadGbk("data/bsub.gbk")
+	if annotatedSequence.Features[0].Provenance == nil || annotatedSequence.Features[0].Provenance.Tool != "ParseGbk" || annotatedSequence.Features[0].Provenance.Timestamp != nil {
+		t.Errorf("ParseGbk() expected features to have provenance with Tool ParseGbk and no Timestamp. Got %+v", annotatedSequence.Features[0].Provenance)
+	}
+	gff := ReadGff("data/ecoli-mg1655.gff")
+	if gff.Features[0].Provenance == nil || gff.Features[0].Provenance.Tool != "ParseGff" || gff.Features[0].Provenance.Timestamp != nil {
+		t.Errorf("ParseGff() expected features to have provenance with Tool ParseGff and no Timestamp. Got %+v", gff.Features[0].Provenance)
+	}
+	if gff.Features[0].Provenance == gff.Features[1].Provenance {
+		t.Errorf("ParseGff() shared provenance between features")
+	}
+
+	timestamp := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	provenance := Provenance{
+		Tool:       "prodigal",
+		Timestamp:  &timestamp,
+		Parameters: map[string]string{"mode": "single"},
+	}
+	stamped := annotatedSequence.StampProvenance(provenance)
+	stamped.Features[0].Provenance.Parameters["mode"] = "meta"
+	if stamped.Features[1].Provenance.Parameters["mode"] != "single" || provenance.Parameters["mode"] != "single" {
+		t.Errorf("StampProvenance() shared parameters between features")
+	}
+	if annotatedSequence.Features[0].Provenance.Tool != "ParseGbk" {
+		t.Errorf("StampProvenance() mutated its input")
+	}
+	if stamped.Features[0].Provenance.Timestamp == stamped.Features[1].Provenance.Timestamp || !stamped.Features[0].Provenance.Timestamp.Equal(timestamp) {
+		t.Errorf("StampProvenance() expected every feature to get its own copy of the timestamp")
+	}
+
+	testOutputPath := "data/test_provenance.json"
+	WriteJSON(stamped, testOutputPath)
+	defer os.Remove(testOutputPath)
+	if diff := cmp.Diff(stamped.Features, ReadJSON(testOutputPath).Features); diff != "" {
+		t.Errorf("Provenance did not survive a json round trip (-want +got):\n%s", diff)
+	}
+}
+
+func TestFeatureIndexQuery(t *testing.T) {
+	annotatedSequence := ReadGbk("data/bsub.gbk")
+	index := BuildFeatureIndex(annotatedSequence)
+
+	// every query should match a scan over every feature.
+	for _, queryRange := range [][2]int{{1, 1}, {410, 1750}, {100000, 105000}, {4215000, 4300000}, {5000000, 5000001}} {
+		var expected []Feature
+		for _, feature := range annotatedSequence.Features {
+			featureLocation, err := feature.location()
+			if err == nil && featureLocation.Start <= queryRange[1] && featureLocation.End >= queryRange[0] {
+				expected = append(expected, feature)
+			}
+		}
+		sort.SliceStable(expected, func(i, j int) bool { return expected[i].Start < expected[j].Start })
+		queried := index.Query(queryRange[0], queryRange[1])
+		if len(queried) != len(expected) {
+			t.Errorf("Query(%d, %d) expected %d features. Got %d", queryRange[0], queryRange[1], len(expected), len(queried))
+			continue
+		}
+		for featureIndex := range queried {
+			if queried[featureIndex].Location != expected[featureIndex].Location {
+				t.Errorf("Query(%d, %d) feature %d expected %s. Got %s", queryRange[0], queryRange[1], featureIndex, expected[featureIndex].Location, queried[featureIndex].Location)
+				break
+			}
+		}
+	}
+
+	if queried := BuildFeatureIndex(AnnotatedSequence{}).Query(1, 100); queried != nil {
+		t.Errorf("Query() on an index of an empty record expected nil. Got %v", queried)
+	}
+	unparseable := AnnotatedSequence{Features: []Feature{{Type: "gene", Location: "not a location"}, {Type: "gene", Start: UndefinedCoordinate, End: 10}}}
+	if queried := BuildFeatureIndex(unparseable).Query(1, 100); queried != nil {
+		t.Errorf("Query() on an index without any parseable locations expected nil. Got %v", queried)
+	}
+}
+
+// the full length source feature overlaps every query, which shouldn't make each query scan every feature before it.
+func BenchmarkFeatureIndexQuery(b *testing.B) {
+	index := BuildFeatureIndex(ReadGbk("data/bsub.gbk"))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		index.Query(4000000, 4001000)
+	}
+}
+
+// run with -race to check that concurrent queries don't race.
+func TestFeatureIndexConcurrentQuery(t *testing.T) {
+	index := BuildFeatureIndex(ReadGbk("data/bsub.gbk"))
+	expected := len(index.Query(100000, 200000))
+
+	var wg sync.WaitGroup
+	for goroutine := 0; goroutine < 32; goroutine++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for query := 0; query < 100; query++ {
+				if queried := index.Query(100000, 200000); len(queried) != expected {
+					t.Errorf("concurrent Query() expected %d features. Got %d", expected, len(queried))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCountOverlaps(t *testing.T) {
+	genes := []Feature{
+		{Type: "gene", Location: "join(1..100,200..300)"},
+		{Name: "chr1", Type: "gene", Start: 400, End: 500, Strand: "-"},
+		{Type: "gene", Location: "1000..1100"},
+		{Type: "gene", Location: "not a location"},
+	}
+	regulatory := []Feature{
+		{Type: "regulatory", Location: "90..95"},
+		{Type: "regulatory", Location: "complement(120..180)"},
+		{Type: "regulatory", Location: "290..410"},
+		{Name: "chr1", Type: "regulatory", Start: 450, End: 460, Strand: "+"},
+		{Name: "chr2", Type: "regulatory", Start: 450, End: 460, Strand: "+"},
+	}
+	expected := map[int]int{0: 2, 1: 2, 2: 0, 3: 0}
+	if diff := cmp.Diff(expected, CountOverlaps(genes, regulatory)); diff != "" {
+		t.Errorf("CountOverlaps() mismatch (-want +got):\n%s", diff)
+	}
+
+	none := map[int]int{0: 0, 1: 0, 2: 0, 3: 0}
+	if diff := cmp.Diff(none, CountOverlaps(genes, nil)); diff != "" {
+		t.Errorf("CountOverlaps() against no features mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(none, CountOverlaps(genes, []Feature{{Type: "regulatory", Location: "not a location"}})); diff != "" {
+		t.Errorf("CountOverlaps() against features without parseable locations mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// counting a genome against itself includes the full length source feature in b, which every feature of a overlaps.
+func BenchmarkCountOverlaps(b *testing.B) {
+	features := ReadGbk("data/bsub.gbk").Features
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		CountOverlaps(features, features)
+	}
+}
+
+func TestCoverageDepth(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: "ACGTACGTAC"},
+		Features: []Feature{
+			{Type: "CDS", Location: "join(1..3,6..8)"},
+			{Type: "CDS", Location: "complement(2..7)"},
+			{Type: "CDS", Start: 8, End: 12, Strand: "+"}, // runs off the end.
+			{Type: "CDS", Start: UndefinedCoordinate, End: 6, Strand: "+"},
+			{Type: "gene", Location: "1..10"},
+		},
+	}
+
+	expected := []int{1, 2, 2, 1, 1, 2, 2, 2, 1, 1}
+	if diff := cmp.Diff(expected, annotatedSequence.CoverageDepth("CDS")); diff != "" {
+		t.Errorf("CoverageDepth() mismatch (-want +got):\n%s", diff)
+	}
+
+	region := AnnotatedSequence{
+		Meta:     Meta{RegionStart: 1000},
+		Sequence: Sequence{Sequence: "ACGTACGT"},
+		Features: []Feature{{Type: "gene", Start: 1002, End: 1004, Strand: "+"}},
+	}
+	if diff := cmp.Diff([]int{0, 0, 1, 1, 1, 0, 0, 0}, region.CoverageDepth("gene")); diff != "" {
+		t.Errorf("CoverageDepth() mismatch on a region of a larger contig (-want +got):\n%s", diff)
+	}
+}
+
+func TestIntervalCoverage(t *testing.T) {
+	intervals := [][2]int{{1, 4}, {3, 6}, {6, 6}, {-2, 1}, {5, 20}, {9, 8}, {UndefinedCoordinate, 3}, {7, UndefinedCoordinate}}
+	expected := []int{2, 1, 2, 2, 2, 3, 1, 1}
+	if diff := cmp.Diff(expected, IntervalCoverage(intervals, 8)); diff != "" {
+		t.Errorf("IntervalCoverage() mismatch (-want +got):\n%s", diff)
+	}
+	if coverage := IntervalCoverage(nil, 3); len(coverage) != 3 || coverage[0]+coverage[1]+coverage[2] != 0 {
+		t.Errorf("IntervalCoverage() with no intervals expected [0 0 0]. Got %v", coverage)
+	}
+}
+
+func TestIsPseudo(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: "ATGAAATAAGGG"},
+		Features: []Feature{
+			{Type: "CDS", Location: "1..9", Attributes: map[string]string{}},
+			{Type: "CDS", Location: "1..10", Attributes: map[string]string{"pseudo": ""}},
+			{Type: "CDS", Location: "4..12", Attributes: map[string]string{"pseudogene": "unprocessed"}},
+			{Type: "CDS", Start: 1, End: 9, Strand: "+", Attributes: map[string]string{"pseudo": "false"}},
+		},
+	}
+
+	for featureIndex, expected := range []bool{false, true, true, false} {
+		if annotatedSequence.Features[featureIndex].IsPseudo() != expected {
+			t.Errorf("IsPseudo() on feature %d expected %t", featureIndex, expected)
+		}
+	}
+
+	if _, err := annotatedSequence.TranslateFeature(annotatedSequence.Features[1], 11); !errors.Is(err, ErrPseudo) {
+		t.Errorf("TranslateFeature() on a pseudogene expected ErrPseudo. Got %v", err)
+	}
+	if protein, err := annotatedSequence.TranslateFeature(annotatedSequence.Features[3], 11); err != nil || protein != "MK*" {
+		t.Errorf("TranslateFeature() expected MK*. Got %q, %v", protein, err)
+	}
+	if errs := annotatedSequence.CheckStartStopCodons(11); len(errs) != 0 {
+		t.Errorf("CheckStartStopCodons() should skip pseudogenes. Got %v", errs)
+	}
+	if frameErrors := annotatedSequence.CDSFrameErrors(); len(frameErrors) != 0 {
+		t.Errorf("CDSFrameErrors() should skip pseudogenes. Got %v", frameErrors)
+	}
+}
+
+func TestTranslateAll(t *testing.T) {
+	annotatedSequence := ReadGbk("data/bsub.gbk")
+	proteins, err := annotatedSequence.TranslateAll(11, 8)
+	if err != nil {
+		t.Fatalf("TranslateAll() returned an unexpected error: %s", err)
+	}
+
+	serialProteins, err := annotatedSequence.TranslateAll(11, 1)
+	if err != nil {
+		t.Fatalf("TranslateAll() with one worker returned an unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(serialProteins, proteins); diff != "" {
+		t.Errorf("TranslateAll() with 8 workers doesn't match 1 worker (-want +got):\n%s", diff)
+	}
+
+	// translations should agree with the annotated /translation, which leaves out the stop codon and has alternative start
+	// codons like TTG as M.
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != "CDS" || feature.IsPseudo() || feature.Attributes["transl_except"] != "" {
+			continue
+		}
+		protein := strings.TrimSuffix(proteins[feature.Attributes["locus_tag"]], "*")
+		translation := feature.Attributes["translation"]
+		if protein != translation {
+			t.Errorf("TranslateAll() translation of %s doesn't match its /translation", feature.Attributes["locus_tag"])
+			break
+		}
+	}
+
+	// with several CDS that can't be translated, the error is always that of the first one.
+	broken := AnnotatedSequence{Sequence: Sequence{Sequence: strings.Repeat("ATGAAATAA", 20)}}
+	for featureIndex := 0; featureIndex < 20; featureIndex++ {
+		location := strconv.Itoa(featureIndex*9+1) + ".." + strconv.Itoa(featureIndex*9+9)
+		if featureIndex >= 5 && featureIndex%3 == 2 {
+			location = "not a location"
+		}
+		broken.Features = append(broken.Features, Feature{Type: "CDS", Location: location, Attributes: map[string]string{"locus_tag": "b" + strconv.Itoa(featureIndex)}})
+	}
+	for attempt := 0; attempt < 20; attempt++ {
+		if _, err := broken.TranslateAll(11, 4); err == nil || !strings.Contains(err.Error(), "b5:") {
+			t.Fatalf("TranslateAll() expected the error of b5, the first CDS that can't be translated. Got %v", err)
+		}
+	}
+	duplicated := AnnotatedSequence{Sequence: broken.Sequence, Features: []Feature{broken.Features[0], broken.Features[0]}}
+	if _, err := duplicated.TranslateAll(11, 4); err == nil {
+		t.Errorf("TranslateAll() expected an error for two CDS with the same identifier")
+	}
+}
+
+func BenchmarkTranslateAll(b *testing.B) {
+	annotatedSequence := ReadGbk("data/bsub.gbk")
+	for _, workers := range []int{1, runtime.NumCPU()} {
+		b.Run("workers="+strconv.Itoa(workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = annotatedSequence.TranslateAll(11, workers)
+			}
+		})
+	}
+}
+
+func TestConcatenatedCDS(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: "ATGAAATAAGGGTTATTTCATCC"},
+		Features: []Feature{
+			{Type: "CDS", Location: "1..9"}, // ATGAAA + stop.
+			{Type: "CDS", Location: "complement(13..23)", Attributes: map[string]string{"codon_start": "3"}}, // ATGAAA + stop.
+			{Type: "CDS", Location: "1..8"}, // ATGAAA, the partial codon TA is dropped.
+			{Type: "CDS", Location: "1..9", Attributes: map[string]string{"pseudo": ""}},
+			{Type: "gene", Location: "1..9"},
+		},
+	}
+
+	if concatenated := annotatedSequence.ConcatenatedCDS(11); concatenated != "ATGAAAATGAAAATGAAA" {
+		t.Errorf("ConcatenatedCDS() expected ATGAAAATGAAAATGAAA. Got %s", concatenated)
+	}
+	if concatenated := annotatedSequence.ConcatenatedCDSWithOptions(11, CDSOptions{}); concatenated != "ATGAAAATGAAAATGAAA" {
+		t.Errorf("ConcatenatedCDSWithOptions() should remove stop codons by default. Got %s", concatenated)
+	}
+	if concatenated := annotatedSequence.ConcatenatedCDSWithOptions(11, CDSOptions{KeepStopCodons: true}); concatenated != "ATGAAATAAATGAAATAAATGAAA" {
+		t.Errorf("ConcatenatedCDSWithOptions() expected ATGAAATAAATGAAATAAATGAAA with stop codons kept. Got %s", concatenated)
+	}
+}
+
+func TestLongestIsoforms(t *testing.T) {
+	gff := "##gff-version 3\n##sequence-region chr1 1 1000\n" +
+		"chr1\tfeature\tgene\t1\t1000\t.\t+\t.\tID=gene1\n" +
+		"chr1\tfeature\tmRNA\t1\t1000\t.\t+\t.\tID=mrna1;Parent=gene1\n" +
+		"chr1\tfeature\tCDS\t1\t100\t.\t+\t0\tID=cds1;Parent=mrna1\n" +
+		"chr1\tfeature\tCDS\t201\t300\t.\t+\t2\tID=cds1;Parent=mrna1\n" +
+		"chr1\tfeature\tmRNA\t1\t1000\t.\t+\t.\tID=mrna2;Parent=gene1\n" +
+		"chr1\tfeature\tCDS\t1\t150\t.\t+\t0\tID=cds2;Parent=mrna2\n" +
+		"chr1\tfeature\texon\t1\t1000\t.\t+\t.\tParent=mrna2\n" +
+		"chr1\tfeature\tgene\t500\t600\t.\t+\t.\tID=gene2\n" +
+		"chr1\tfeature\tncRNA\t500\t600\t.\t+\t.\tID=ncrna1;Parent=gene2\n" +
+		// NCBI's prokaryotic layout, where the CDS's Parent is the gene and there's no mRNA.
+		"chr1\tfeature\tgene\t700\t900\t.\t-\t.\tID=gene3\n" +
+		"chr1\tfeature\tCDS\t700\t900\t.\t-\t0\tID=cds3;Parent=gene3\n"
+	annotatedSequence, _ := ParseGff(gff)
+	longestIsoforms := annotatedSequence.LongestIsoforms()
+	if len(longestIsoforms) != 2 || longestIsoforms[0].Attributes["ID"] != "mrna1" || longestIsoforms[1].Attributes["ID"] != "cds3" {
+		t.Errorf("LongestIsoforms() expected mrna1 with a 200 base spliced CDS and gene3's own CDS. Got %+v", longestIsoforms)
+	}
+
+	genbankIsoforms := AnnotatedSequence{Features: []Feature{
+		{Type: "CDS", Location: "join(1..100,201..250)", Attributes: map[string]string{"gene": "abc", "product": "short"}},
+		{Type: "CDS", Location: "join(1..100,201..300)", Attributes: map[string]string{"gene": "abc", "product": "long"}},
+		{Type: "CDS", Location: "400..500", Attributes: map[string]string{"locus_tag": "b0002"}},
+		{Type: "CDS", Location: "600..700", Attributes: map[string]string{"product": "unnamed"}},
+		{Type: "CDS", Location: "800..1000", Attributes: map[string]string{"product": "also unnamed"}},
+	}}.LongestIsoforms()
+	if len(genbankIsoforms) != 4 || genbankIsoforms[0].Attributes["product"] != "long" || genbankIsoforms[1].Attributes["locus_tag"] != "b0002" ||
+		genbankIsoforms[2].Location != "600..700" || genbankIsoforms[3].Location != "800..1000" {
+		t.Errorf("LongestIsoforms() on genbank features expected the long abc CDS, b0002, and both unnamed CDSs. Got %+v", genbankIsoforms)
+	}
+}
+
+func TestOverlappingGenePairs(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Features: []Feature{
+			{Type: "gene", Location: "1..100", Attributes: map[string]string{"locus_tag": "a"}},
+			{Type: "gene", Location: "complement(90..200)", Attributes: map[string]string{"locus_tag": "b"}},
+			{Type: "gene", Location: "150..300", Attributes: map[string]string{"locus_tag": "c"}},
+			{Type: "CDS", Location: "1..300"}, // not a gene.
+			{Type: "gene", Location: "join(250..260,400..500)", Attributes: map[string]string{"locus_tag": "d"}}, // overlaps c.
+			{Type: "gene", Location: "complement(270..390)", Attributes: map[string]string{"locus_tag": "e"}},    // in d's intron.
+		},
+	}
+
+	var got []string
+	for _, pair := range annotatedSequence.OverlappingGenePairs(false) {
+		got = append(got, pair[0].Attributes["locus_tag"]+pair[1].Attributes["locus_tag"])
+	}
+	if diff := cmp.Diff([]string{"ab", "bc", "cd", "ce"}, got); diff != "" {
+		t.Errorf("OverlappingGenePairs(false) returned the wrong pairs. Diff: %s", diff)
+	}
+
+	got = nil
+	for _, pair := range annotatedSequence.OverlappingGenePairs(true) {
+		got = append(got, pair[0].Attributes["locus_tag"]+pair[1].Attributes["locus_tag"])
+	}
+	if diff := cmp.Diff([]string{"ab", "bc", "ce"}, got); diff != "" {
+		t.Errorf("OverlappingGenePairs(true) returned the wrong pairs. Diff: %s", diff)
+	}
+}
+
+func TestCodonPositionFrequencies(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: "ATGGCAtaaCCCATGAAGTGA"},
+		Features: []Feature{
+			{Type: "CDS", Location: "1..9"},
+			{Type: "CDS", Location: "13..21"},
+			{Type: "CDS", Location: "1..6", Attributes: map[string]string{"pseudo": "true"}},
+		},
+	}
+	expected := [3]map[rune]float64{
+		{'A': 0.75, 'G': 0.25},
+		{'T': 0.5, 'C': 0.25, 'A': 0.25},
+		{'G': 0.75, 'A': 0.25},
+	}
+	if diff := cmp.Diff(expected, annotatedSequence.CodonPositionFrequencies(11)); diff != "" {
+		t.Errorf("CodonPositionFrequencies() mismatch (-want +got):\n%s", diff)
+	}
+
+	frequencies := ReadGbk("data/bsub.gbk").CodonPositionFrequencies(11)
+	for position, positionFrequencies := range frequencies {
+		var total float64
+		for _, frequency := range positionFrequencies {
+			total += frequency
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Errorf("CodonPositionFrequencies() position %d frequencies sum to %f", position+1, total)
+		}
+	}
+	if empty := (AnnotatedSequence{}).CodonPositionFrequencies(11); len(empty[0]) != 0 {
+		t.Errorf("CodonPositionFrequencies() expected no frequencies without CDS. Got %v", empty)
+	}
+}
+
+func TestPromoters(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: "TTGACAATTAATCATCGGCTCGTATAATGTGTGG"},
+		Features: []Feature{
+			{Type: "regulatory", Location: "1..6", Attributes: map[string]string{"regulatory_class": "promoter"}},
+			{Type: "regulatory", Location: "13..18", Attributes: map[string]string{"regulatory_class": "terminator"}},
+			{Type: "promoter", Location: "complement(23..28)", Attributes: map[string]string{}},
+			{Type: "CDS", Location: "1..6", Attributes: map[string]string{"regulatory_class": "promoter"}},
+		},
+	}
+
+	promoters := annotatedSequence.Promoters()
+	if len(promoters) != 2 {
+		t.Fatalf("Promoters() expected 2 promoters. Got %d: %v", len(promoters), promoters)
+	}
+	if promoters[0].Sequence != "TTGACA" || promoters[1].Sequence != "ATTATA" {
+		t.Errorf("Promoters() expected sequences TTGACA and ATTATA. Got %s and %s", promoters[0].Sequence, promoters[1].Sequence)
+	}
+	if annotatedSequence.Features[0].Sequence != "" {
+		t.Errorf("Promoters() should not modify the features of the AnnotatedSequence")
+	}
+}
+
+func TestFindTandemRepeats(t *testing.T) {
+	//                  CACACACA      GATGATGAT    NNNNNN
+	sequence := "TTGCCACACACAGTCGGATGATGATGCTNNNNNNATG"
+	repeats := FindTandemRepeats(sequence, 2, 3)
+	var got []string
+	for _, repeat := range repeats {
+		got = append(got, repeat.Location+" "+repeat.Attributes["rpt_unit_seq"])
+	}
+	if diff := cmp.Diff([]string{"5..12 CA", "17..25 GAT"}, got); diff != "" {
+		t.Errorf("FindTandemRepeats() returned the wrong repeats. Diff: %s", diff)
+	}
+	if repeats[0].Type != "repeat_region" || repeats[0].Attributes["rpt_type"] != "tandem" {
+		t.Errorf("FindTandemRepeats() returned an unexpected feature: %+v", repeats[0])
+	}
+	if repeats[1].Start != 17 || repeats[1].End != 25 || repeats[1].Strand != "+" {
+		t.Errorf("FindTandemRepeats() expected gff coordinates 17 to 25 on the plus strand. Got %d, %d, %q", repeats[1].Start, repeats[1].End, repeats[1].Strand)
+	}
+
+	if repeats := FindTandemRepeats("atatatat", 1, 4); len(repeats) != 1 || repeats[0].Attributes["rpt_unit_seq"] != "AT" {
+		t.Errorf("FindTandemRepeats() should report ATATATAT once with a unit of AT. Got %+v", repeats)
+	}
+	if repeats := FindTandemRepeats("CACACACA", 2, 5); len(repeats) != 0 {
+		t.Errorf("FindTandemRepeats() should not report repeats with fewer than minCopies copies. Got %+v", repeats)
+	}
+}
+
+func TestFindInvertedRepeats(t *testing.T) {
+	//                 AGGCG TTTT CGCCT       GAATTC
+	sequence := "CCCCCAGGCGTTTTCGCCTCCCCCAGAATTCCCC"
+	var got []string
+	for _, stemLoop := range FindInvertedRepeats(sequence, 5, 4) {
+		got = append(got, stemLoop.Location+" "+stemLoop.Attributes["note"])
+	}
+	expected := []string{"6..19 5 base stem with a 4 base loop"}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("FindInvertedRepeats() returned the wrong stem loops. Diff: %s", diff)
+	}
+
+	if stemLoop := FindInvertedRepeats(sequence, 5, 4)[0]; stemLoop.Start != 6 || stemLoop.End != 19 || stemLoop.Strand != "+" {
+		t.Errorf("FindInvertedRepeats() expected gff coordinates 6 to 19 on the plus strand. Got %d, %d, %q", stemLoop.Start, stemLoop.End, stemLoop.Strand)
+	}
+
+	got = nil
+	for _, stemLoop := range FindInvertedRepeats(sequence, 3, 0) {
+		got = append(got, stemLoop.Location)
+	}
+	if diff := cmp.Diff([]string{"26..31"}, got); diff != "" {
+		t.Errorf("FindInvertedRepeats() should find the GAATTC palindrome. Diff: %s", diff)
+	}
+}
+
+func TestFeaturesByQualifierRegExp(t *testing.T) {
+	annotatedSequence := ReadGbk("data/bsub.gbk")
+	transposases, err := annotatedSequence.FeaturesByQualifierRegExp("product", "(?i)transposase")
+	if err != nil {
+		t.Fatalf("FeaturesByQualifierRegExp() returned an unexpected error: %s", err)
+	}
+	if len(transposases) != 2 {
+		t.Errorf("FeaturesByQualifierRegExp() expected 2 transposases. Got %d", len(transposases))
+	}
+	for _, feature := range transposases {
+		if !strings.Contains(strings.ToLower(feature.Attributes["product"]), "transposase") {
+			t.Errorf("FeaturesByQualifierRegExp() returned a feature without a transposase product: %+v", feature)
+		}
+	}
+
+	repeated := AnnotatedSequence{Features: []Feature{
+		{Type: "CDS", Attributes: map[string]string{"EC_number": "1.1.1.1"}, RepeatedQualifiers: map[string][]string{"EC_number": {"2.7.7.7"}}},
+		{Type: "CDS", Attributes: map[string]string{"EC_number": "3.1.1.1"}},
+	}}
+	matches, _ := repeated.FeaturesByQualifierRegExp("EC_number", `^2\.7\.`)
+	if len(matches) != 1 {
+		t.Errorf("FeaturesByQualifierRegExp() should match any value of a repeated qualifier. Got %+v", matches)
+	}
+
+	if _, err := annotatedSequence.FeaturesByQualifierRegExp("product", "(unclosed"); err == nil {
+		t.Errorf("FeaturesByQualifierRegExp() should return an error for a bad pattern")
+	}
+}
+
+func TestGapFeatures(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{Sequence: Sequence{Sequence: "NNATGCnnnnnATGNNATGNNNNNN"}}
+	var got []string
+	for _, gap := range annotatedSequence.GapFeatures(3) {
+		got = append(got, gap.Type+" "+gap.Location+" "+gap.Attributes["estimated_length"])
+	}
+	if diff := cmp.Diff([]string{"gap 7..11 5", "gap 20..25 6"}, got); diff != "" {
+		t.Errorf("GapFeatures() returned the wrong gaps. Diff: %s", diff)
+	}
+
+	gaps := annotatedSequence.GapFeatures(3)
+	if gapSequence, _ := annotatedSequence.FeatureSequence(gaps[0]); gapSequence != "NNNNN" {
+		t.Errorf("GapFeatures() returned a gap that doesn't cover its Ns. Got %s", gapSequence)
+	}
+	if gaps := annotatedSequence.GapFeatures(1); len(gaps) != 4 {
+		t.Errorf("GapFeatures(1) expected 4 gaps. Got %d", len(gaps))
+	}
+	if gaps[0].Start != 7 || gaps[0].End != 11 || gaps[0].Strand != "+" {
+		t.Errorf("GapFeatures() expected gff coordinates 7 to 11 on the plus strand. Got %d, %d, %q", gaps[0].Start, gaps[0].End, gaps[0].Strand)
+	}
+}
+
+func TestFeatureUpdaters(t *testing.T) {
+	original := Feature{
+		Type:               "CDS",
+		Location:           "join(1..3,5..8)",
+		Attributes:         map[string]string{"EC_number": "1.1.1.1", "product": "thing"},
+		RepeatedQualifiers: map[string][]string{"EC_number": {"2.7.7.7"}},
+		Provenance:         &Provenance{Tool: "ParseGbk"},
+	}
+
+	updated := original.WithType("gene").WithStrand("-").WithAttribute("EC_number", "3.1.1.1")
+	if updated.Type != "gene" || updated.Strand != "-" || updated.Location != "complement(join(1..3,5..8))" {
+		t.Errorf("updaters did not apply their changes. Got %+v", updated)
+	}
+	if values := updated.QualifierValues("EC_number"); len(values) != 1 || values[0] != "3.1.1.1" {
+		t.Errorf("WithAttribute() should replace every value of a repeated qualifier. Got %v", values)
+	}
+	if back := updated.WithStrand("+"); back.Location != "join(1..3,5..8)" {
+		t.Errorf("WithStrand() expected join(1..3,5..8) back on the plus strand. Got %s", back.Location)
+	}
+
+	updated.Provenance.Tool = "changed"
+	if original.Type != "CDS" || original.Location != "join(1..3,5..8)" || original.Attributes["EC_number"] != "1.1.1.1" ||
+		len(original.RepeatedQualifiers["EC_number"]) != 1 || original.Provenance.Tool != "ParseGbk" {
+		t.Errorf("updaters should not modify the original feature. Got %+v", original)
+	}
+
+	if gff := (Feature{Start: 1, End: 9, Strand: "+"}).WithStrand("-"); gff.Strand != "-" || gff.Location != "" {
+		t.Errorf("WithStrand() on a gff feature expected only its Strand to change. Got %+v", gff)
+	}
+	if attribute := (Feature{}).WithAttribute("ID", "cds0"); attribute.Attributes["ID"] != "cds0" {
+		t.Errorf("WithAttribute() should work on a feature without attributes")
+	}
+}
+
+func TestGeneIntervals(t *testing.T) {
+	gff := AnnotatedSequence{
+		Features: []Feature{
+			{Name: "chr1", Source: "maker", Type: "gene", Start: 10, End: 200, Strand: "-", Attributes: map[string]string{"ID": "gene1", "Name": "abc"}},
+			{Name: "chr1", Source: "maker", Type: "mRNA", Start: 10, End: 200, Strand: "-", Attributes: map[string]string{"ID": "mRNA1", "Parent": "gene1"}},
+			{Name: "chr1", Source: "maker", Type: "exon", Start: 10, End: 50, Strand: "-", Attributes: map[string]string{"Parent": "mRNA1"}},
+			{Name: "chr1", Source: "maker", Type: "CDS", Start: 30, End: 50, Strand: "-", Attributes: map[string]string{"Parent": "mRNA1"}},
+			{Name: "chr1", Source: "maker", Type: "CDS", Start: 100, End: 180, Strand: "-", Attributes: map[string]string{"Parent": "mRNA1"}},
+			{Name: "chr1", Source: "maker", Type: "CDS", Start: 300, End: 390, Strand: "+", Attributes: map[string]string{"Parent": "mRNA2"}},
+		},
+	}
+	expected := []Feature{
+		{Name: "chr1", Source: "maker", Type: "gene", Start: 10, End: 180, Score: ".", Strand: "-", Phase: ".", Attributes: map[string]string{"ID": "gene1", "Name": "abc"}},
+		{Name: "chr1", Source: "maker", Type: "gene", Start: 300, End: 390, Score: ".", Strand: "+", Phase: ".", Attributes: map[string]string{"ID": "mRNA2"}},
+	}
+	if diff := cmp.Diff(expected, gff.GeneIntervals()); diff != "" {
+		t.Errorf("GeneIntervals() of a gff mismatch (-want +got):\n%s", diff)
+	}
+
+	genbank := AnnotatedSequence{
+		Features: []Feature{
+			{Type: "CDS", Location: "complement(join(500..600,700..800))", Attributes: map[string]string{"gene": "xyz"}},
+			{Type: "CDS", Location: "complement(join(450..600,700..750))", Attributes: map[string]string{"gene": "xyz"}},
+			{Type: "CDS", Location: "1..90", Attributes: map[string]string{"locus_tag": "b0001"}},
+			{Type: "exon", Location: "900..950"},
+		},
+	}
+	expected = []Feature{
+		{Type: "gene", Location: "complement(450..800)", Start: 450, End: 800, Strand: "-", Attributes: map[string]string{"gene": "xyz"}},
+		{Type: "gene", Location: "1..90", Start: 1, End: 90, Strand: "+", Attributes: map[string]string{"locus_tag": "b0001"}},
+		{Type: "gene", Location: "900..950", Start: 900, End: 950, Strand: "+", Attributes: map[string]string{}},
+	}
+	if diff := cmp.Diff(expected, genbank.GeneIntervals()); diff != "" {
+		t.Errorf("GeneIntervals() of a genbank mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPeptideParts(t *testing.T) {
+	cds := Feature{Type: "CDS", Location: "join(101..200,301..500)"}
+	annotatedSequence := AnnotatedSequence{
+		Features: []Feature{
+			cds,
+			{Type: "sig_peptide", Location: "101..160"},
+			{Type: "mat_peptide", Location: "join(161..200,301..497)"},
+			{Type: "mat_peptide", Location: "complement(161..200)"}, // wrong strand.
+			{Type: "mat_peptide", Location: "150..250"},             // runs into the intron.
+			{Type: "misc_feature", Location: "101..160"},            // not a peptide.
+			{Type: "mat_peptide", Location: "600..700"},             // outside the CDS.
+		},
+	}
+
+	parts := annotatedSequence.PeptideParts(cds)
+	if len(parts) != 2 || parts[0].Type != "sig_peptide" || parts[1].Location != "join(161..200,301..497)" {
+		t.Errorf("PeptideParts() expected the sig_peptide and joined mat_peptide. Got %+v", parts)
+	}
+}
+
+func TestExtractWithContext(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Meta:     Meta{Locus: Locus{Name: "context", SequenceLength: "30 bp", Circular: true}},
+		Sequence: Sequence{Sequence: "aaaaaaaaaaGGGATGCCCTAAtttttttt"},
+		Features: []Feature{
+			{Type: "source", Location: "1..30"},
+			{Type: "gene", Location: "1..3"},
+			{Type: "gene", Location: "11..19"},
+			{Type: "CDS", Location: "complement(join(5..8,12..15,25..28))"},
+			{Type: "repeat_region", Start: 20, End: 25, Strand: "+"},
+		},
+	}
+	context, err := annotatedSequence.ExtractWithContext(annotatedSequence.Features[2], 2)
+	if err != nil {
+		t.Fatalf("ExtractWithContext() returned an error: %s", err)
+	}
+	if context.Sequence.Sequence != "aaGGGATGCCCTA" || context.Meta.Locus.SequenceLength != "13 bp" || context.Meta.Locus.Circular {
+		t.Errorf("ExtractWithContext() returned the wrong sequence or Meta. Got %s, %s", context.Sequence.Sequence, context.Meta.Locus.SequenceLength)
+	}
+	expected := []Feature{
+		{Type: "source", Location: "<1..>13", Start: 1, End: 13, Strand: "+"},
+		{Type: "gene", Location: "3..11", Start: 3, End: 11, Strand: "+"},
+		{Type: "CDS", Location: "complement(4..7)", Start: 4, End: 7, Strand: "-"},
+		{Type: "repeat_region", Start: 12, End: 13, Strand: "+"},
+	}
+	if diff := cmp.Diff(expected, context.Features); diff != "" {
+		t.Errorf("ExtractWithContext() features mismatch (-want +got):\n%s", diff)
+	}
+	if geneSequence, _ := context.FeatureSequence(context.Features[1]); geneSequence != "GGGATGCCC" {
+		t.Errorf("ExtractWithContext() gene should resolve to GGGATGCCC. Got %s", geneSequence)
+	}
+
+	edge, err := annotatedSequence.ExtractWithContext(annotatedSequence.Features[1], 5)
+	if err != nil || edge.Sequence.Sequence != "aaaaaaaa" {
+		t.Errorf("ExtractWithContext() should stop the flank at the start of the sequence. Got %q, %v", edge.Sequence.Sequence, err)
+	}
+	if _, err := annotatedSequence.ExtractWithContext(Feature{Type: "gene", Location: "25..40"}, 0); err == nil {
+		t.Errorf("ExtractWithContext() should error for a feature outside the sequence")
+	}
+}
+
+func TestExtractFeatureByName(t *testing.T) {
+	bsub := ReadGbk("data/bsub.gbk")
+	var gene Feature
+	for _, feature := range bsub.Features {
+		if feature.Type == "gene" && feature.Attributes["locus_tag"] != "" {
+			gene = feature
+			break
+		}
+	}
+	for _, name := range []string{gene.Attributes["locus_tag"], gene.Attributes["gene"]} {
+		extracted, err := bsub.ExtractFeatureByName(name, 100)
+		if err != nil {
+			t.Fatalf("ExtractFeatureByName(%q) returned an unexpected error: %s", name, err)
+		}
+		expected, _ := bsub.ExtractWithContext(gene, 100)
+		if diff := cmp.Diff(expected, extracted); diff != "" {
+			t.Errorf("ExtractFeatureByName(%q) should extract the gene rather than its CDS (-want +got):\n%s", name, diff)
+		}
+	}
+	if _, err := bsub.ExtractFeatureByName("not_a_gene", 100); err == nil {
+		t.Errorf("ExtractFeatureByName() should return an error for an unknown name")
+	}
+}
+
+func TestFeatureQuality(t *testing.T) {
+	read := Read{Identifier: "trace", Sequence: "ATGCATGC", Quality: "!+5?IIII"}
+	annotatedSequence := AnnotatedSequence{Sequence: read.AsSequence(33)}
+	if diff := cmp.Diff([]int{0, 10, 20, 30, 40, 40, 40, 40}, annotatedSequence.Sequence.Quality); diff != "" {
+		t.Errorf("AsSequence() did not decode quality (-want +got):\n%s", diff)
+	}
+
+	quality, err := annotatedSequence.FeatureQuality(Feature{Location: "complement(join(1..2,4..5))"})
+	if err != nil {
+		t.Fatalf("FeatureQuality() returned an unexpected error: %s", err)
+	}
+	if diff := cmp.Diff([]int{40, 30, 10, 0}, quality); diff != "" {
+		t.Errorf("FeatureQuality() mismatch (-want +got):\n%s", diff)
+	}
+
+	if quality, err := (AnnotatedSequence{Sequence: Sequence{Sequence: "ATGC"}}).FeatureQuality(Feature{Location: "1..2"}); quality != nil || err != nil {
+		t.Errorf("FeatureQuality() should return nil for a sequence without quality. Got %v, %v", quality, err)
+	}
+	gap := Feature{Type: "assembly_gap", Location: "3..4", Attributes: map[string]string{"estimated_length": "5"}}
+	if quality, err := annotatedSequence.FeatureQuality(gap); err != nil || len(quality) != 5 || quality[0] != 0 {
+		t.Errorf("FeatureQuality() expected a score of 0 for each of the 5 estimated Ns of a gap. Got %v, %v", quality, err)
+	}
+
+	misaligned := annotatedSequence
+	misaligned.Sequence.Quality = []int{40}
+	if context, err := misaligned.ExtractWithContext(Feature{Location: "2..3"}, 0); err != nil || context.Sequence.Quality != nil {
+		t.Errorf("ExtractWithContext() should drop quality that doesn't have a score for every base. Got %v, %v", context.Sequence.Quality, err)
+	}
+
+	gbk := "LOCUS       trace                      8 bp    DNA     linear   SYN 01-JAN-2020\n" +
+		"FEATURES             Location/Qualifiers\n" +
+		"     source          1..8\n" +
+		"                     /phred=\"0 10 20 30 40 40\n" +
+		"                     40 40\"\n" +
+		"ORIGIN\n" +
+		"        1 atgcatgc\n" +
+		"//\n"
+	if diff := cmp.Diff([]int{0, 10, 20, 30, 40, 40, 40, 40}, ParseGbk(gbk).Sequence.Quality); diff != "" {
+		t.Errorf("ParseGbk() did not read quality from /phred (-want +got):\n%s", diff)
+	}
+	if quality := ParseGbk(strings.Replace(gbk, " 40 40\"", " 40\"", 1)).Sequence.Quality; quality != nil {
+		t.Errorf("ParseGbk() should ignore a /phred without a score for every base. Got %v", quality)
+	}
+}
+
+func TestFeatureSequenceRegionOffset(t *testing.T) {
+	gff := "##gff-version 3\n##sequence-region contig1 1000 1011\n" +
+		"contig1\tfeature\tCDS\t1000\t1008\t.\t+\t0\tID=cds0\n" +
+		"contig1\tfeature\tCDS\t1003\t1011\t.\t-\t0\tID=cds1\n" +
+		"##FASTA\n>contig1\nATGAAATAANNN\n"
+	annotatedSequence, _ := ParseGff(gff)
+
+	plusStrand, err := annotatedSequence.FeatureSequence(annotatedSequence.Features[0])
+	if err != nil || plusStrand != "ATGAAATAA" {
+		t.Errorf("FeatureSequence() expected ATGAAATAA from a region starting at 1000. Got %s, %v", plusStrand, err)
+	}
+	minusStrand, err := annotatedSequence.FeatureSequence(annotatedSequence.Features[1])
+	if err != nil || minusStrand != "NNNTTATTT" {
+		t.Errorf("FeatureSequence() expected NNNTTATTT from a region starting at 1000. Got %s, %v", minusStrand, err)
+	}
+	if gaps := annotatedSequence.GapFeatures(3); len(gaps) != 1 || gaps[0].Location != "1009..1011" {
+		t.Errorf("GapFeatures() expected a gap at 1009..1011 on the contig. Got %+v", gaps)
+	}
+
+	// a sequence longer than the region is the whole contig, so coordinates aren't offset.
+	annotatedSequence.Sequence.Sequence = strings.Repeat("C", 999) + "ATGAAATAANNN"
+	if wholeContig, _ := annotatedSequence.FeatureSequence(annotatedSequence.Features[0]); wholeContig != "ATGAAATAA" {
+		t.Errorf("FeatureSequence() should not offset coordinates into a whole contig. Got %s", wholeContig)
+	}
+}
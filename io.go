@@ -1,14 +1,27 @@
 package main
 
 import (
+	"archive/tar"
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
 /******************************************************************************
@@ -19,9 +32,18 @@ Structs:
 	AnnotatedSequence - main struct for sequence handling plus sub structs.
 
 File specific parsers, readers, writers, and builders:
-	Gff - parser, reader, writer, builder
-	Gbk/gb/genbank - parser, reader
+	Gff - parser, reader, reader with separate fasta, writer, streaming writer, builder, merger
+	Gbk/gb/genbank - parser, multi-record parser and reader, reader, writer, parallel writer, builder, metadata reader, verified reader, tar.gz reader, indexer, minimal record builder, feature type registry, CONTIG resolver
 	JSON- reader, writer
+	Feature table - builder
+	GenePred - builder
+	Fasta - parser, reader, builder, writer, feature sequence writer, indexed reader
+	Fastq - parser, reader
+	2bit - builder, writer, indexed reader
+
+Conversion:
+	Convert - reads any supported format and writes any other based on file extensions.
+	Qualifier names - translating attribute names between gff and genbank.
 
 ******************************************************************************/
 
@@ -38,6 +60,10 @@ type Meta struct {
 	GffVersion  string
 	RegionStart int
 	RegionEnd   int
+	// NCBI taxonomy of the organism. SpeciesTaxonURL is from a gff ##species directive and TaxonID is the NCBI taxon id
+	// from the directive or a genbank source feature's /db_xref="taxon:..." qualifier.
+	SpeciesTaxonURL string
+	TaxonID         string
 	// genbank specific
 	Size            int
 	Type            string
@@ -48,8 +74,10 @@ type Meta struct {
 	Version         string
 	Keywords        string
 	Organism        string
+	Taxonomy        []string // lineage from the ORGANISM block, from the highest rank down.
 	Source          string
 	Origin          string
+	Contig          string // CONTIG join of other records' sequences that a scaffold's sequence is built from, see ResolveContig.
 	Locus           Locus
 	References      []Reference
 	Primaries       []Primary
@@ -81,7 +109,7 @@ type Locus struct {
 
 // Feature holds a single annotation in a struct. from https://github.com/blachlylab/gff3/blob/master/gff3.go
 type Feature struct {
-	Name string //Seqid in gff, name in gbk
+	Name string //Seqid in gff, the landmark sequence the feature is on. Unset for gbk, where every feature is on the record.
 	//gff specific
 	Source     string
 	Type       string
@@ -94,12 +122,50 @@ type Feature struct {
 	//gbk specific
 	Location string
 	Sequence string
+	// the values after the first of a genbank qualifier that appears more than once, like /db_xref, in order. The first
+	// value is in Attributes, so every value is kept in exactly one place. QualifierValues returns them all.
+	RepeatedQualifiers map[string][]string `json:",omitempty"`
+	// genbank qualifiers that were read without a value, like /pseudo, so they're written back bare rather than as "true".
+	FlagQualifiers map[string]bool `json:",omitempty"`
+	// optional record of how the feature was made. Not written to gff or genbank.
+	Provenance *Provenance `json:",omitempty"`
+	// the parsed Target attribute of gff alignment features. The raw attribute is still kept in Attributes.
+	Target *Target `json:",omitempty"`
+}
+
+// Target holds the region of a target sequence that a gff alignment feature (like one from minimap2 or exonerate) aligns to.
+type Target struct {
+	ID     string
+	Start  int
+	End    int
+	Strand string // "+", "-", or "" if the Target attribute left it out.
+}
+
+// Provenance records where a Feature came from so annotations merged from several files or predictors can be audited.
+type Provenance struct {
+	Tool       string            // the parser or program that produced the feature.
+	Timestamp  *time.Time        `json:",omitempty"` // when the tool was run, if it's known.
+	Parameters map[string]string `json:",omitempty"` // settings the tool was run with.
 }
 
 // Sequence holds raw sequence information in an AnnotatedSequence struct.
 type Sequence struct {
 	Description string
 	Sequence    string
+	// optional phred quality score of every base, like those of a Sanger trace or a consensus built from fastq reads.
+	// ParseGbk reads it from a /phred qualifier on the source feature and BuildGbk writes it back there. nil for formats
+	// without quality.
+	Quality []int `json:",omitempty"`
+	// optional SEGUID checksum of Sequence embedded by a writer's IncludeChecksum option, which VerifySeguid checks.
+	// Transformations that change Sequence, like ApplyVariants or TrimNs, clear it since it would no longer match.
+	Seguid string `json:",omitempty"`
+}
+
+// Read holds a single sequencing read from a fastq file.
+type Read struct {
+	Identifier string
+	Sequence   string
+	Quality    string
 }
 
 // AnnotatedSequence holds all sequence information in a single struct.
@@ -109,6 +175,29 @@ type AnnotatedSequence struct {
 	Sequence Sequence
 }
 
+// SequenceCase is the case writers give the sequences they write, set through their options.
+type SequenceCase int
+
+const (
+	// CaseAsIs writes sequences in whatever case they're stored in, keeping soft masked lowercase bases lowercase.
+	CaseAsIs SequenceCase = iota
+	// CaseUpper writes sequences in uppercase.
+	CaseUpper
+	// CaseLower writes sequences in lowercase.
+	CaseLower
+)
+
+// returns a sequence in the case sequenceCase asks for.
+func (sequenceCase SequenceCase) apply(sequence string) string {
+	switch sequenceCase {
+	case CaseUpper:
+		return strings.ToUpper(sequence)
+	case CaseLower:
+		return strings.ToLower(sequence)
+	}
+	return sequence
+}
+
 /******************************************************************************
 
 AnnotatedSequence related structs end here.
@@ -121,44 +210,77 @@ GFF specific IO related things begin here.
 
 ******************************************************************************/
 
-// ParseGff Takes in a string representing a gffv3 file and parses it into an AnnotatedSequence object.
-func ParseGff(gff string) AnnotatedSequence {
+// ParseGff Takes in a string representing a gffv3 file and parses it into an AnnotatedSequence object. Comment lines and
+// UCSC track and browser lines are skipped wherever they appear. Meta.Name is the seqid of ##sequence-region, or of the
+// first feature if the optional directive is missing. Returns an error if there's no ##gff-version directive,
+// the ##sequence-region directive is malformed, a feature line has fewer than 9 columns or coordinates that aren't
+// integers or ".", or an attribute has no "=".
+func ParseGff(gff string) (AnnotatedSequence, error) {
 	lines := strings.Split(gff, "\n")
-	metaString := lines[0:2]
-	versionString := metaString[0]
-	regionStringArray := strings.Split(metaString[1], " ")
 
 	meta := Meta{}
-	meta.GffVersion = strings.Split(versionString, " ")[1]
-	meta.Name = regionStringArray[1] // Formally region name, but changed to name here for generality/interoperability.
-	meta.RegionStart, _ = strconv.Atoi(regionStringArray[2])
-	meta.RegionEnd, _ = strconv.Atoi(regionStringArray[3])
-	meta.Size = meta.RegionEnd - meta.RegionStart
-
+	sawVersion, sawRegion := false, false
 	records := []Feature{}
 	sequence := Sequence{}
 	var sequenceBuffer bytes.Buffer
 	fastaFlag := false
-	for _, line := range lines {
+	for lineIndex, line := range lines {
+		line = strings.TrimRight(line, "\r")
 		if line == "##FASTA" {
 			fastaFlag = true
 		} else if len(line) == 0 {
 			continue
-		} else if line[0:2] == "##" {
+		} else if strings.HasPrefix(line, "##gff-version") {
+			meta.GffVersion = parseGffVersion(line)
+			sawVersion = true
+		} else if strings.HasPrefix(line, "##sequence-region") {
+			// only the first region is kept since Meta describes a single sequence.
+			if sawRegion {
+				continue
+			}
+			regionFields := strings.Fields(line)
+			if len(regionFields) != 4 {
+				return AnnotatedSequence{}, fmt.Errorf("line %d of gff: malformed directive %q, expected ##sequence-region seqid start end", lineIndex+1, line)
+			}
+			var startErr, endErr error
+			meta.Name = regionFields[1] // Formally region name, but changed to name here for generality/interoperability.
+			meta.RegionStart, startErr = strconv.Atoi(regionFields[2])
+			meta.RegionEnd, endErr = strconv.Atoi(regionFields[3])
+			if startErr != nil || endErr != nil {
+				return AnnotatedSequence{}, fmt.Errorf("line %d of gff: malformed directive %q, start and end must be integers", lineIndex+1, line)
+			}
+			meta.Size = meta.RegionEnd - meta.RegionStart
+			sawRegion = true
+		} else if strings.HasPrefix(line, "##species") {
+			meta.SpeciesTaxonURL, meta.TaxonID = parseGffSpecies(line)
+		} else if strings.HasPrefix(line, "##") {
 			continue
+		} else if line[0] == '#' {
+			if strings.HasPrefix(line, gffSeguidComment) {
+				sequence.Seguid = strings.TrimSpace(strings.TrimPrefix(line, gffSeguidComment))
+			}
 		} else if fastaFlag == true && line[0:1] != ">" {
 			// sequence.Sequence = sequence.Sequence + line
 			sequenceBuffer.WriteString(line)
 		} else if fastaFlag == true && line[0:1] == ">" {
 			sequence.Description = line
+		} else if strings.HasPrefix(line, "track ") || strings.HasPrefix(line, "browser ") {
+			continue
 		} else {
+			fields, err := splitGffColumns(line)
+			if err != nil {
+				return AnnotatedSequence{}, fmt.Errorf("line %d of gff: %w", lineIndex+1, err)
+			}
 			record := Feature{}
-			fields := strings.Split(line, "\t")
 			record.Name = fields[0]
 			record.Source = fields[1]
 			record.Type = fields[2]
-			record.Start, _ = strconv.Atoi(fields[3])
-			record.End, _ = strconv.Atoi(fields[4])
+			var startErr, endErr error
+			record.Start, startErr = parseGffCoordinate(fields[3])
+			record.End, endErr = parseGffCoordinate(fields[4])
+			if startErr != nil || endErr != nil {
+				return AnnotatedSequence{}, fmt.Errorf("line %d of gff: start %q and end %q must be integers or \".\"", lineIndex+1, fields[3], fields[4])
+			}
 			record.Score = fields[5]
 			record.Strand = fields[6]
 			record.Phase = fields[7]
@@ -168,138 +290,466 @@ func ParseGff(gff string) AnnotatedSequence {
 			attributeSlice := strings.Split(attributes, ";")
 
 			for _, attribute := range attributeSlice {
-				attributeSplit := strings.Split(attribute, "=")
-				key := attributeSplit[0]
-				value := attributeSplit[1]
-				record.Attributes[key] = value
+				if strings.TrimSpace(attribute) == "" || attribute == "." {
+					continue
+				}
+				attributeSplit := strings.SplitN(attribute, "=", 2)
+				if len(attributeSplit) != 2 {
+					return AnnotatedSequence{}, fmt.Errorf("line %d of gff: attribute %q has no \"=\", expected tag=value", lineIndex+1, attribute)
+				}
+				record.Attributes[attributeSplit[0]] = gffValueUnescaper.Replace(attributeSplit[1])
 			}
+			if targetString, ok := record.Attributes["Target"]; ok {
+				target, err := ParseTarget(targetString)
+				if err != nil {
+					log.Printf("ignoring Target attribute on line %d of gff: %s", lineIndex+1, err)
+				} else {
+					record.Target = &target
+				}
+			}
+			record.Provenance = &Provenance{Tool: "ParseGff"}
 			records = append(records, record)
 		}
 	}
+	if !sawVersion {
+		return AnnotatedSequence{}, fmt.Errorf("gff has no ##gff-version directive")
+	}
 	sequence.Sequence = sequenceBuffer.String()
+	if !sawRegion && len(records) > 0 {
+		meta.Name = records[0].Name
+	}
+	meta.Locus.Circular = hasCircularLandmark(meta.Name, records)
 	annotatedSequence := AnnotatedSequence{}
 	annotatedSequence.Meta = meta
 	annotatedSequence.Features = records
 	annotatedSequence.Sequence = sequence
 
-	return annotatedSequence
+	return annotatedSequence, nil
+}
+
+// gff3 requires tabs and newlines inside attribute values to be percent encoded so they don't break the file's columns
+// and lines. Percent signs are encoded as %25 too, so a literal "%09" in a value isn't read back as a tab. Other percent
+// encodings like %3B are left as they are, both when reading and writing, so they round trip unchanged.
+var gffValueUnescaper = strings.NewReplacer("%25", "%", "%09", "\t", "%0A", "\n", "%0a", "\n", "%0D", "\r", "%0d", "\r")
+
+// percent encodes an attribute value for gff, the reverse of gffValueUnescaper.
+func escapeGffValue(value string) string {
+	var escaped strings.Builder
+	escaped.Grow(len(value))
+	for index := 0; index < len(value); index++ {
+		switch character := value[index]; {
+		case character == '\t':
+			escaped.WriteString("%09")
+		case character == '\n':
+			escaped.WriteString("%0A")
+		case character == '\r':
+			escaped.WriteString("%0D")
+		case character == '%' && !isKeptGffEscape(value[index:]):
+			escaped.WriteString("%25")
+		default:
+			escaped.WriteByte(character)
+		}
+	}
+	return escaped.String()
+}
+
+// reports whether value starts with a percent encoding that gffValueUnescaper leaves encoded, like %3B.
+func isKeptGffEscape(value string) bool {
+	if len(value) < 3 || !isHexDigit(value[1]) || !isHexDigit(value[2]) {
+		return false
+	}
+	switch strings.ToUpper(value[1:3]) {
+	case "25", "09", "0A", "0D":
+		return false
+	}
+	return true
+}
+
+// reports whether a character is 0-9, a-f, or A-F.
+func isHexDigit(character byte) bool {
+	return ('0' <= character && character <= '9') || ('a' <= character && character <= 'f') || ('A' <= character && character <= 'F')
+}
+
+// splits a gff feature line into its nine columns. Hand edited files sometimes use spaces where tabs belong, so lines
+// without nine tab separated columns fall back to splitting the first eight columns on runs of whitespace. Everything
+// after the eighth column is kept together as the attributes column since attribute values may contain spaces.
+func splitGffColumns(line string) ([]string, error) {
+	columns := strings.Split(line, "\t")
+	if len(columns) >= 9 {
+		return columns, nil
+	}
+
+	columns = make([]string, 0, 9)
+	remainder := line
+	for len(columns) < 8 {
+		remainder = strings.TrimLeft(remainder, " \t")
+		columnEnd := strings.IndexAny(remainder, " \t")
+		if columnEnd == -1 {
+			columnEnd = len(remainder)
+		}
+		if columnEnd == 0 {
+			return nil, fmt.Errorf("expected 9 columns but found %d", len(columns))
+		}
+		columns = append(columns, remainder[:columnEnd])
+		remainder = remainder[columnEnd:]
+	}
+	return append(columns, strings.TrimSpace(remainder)), nil
+}
+
+// ParseTarget parses the value of a gff Target attribute, "target_id start end [strand]", into a Target. Coordinates must
+// be positive integers and the optional strand must be + or -.
+func ParseTarget(targetString string) (Target, error) {
+	targetFields := strings.Fields(targetString)
+	if len(targetFields) != 3 && len(targetFields) != 4 {
+		return Target{}, fmt.Errorf("malformed Target %q, expected \"target_id start end [strand]\"", targetString)
+	}
+
+	target := Target{ID: targetFields[0]}
+	var err error
+	if target.Start, err = strconv.Atoi(targetFields[1]); err != nil || target.Start < 1 {
+		return Target{}, fmt.Errorf("malformed Target %q, start must be a positive integer", targetString)
+	}
+	if target.End, err = strconv.Atoi(targetFields[2]); err != nil || target.End < 1 {
+		return Target{}, fmt.Errorf("malformed Target %q, end must be a positive integer", targetString)
+	}
+	if len(targetFields) == 4 {
+		target.Strand = targetFields[3]
+		if target.Strand != "+" && target.Strand != "-" {
+			return Target{}, fmt.Errorf("malformed Target %q, strand must be + or -", targetString)
+		}
+	}
+	return target, nil
+}
+
+// gffSeguidComment starts the comment line that GffOptions.IncludeChecksum writes the sequence's SEGUID to.
+const gffSeguidComment = "# seguid "
+
+// ncbiTaxonomyURL is the NCBI taxonomy browser page that ##species directives link to, followed by a taxon id.
+const ncbiTaxonomyURL = "https://www.ncbi.nlm.nih.gov/Taxonomy/Browser/wwwtax.cgi?id="
+
+// parses a ##species directive into the taxonomy URL it holds and the NCBI taxon id from the URL's id parameter. Bare
+// taxon ids and "taxon:" ids are accepted too, in which case the URL is left empty.
+func parseGffSpecies(speciesLine string) (speciesTaxonURL, taxonID string) {
+	species := strings.TrimSpace(strings.TrimPrefix(speciesLine, "##species"))
+	if taxonID := strings.TrimPrefix(species, "taxon:"); taxonID != "" && strings.Trim(taxonID, "0123456789") == "" {
+		return "", taxonID
+	}
+	if idIndex := strings.LastIndex(species, "id="); idIndex != -1 {
+		taxonID = species[idIndex+len("id="):]
+		if parameterEnd := strings.IndexAny(taxonID, "&#"); parameterEnd != -1 {
+			taxonID = taxonID[:parameterEnd]
+		}
+	}
+	return species, taxonID
+}
+
+// returns the ##species directive line for a sequence's Meta, linking SpeciesTaxonURL or else the NCBI taxonomy page of
+// TaxonID. Returns an empty string if neither is set.
+func gffSpeciesDirective(meta Meta) string {
+	if meta.SpeciesTaxonURL != "" {
+		return "##species " + meta.SpeciesTaxonURL + "\n"
+	}
+	if meta.TaxonID != "" {
+		return "##species " + ncbiTaxonomyURL + meta.TaxonID + "\n"
+	}
+	return ""
+}
+
+// parses the full version token (e.g. 3 or 3.1.26) out of a ##gff-version line and warns if it isn't a gff3 version.
+func parseGffVersion(versionLine string) string {
+	var version string
+	versionFields := strings.Fields(strings.TrimPrefix(versionLine, "##gff-version"))
+	if len(versionFields) > 0 {
+		version = versionFields[0]
+	}
+	if version != "3" && !strings.HasPrefix(version, "3.") {
+		log.Printf("gff version %q is not a gff3 version. Parsing may be unreliable.", version)
+	}
+	return version
+}
+
+// UndefinedCoordinate is the Start or End of a gff feature whose coordinate column was "." (undefined).
+const UndefinedCoordinate = -1
+
+// parses a gff start or end column, keeping "." distinct from real coordinates.
+func parseGffCoordinate(coordinateString string) (int, error) {
+	if coordinateString == "." {
+		return UndefinedCoordinate, nil
+	}
+	return strconv.Atoi(coordinateString)
+}
+
+// formats a gff start or end column, writing undefined coordinates back out as ".".
+func formatGffCoordinate(coordinate int) string {
+	if coordinate == UndefinedCoordinate {
+		return "."
+	}
+	return strconv.Itoa(coordinate)
+}
+
+// GffOptions controls what BuildGffWithOptions includes in a gff.
+type GffOptions struct {
+	IncludeFasta    bool         // append the sequence in a ##FASTA block after the features.
+	IncludeChecksum bool         // write the sequence's SEGUID in a "# seguid" comment after the directives.
+	Case            SequenceCase // case of the sequence in the ##FASTA block.
+}
+
+// reports whether the landmark feature of a gff sequence region carries the gff3 Is_circular=true attribute.
+func hasCircularLandmark(name string, features []Feature) bool {
+	for _, feature := range features {
+		if feature.Name == name && feature.Attributes["Is_circular"] == "true" {
+			return true
+		}
+	}
+	return false
 }
 
 // BuildGff takes an Annotated sequence and returns a byte array representing a gff to be written out.
+// The sequence is included in a ##FASTA block if there is one. Use BuildGffWithOptions to leave it out.
 func BuildGff(annotatedSequence AnnotatedSequence) []byte {
+	return BuildGffWithOptions(annotatedSequence, GffOptions{IncludeFasta: true})
+}
+
+// BuildGffWithOptions takes an Annotated sequence and GffOptions and returns a byte array representing a gff to be written out.
+//
+// gff3 has no topology in its ##sequence-region directive, so circular sequences (Meta.Locus.Circular) follow the gff3
+// spec's convention of a region feature spanning the whole sequence with an Is_circular=true attribute. The region is
+// only added if the features don't already include one, so parsed circular gffs are written back out unchanged.
+//
+// The seqid column always holds the landmark, the sequence the feature's coordinates are on. That's the feature's Name,
+// which is what ParseGff reads the seqid into, or if it has none the record's name from Meta.Name, Meta.Locus.Name, or
+// Meta.Accession, the same name written in ##sequence-region and the ##FASTA header. The Name and ID attributes of a
+// feature name the feature itself and are only ever written from its Attributes, never from the seqid.
+func BuildGffWithOptions(annotatedSequence AnnotatedSequence, options GffOptions) []byte {
 	var gffBuffer bytes.Buffer
 
 	var versionString string
 	if annotatedSequence.Meta.GffVersion != "" {
 		versionString = "##gff-version " + annotatedSequence.Meta.GffVersion + "\n"
 	} else {
-		versionString = "##gff-version 3 \n"
+		versionString = "##gff-version 3\n"
 	}
 	gffBuffer.WriteString(versionString)
 
-	var regionString string
-	var name string
-	var start string
-	var end string
-
-	if annotatedSequence.Meta.Name != "" {
-		name = annotatedSequence.Meta.Name
-	} else if annotatedSequence.Meta.Locus.Name != "" {
-		name = annotatedSequence.Meta.Locus.Name
-	} else if annotatedSequence.Meta.Accession != "" {
-		name = annotatedSequence.Meta.Accession
+	name, start, end := gffSequenceRegion(annotatedSequence.Meta)
+	gffBuffer.WriteString("##sequence-region " + name + " " + start + " " + end + "\n")
+	gffBuffer.WriteString(gffSpeciesDirective(annotatedSequence.Meta))
+	if options.IncludeChecksum {
+		gffBuffer.WriteString(gffSeguidComment + Seguid(annotatedSequence.Sequence.Sequence) + "\n")
+	}
+
+	if annotatedSequence.Meta.Locus.Circular && !hasCircularLandmark(name, annotatedSequence.Features) {
+		gffBuffer.WriteString(gffCircularRegion(name, start, end))
+	}
+
+	for _, feature := range annotatedSequence.Features {
+		gffBuffer.WriteString(formatGffFeature(feature, name))
+	}
+
+	gffBuffer.WriteString("###\n")
+	// an empty ##FASTA block is malformed so annotation only records don't get one.
+	if !options.IncludeFasta || annotatedSequence.Sequence.Sequence == "" {
+		return gffBuffer.Bytes()
+	}
+	gffBuffer.WriteString("##FASTA\n")
+	gffBuffer.WriteString(">" + name + "\n")
+
+	for letterIndex, letter := range options.Case.apply(annotatedSequence.Sequence.Sequence) {
+		letterIndex++
+		if letterIndex%70 == 0 && letterIndex != 0 {
+			gffBuffer.WriteRune(letter)
+			gffBuffer.WriteString("\n")
+		} else {
+			gffBuffer.WriteRune(letter)
+		}
+	}
+	gffBuffer.WriteString("\n")
+	return gffBuffer.Bytes()
+}
+
+// returns the seqid, start, and end written to the ##sequence-region directive for a sequence's Meta.
+func gffSequenceRegion(meta Meta) (name, start, end string) {
+	if meta.Name != "" {
+		name = meta.Name
+	} else if meta.Locus.Name != "" {
+		name = meta.Locus.Name
+	} else if meta.Accession != "" {
+		name = meta.Accession
 	} else {
 		name = "unknown"
 	}
 
-	if annotatedSequence.Meta.RegionStart != 0 {
-		start = strconv.Itoa(annotatedSequence.Meta.RegionStart)
+	if meta.RegionStart != 0 {
+		start = strconv.Itoa(meta.RegionStart)
 	} else {
 		start = "1"
 	}
 
-	if annotatedSequence.Meta.RegionEnd != 0 {
-		end = strconv.Itoa(annotatedSequence.Meta.RegionEnd)
-	} else if annotatedSequence.Meta.Locus.SequenceLength != "" {
+	if meta.RegionEnd != 0 {
+		end = strconv.Itoa(meta.RegionEnd)
+	} else if meta.Locus.SequenceLength != "" {
 		reg, err := regexp.Compile("[^0-9]+")
 		if err != nil {
 			log.Fatal(err)
 		}
-		end = reg.ReplaceAllString(annotatedSequence.Meta.Locus.SequenceLength, "")
+		end = reg.ReplaceAllString(meta.Locus.SequenceLength, "")
 	} else {
 		end = "1"
 	}
+	return name, start, end
+}
 
-	regionString = "##sequence-region " + name + " " + start + " " + end + "\n"
-	gffBuffer.WriteString(regionString)
+// returns the region line gff3 uses to mark a sequence as circular.
+func gffCircularRegion(name, start, end string) string {
+	return name + "\tfeature\tregion\t" + start + "\t" + end + "\t.\t+\t.\tID=" + name + ";Is_circular=true\n"
+}
 
-	for _, feature := range annotatedSequence.Features {
-		var featureString string
+// returns a feature as a single gff line. Features without a Name are written on defaultName, which is the landmark of
+// the ##sequence-region directive.
+func formatGffFeature(feature Feature, defaultName string) string {
+	var featureName string
+	if feature.Name != "" {
+		featureName = feature.Name
+	} else {
+		featureName = defaultName
+	}
 
-		var featureName string
-		if feature.Name != "" {
-			featureName = feature.Name
-		} else {
-			featureName = annotatedSequence.Meta.Name
-		}
+	var featureSource string
+	if feature.Source != "" {
+		featureSource = feature.Source
+	} else {
+		featureSource = "feature"
+	}
 
-		var featureSource string
-		if feature.Source != "" {
-			featureSource = feature.Source
-		} else {
-			featureSource = "feature"
-		}
+	var featureType string
+	if feature.Type != "" {
+		featureType = feature.Type
+	} else {
+		featureType = "unknown"
+	}
 
-		var featureType string
-		if feature.Type != "" {
-			featureType = feature.Type
-		} else {
-			featureType = "unknown"
-		}
+	// really really really need to make a genbank parser util for getting start and stop of region.
+	featureStart := formatGffCoordinate(feature.Start)
 
-		// really really really need to make a genbank parser util for getting start and stop of region.
-		featureStart := strconv.Itoa(feature.Start)
+	featureEnd := formatGffCoordinate(feature.End)
+	featureScore := feature.Score
+	featureStrand := string(feature.Strand)
+	featurePhase := feature.Phase
+	// gff has no empty columns. Features built for genbank, like MinimalGbk's source, have no score or phase.
+	if featureScore == "" {
+		featureScore = "."
+	}
+	if featureStrand == "" {
+		featureStrand = "."
+	}
+	if featurePhase == "" {
+		featurePhase = "."
+	}
+	var featureAttributes string
 
-		featureEnd := strconv.Itoa(feature.End)
-		featureScore := feature.Score
-		featureStrand := string(feature.Strand)
-		featurePhase := feature.Phase
-		var featureAttributes string
+	keys := make([]string, 0, len(feature.Attributes))
+	for key := range feature.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-		keys := make([]string, 0, len(feature.Attributes))
-		for key := range feature.Attributes {
-			keys = append(keys, key)
-		}
-		sort.Strings(keys)
+	for _, key := range keys {
+		// repeated genbank qualifiers become a gff attribute with multiple values.
+		attributeString := key + "=" + escapeGffValue(strings.Join(feature.QualifierValues(key), ",")) + ";"
+		featureAttributes += attributeString
+	}
+
+	if len(featureAttributes) > 0 {
+		featureAttributes = featureAttributes[0 : len(featureAttributes)-1]
+	}
+	TAB := "\t"
+	return featureName + TAB + featureSource + TAB + featureType + TAB + featureStart + TAB + featureEnd + TAB + featureScore + TAB + featureStrand + TAB + featurePhase + TAB + featureAttributes + "\n"
+}
 
-		for _, key := range keys {
-			attributeString := key + "=" + feature.Attributes[key] + ";"
-			featureAttributes += attributeString
+// WriteGffStream writes a gff to w without holding it in memory, so whole genome annotations with millions of features
+// can be written in constant memory. The header is written from meta, then a line for every feature received from
+// features until it's closed, then the sequence read from sequence in a ##FASTA block wrapped at 70 bases. Whitespace in
+// the sequence is skipped and a nil or empty sequence leaves out the ##FASTA block. Circular sequences get the
+// Is_circular region line BuildGff writes, and matching region features from the channel are dropped so it isn't written
+// twice. If writing fails the rest of features is drained so the goroutine sending them isn't left blocked.
+func WriteGffStream(w io.Writer, meta Meta, features <-chan Feature, sequence io.Reader) error {
+	err := writeGffStream(w, meta, features, sequence)
+	if err != nil {
+		for range features {
 		}
+	}
+	return err
+}
+
+func writeGffStream(w io.Writer, meta Meta, features <-chan Feature, sequence io.Reader) error {
+	writer := bufio.NewWriter(w)
 
-		if len(featureAttributes) > 0 {
-			featureAttributes = featureAttributes[0 : len(featureAttributes)-1]
+	versionString := "##gff-version 3\n"
+	if meta.GffVersion != "" {
+		versionString = "##gff-version " + meta.GffVersion + "\n"
+	}
+	name, start, end := gffSequenceRegion(meta)
+	if _, err := writer.WriteString(versionString + "##sequence-region " + name + " " + start + " " + end + "\n" + gffSpeciesDirective(meta)); err != nil {
+		return err
+	}
+	if meta.Locus.Circular {
+		if _, err := writer.WriteString(gffCircularRegion(name, start, end)); err != nil {
+			return err
 		}
-		TAB := "\t"
-		featureString = featureName + TAB + featureSource + TAB + featureType + TAB + featureStart + TAB + featureEnd + TAB + featureScore + TAB + featureStrand + TAB + featurePhase + TAB + featureAttributes + "\n"
-		gffBuffer.WriteString(featureString)
 	}
 
-	gffBuffer.WriteString("###\n")
-	gffBuffer.WriteString("##FASTA\n")
-	gffBuffer.WriteString(">" + annotatedSequence.Meta.Name + "\n")
+	for feature := range features {
+		if meta.Locus.Circular && hasCircularLandmark(name, []Feature{feature}) {
+			continue
+		}
+		if _, err := writer.WriteString(formatGffFeature(feature, name)); err != nil {
+			return err
+		}
+	}
+	if _, err := writer.WriteString("###\n"); err != nil {
+		return err
+	}
 
-	for letterIndex, letter := range annotatedSequence.Sequence.Sequence {
-		letterIndex++
-		if letterIndex%70 == 0 && letterIndex != 0 {
-			gffBuffer.WriteRune(letter)
-			gffBuffer.WriteString("\n")
-		} else {
-			gffBuffer.WriteRune(letter)
+	if sequence != nil {
+		sequenceReader := bufio.NewReader(sequence)
+		lineLength := 0
+		wroteHeader := false
+		for {
+			base, err := sequenceReader.ReadByte()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if unicode.IsSpace(rune(base)) {
+				continue
+			}
+			// the header waits for the first base so an empty sequence doesn't leave an empty ##FASTA block.
+			if !wroteHeader {
+				if _, err := writer.WriteString("##FASTA\n>" + name + "\n"); err != nil {
+					return err
+				}
+				wroteHeader = true
+			}
+			if err := writer.WriteByte(base); err != nil {
+				return err
+			}
+			lineLength++
+			if lineLength == 70 {
+				if err := writer.WriteByte('\n'); err != nil {
+					return err
+				}
+				lineLength = 0
+			}
+		}
+		if lineLength > 0 {
+			if err := writer.WriteByte('\n'); err != nil {
+				return err
+			}
 		}
 	}
-	gffBuffer.WriteString("\n")
-	return gffBuffer.Bytes()
+	return writer.Flush()
 }
 
 // ReadGff takes in a filepath for a .gffv3 file and parses it into an Annotated Sequence struct.
@@ -309,17 +759,116 @@ func ReadGff(path string) AnnotatedSequence {
 	if err != nil {
 		// return 0, fmt.Errorf("Failed to open file %s for unpack: %s", gzFilePath, err)
 	} else {
-		annotatedSequence = ParseGff(string(file))
+		annotatedSequence, err = ParseGff(string(file))
+		if err != nil {
+			log.Printf("could not parse gff from %s: %s", path, err)
+		}
 	}
 	return annotatedSequence
 }
 
+// ReadGffWithFasta reads the annotation of the gff at gffPath and takes its sequence from the fasta at fastaPath, the
+// usual layout when the genome and its annotation are published as separate files. The fasta record used is the one whose
+// name, the first word of its header, is the gff's seqid from ##sequence-region, or the seqid of its first feature since
+// the directive is optional. It replaces any inline ##FASTA sequence. Returns an error if either file can't be read or
+// the fasta has no record for the seqid.
+func ReadGffWithFasta(gffPath, fastaPath string) (AnnotatedSequence, error) {
+	gff, err := ioutil.ReadFile(gffPath)
+	if err != nil {
+		return AnnotatedSequence{}, err
+	}
+	annotatedSequence, err := ParseGff(string(gff))
+	if err != nil {
+		return AnnotatedSequence{}, fmt.Errorf("could not parse gff from %s: %w", gffPath, err)
+	}
+
+	seqid := annotatedSequence.Meta.Name
+	if seqid == "" && len(annotatedSequence.Features) > 0 {
+		seqid = annotatedSequence.Features[0].Name
+	}
+
+	sequences, err := ReadFasta(fastaPath)
+	if err != nil {
+		return AnnotatedSequence{}, err
+	}
+	for _, sequence := range sequences {
+		if nameFields := strings.Fields(sequence.Description); len(nameFields) > 0 && nameFields[0] == seqid {
+			annotatedSequence.Sequence = sequence
+			return annotatedSequence, nil
+		}
+	}
+	return AnnotatedSequence{}, fmt.Errorf("%s has no sequence named %q for %s", fastaPath, seqid, gffPath)
+}
+
 // WriteGff takes an AnnotatedSequence struct and a path string and writes out a gff to that path.
 func WriteGff(annotatedSequence AnnotatedSequence, path string) {
 	gff := BuildGff(annotatedSequence)
 	_ = ioutil.WriteFile(path, gff, 0644)
 }
 
+// WriteGffWithOptions takes an AnnotatedSequence struct, GffOptions, and a path string and writes out a gff to that path.
+func WriteGffWithOptions(annotatedSequence AnnotatedSequence, options GffOptions, path string) {
+	gff := BuildGffWithOptions(annotatedSequence, options)
+	_ = ioutil.WriteFile(path, gff, 0644)
+}
+
+// MergeGff reads several gff files annotating the same sequence, like gene, repeat, and ncRNA predictions from different
+// tools, and combines them into one AnnotatedSequence. Meta comes from the first file. Features that are identical in
+// every column are only kept once, and each feature's Provenance records the path it was read from. Returns an error if
+// the files' ##FASTA sequences disagree. Files without a ##FASTA block are fine.
+func MergeGff(paths []string) (AnnotatedSequence, error) {
+	var merged AnnotatedSequence
+	var sequencePath string
+	seenFeatures := make(map[string]bool)
+	for pathIndex, path := range paths {
+		file, err := ioutil.ReadFile(path)
+		if err != nil {
+			return AnnotatedSequence{}, err
+		}
+		annotatedSequence, err := ParseGff(string(file))
+		if err != nil {
+			return AnnotatedSequence{}, fmt.Errorf("could not parse gff from %s: %w", path, err)
+		}
+		if pathIndex == 0 {
+			merged.Meta = annotatedSequence.Meta
+		}
+
+		if annotatedSequence.Sequence.Sequence != "" {
+			if sequencePath == "" {
+				merged.Sequence = annotatedSequence.Sequence
+				sequencePath = path
+			} else if !strings.EqualFold(annotatedSequence.Sequence.Sequence, merged.Sequence.Sequence) {
+				return AnnotatedSequence{}, fmt.Errorf("the sequence in %s doesn't match the sequence in %s", path, sequencePath)
+			}
+		}
+
+		for _, feature := range annotatedSequence.Features {
+			featureKey := gffFeatureKey(feature)
+			if seenFeatures[featureKey] {
+				continue
+			}
+			seenFeatures[featureKey] = true
+			feature.Provenance.Parameters = map[string]string{"path": path}
+			merged.Features = append(merged.Features, feature)
+		}
+	}
+	return merged, nil
+}
+
+// returns a string that's the same for two features only if every one of their gff columns is the same.
+func gffFeatureKey(feature Feature) string {
+	keys := make([]string, 0, len(feature.Attributes))
+	for key := range feature.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	columns := []string{feature.Name, feature.Source, feature.Type, strconv.Itoa(feature.Start), strconv.Itoa(feature.End), feature.Score, feature.Strand, feature.Phase}
+	for _, key := range keys {
+		columns = append(columns, key+"="+feature.Attributes[key])
+	}
+	return strings.Join(columns, "\t")
+}
+
 /******************************************************************************
 
 GFF specific IO related things end here.
@@ -537,6 +1086,70 @@ var genbankGeneQualifierTypes = []string{
 	"/variety=",
 }
 
+// the INSDC feature types and cleaned qualifier names as sets, built once so checking a name is a single lookup. They're
+// never written to after init so they can be read without a lock.
+var insdcFeatureTypes = stringSet(genbankGeneFeatureTypes, strings.TrimSpace)
+var insdcQualifiers = stringSet(genbankGeneQualifierTypes, cleanQualifierName)
+
+// returns the set of names after clean is applied to each of them.
+func stringSet(names []string, clean func(string) string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[clean(name)] = true
+	}
+	return set
+}
+
+// feature types and qualifiers added with RegisterFeatureType and RegisterQualifier. Guarded by registeredTypesMutex
+// since registration can happen while other goroutines are reading and checking records.
+var registeredTypesMutex sync.RWMutex
+var registeredFeatureTypes = map[string]bool{}
+var registeredQualifiers = map[string]bool{}
+
+// RegisterFeatureType adds a custom feature type to the ones IsValidFeatureType accepts, so validation stops flagging it.
+// It is safe to call from multiple goroutines and before or during parsing.
+func RegisterFeatureType(name string) {
+	registeredTypesMutex.Lock()
+	defer registeredTypesMutex.Unlock()
+	registeredFeatureTypes[strings.TrimSpace(name)] = true
+}
+
+// RegisterQualifier adds a custom qualifier name, without the leading slash, to the ones IsValidQualifier accepts.
+// It is safe to call from multiple goroutines and before or during parsing.
+func RegisterQualifier(name string) {
+	registeredTypesMutex.Lock()
+	defer registeredTypesMutex.Unlock()
+	registeredQualifiers[cleanQualifierName(name)] = true
+}
+
+// IsValidFeatureType reports whether name is an INSDC feature type or one added with RegisterFeatureType.
+func IsValidFeatureType(name string) bool {
+	name = strings.TrimSpace(name)
+	if insdcFeatureTypes[name] {
+		return true
+	}
+	registeredTypesMutex.RLock()
+	defer registeredTypesMutex.RUnlock()
+	return registeredFeatureTypes[name]
+}
+
+// IsValidQualifier reports whether name is an INSDC qualifier or one added with RegisterQualifier. The leading slash
+// and trailing equals sign are optional, so "gene", "/gene", and "/gene=" are all accepted.
+func IsValidQualifier(name string) bool {
+	name = cleanQualifierName(name)
+	if insdcQualifiers[name] {
+		return true
+	}
+	registeredTypesMutex.RLock()
+	defer registeredTypesMutex.RUnlock()
+	return registeredQualifiers[name]
+}
+
+// strips whitespace, the leading slash, and the trailing equals sign from a qualifier name.
+func cleanQualifierName(name string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(name), "/"), "=")
+}
+
 // indeces for random points of interests on a gbk line.
 const metaIndex = 0
 const subMetaIndex = 5
@@ -544,6 +1157,9 @@ const qualifierIndex = 21
 
 func quickMetaCheck(line string) bool {
 	flag := false
+	if len(line) <= metaIndex {
+		return flag
+	}
 	if string(line[metaIndex]) != " " {
 		flag = true
 	}
@@ -552,6 +1168,9 @@ func quickMetaCheck(line string) bool {
 
 func quickSubMetaCheck(line string) bool {
 	flag := false
+	if len(line) <= subMetaIndex {
+		return flag
+	}
 
 	if string(line[metaIndex]) == " " && string(line[subMetaIndex]) != " " {
 		flag = true
@@ -561,6 +1180,9 @@ func quickSubMetaCheck(line string) bool {
 
 func quickFeatureCheck(line string) bool {
 	flag := false
+	if len(line) <= subMetaIndex {
+		return flag
+	}
 
 	if string(line[metaIndex]) == " " && string(line[subMetaIndex]) != " " {
 		flag = true
@@ -570,6 +1192,9 @@ func quickFeatureCheck(line string) bool {
 
 func quickQualifierCheck(line string) bool {
 	flag := false
+	if len(line) <= qualifierIndex {
+		return flag
+	}
 
 	if string(line[metaIndex]) == " " && string(line[subMetaIndex]) == " " && string(line[qualifierIndex]) == "/" {
 		flag = true
@@ -580,6 +1205,9 @@ func quickQualifierCheck(line string) bool {
 
 func quickQualifierSubLineCheck(line string) bool {
 	flag := false
+	if len(line) <= qualifierIndex {
+		return flag
+	}
 
 	if string(line[metaIndex]) == " " && string(line[subMetaIndex]) == " " && string(line[qualifierIndex]) != "/" && string(line[qualifierIndex-1]) == " " {
 		flag = true
@@ -625,27 +1253,15 @@ func allLevelFeatureCheck(featureString string) bool {
 
 // will eventually refactor all checks into one function.
 func geneFeatureTypeCheck(featureString string) bool {
-	flag := false
-	cleanedFeatureString := strings.TrimSpace(featureString)
-	for _, feature := range genbankGeneFeatureTypes {
-		if feature == cleanedFeatureString {
-			flag = true
-			break
-		}
-	}
-	return flag
+	return IsValidFeatureType(featureString)
 }
 
 func geneQualifierTypeCheck(featureString string) bool {
-	flag := false
-	cleanedFeatureString := strings.TrimSpace(strings.SplitAfter(featureString, "=")[0])
-	for _, feature := range genbankGeneQualifierTypes {
-		if feature == cleanedFeatureString {
-			flag = true
-			break
-		}
+	cleanedFeatureString := strings.TrimSpace(featureString)
+	if !strings.HasPrefix(cleanedFeatureString, "/") {
+		return false
 	}
-	return flag
+	return IsValidQualifier(strings.SplitAfter(cleanedFeatureString, "=")[0])
 }
 
 func allGeneTypeCheck(featureString string) bool {
@@ -657,7 +1273,7 @@ func allGeneTypeCheck(featureString string) bool {
 	return flag
 }
 
-// parses locus from provided string.
+// parses locus from provided string. Fields missing from the end of a truncated LOCUS line are left empty.
 func parseLocus(locusString string) Locus {
 	locus := Locus{}
 	locusSplit := strings.Split(strings.TrimSpace(locusString), " ")
@@ -667,30 +1283,51 @@ func parseLocus(locusString string) Locus {
 			filteredLocusSplit = append(filteredLocusSplit, locusSplit[i])
 		}
 	}
-	locus.Name = filteredLocusSplit[1]
-	locus.SequenceLength = strings.Join([]string{filteredLocusSplit[2], filteredLocusSplit[3]}, " ")
-	locus.MoleculeType = filteredLocusSplit[4]
-	if filteredLocusSplit[5] == "circular" || filteredLocusSplit[5] == "linear" {
-		if filteredLocusSplit[5] == "circular" {
+	field := func(index int) string {
+		if index < len(filteredLocusSplit) {
+			return filteredLocusSplit[index]
+		}
+		return ""
+	}
+	locus.Name = field(1)
+	locus.SequenceLength = strings.TrimSpace(field(2) + " " + field(3))
+	locus.MoleculeType = field(4)
+	if field(5) == "circular" || field(5) == "linear" {
+		if field(5) == "circular" {
 			locus.Circular = true
 		} else {
 			locus.Circular = false
 		}
-		locus.GenBankDivision = filteredLocusSplit[6]
-		locus.ModDate = filteredLocusSplit[7]
+		locus.GenBankDivision = field(6)
+		locus.ModDate = field(7)
 	} else {
 		locus.Circular = false
-		locus.GenBankDivision = filteredLocusSplit[5]
-		locus.ModDate = filteredLocusSplit[6]
+		locus.GenBankDivision = field(5)
+		locus.ModDate = field(6)
 	}
 	return locus
 }
 
+// ParsedModDate parses a Locus' ModDate from genbank's DD-MON-YYYY format, like 16-DEC-2014, into a time.Time so records
+// can be sorted and filtered by date.
+func (locus Locus) ParsedModDate() (time.Time, error) {
+	// time.Parse matches month abbreviations case insensitively so JAN and Jan both work.
+	modDate, err := time.Parse("2-Jan-2006", strings.TrimSpace(locus.ModDate))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid genbank date %q, expected DD-MON-YYYY like 16-DEC-2014", locus.ModDate)
+	}
+	return modDate, nil
+}
+
 // really important helper function. It finds sublines of a feature and joins them.
 func joinSubLines(splitLine, subLines []string) string {
 	base := strings.TrimSpace(strings.Join(splitLine[1:], " "))
 
 	for _, subLine := range subLines {
+		// blank lines carry nothing to join and are too short for the checks below.
+		if strings.TrimSpace(subLine) == "" {
+			continue
+		}
 		if !quickMetaCheck(subLine) && !quickSubMetaCheck(subLine) {
 			base = strings.TrimSpace(strings.TrimSpace(base) + " " + strings.TrimSpace(subLine))
 		} else {
@@ -700,33 +1337,65 @@ func joinSubLines(splitLine, subLines []string) string {
 	return base
 }
 
-// get organism name and source. Doesn't use joinSubLines.
-func getSourceOrganism(splitLine, subLines []string) (string, string) {
+// get organism name, source, and taxonomic lineage. Doesn't use joinSubLines.
+func getSourceOrganism(splitLine, subLines []string) (string, string, []string) {
 	source := strings.TrimSpace(strings.Join(splitLine[1:], " "))
 	var organism string
+	var taxonomy []string
 	for numSubLine, subLine := range subLines {
+		if subLine == "" {
+			continue
+		}
 		headString := strings.Split(strings.TrimSpace(subLine), " ")[0]
 		if string(subLine[0]) == " " && headString != "ORGANISM" {
 			source = strings.TrimSpace(strings.TrimSpace(source) + " " + strings.TrimSpace(subLine))
 		} else {
 			organismSubLines := subLines[numSubLine+1:]
 			organismSplitLine := strings.Split(strings.TrimSpace(subLine), " ")
-			organism = joinSubLines(organismSplitLine, organismSubLines)
+			organism, taxonomy = getOrganismLineage(organismSplitLine, organismSubLines)
 			break
 		}
 	}
-	return source, organism
+	return source, organism, taxonomy
 }
 
-// gets a single reference. Parses headstring and the joins sub lines based on feature.
-func getReference(splitLine, subLines []string) Reference {
-	base := strings.TrimSpace(strings.Join(splitLine[1:], " "))
-	reference := Reference{}
-	reference.Index = strings.Split(base, " ")[0]
-	if len(base) > 1 {
-		reference.Range = strings.TrimSpace(strings.Join(strings.Split(base, " ")[1:], " "))
-	}
-
+// splits the ORGANISM block into the organism name and its lineage. The name is on the ORGANISM line and the lineage
+// follows on the continuation lines as levels separated by semicolons and ending in a period, like
+// "Bacteria; Firmicutes; Bacilli.". Continuation lines without a semicolon or final period are the end of a long organism
+// name that wrapped.
+func getOrganismLineage(splitLine, subLines []string) (string, []string) {
+	organism := strings.TrimSpace(strings.Join(splitLine[1:], " "))
+	var lineage string
+	for _, subLine := range subLines {
+		if strings.TrimSpace(subLine) == "" || quickMetaCheck(subLine) || quickSubMetaCheck(subLine) {
+			break
+		}
+		subLine = strings.TrimSpace(subLine)
+		if lineage == "" && !strings.Contains(subLine, ";") && !strings.HasSuffix(subLine, ".") {
+			organism = strings.TrimSpace(organism + " " + subLine)
+			continue
+		}
+		lineage = strings.TrimSpace(lineage + " " + subLine)
+	}
+
+	var taxonomy []string
+	for _, level := range strings.Split(strings.TrimSuffix(lineage, "."), ";") {
+		if level = strings.TrimSpace(level); level != "" {
+			taxonomy = append(taxonomy, level)
+		}
+	}
+	return organism, taxonomy
+}
+
+// gets a single reference. Parses headstring and the joins sub lines based on feature.
+func getReference(splitLine, subLines []string) Reference {
+	base := strings.TrimSpace(strings.Join(splitLine[1:], " "))
+	reference := Reference{}
+	reference.Index = strings.Split(base, " ")[0]
+	if len(base) > 1 {
+		reference.Range = strings.TrimSpace(strings.Join(strings.Split(base, " ")[1:], " "))
+	}
+
 	for numSubLine, subLine := range subLines {
 		featureSubLines := subLines[numSubLine+1:]
 		featureSplitLine := strings.Split(strings.TrimSpace(subLine), " ")
@@ -753,16 +1422,248 @@ func getReference(splitLine, subLines []string) Reference {
 	return reference
 }
 
+// Location holds a parsed genbank feature location, see ParseLocation. Coordinates are 1-based and inclusive.
+type Location struct {
+	Start             int
+	End               int
+	Complement        bool
+	Join              bool       // true for join(...) and order(...) locations made up of SubLocations.
+	Order             bool       // the SubLocations were written as order(...) rather than join(...).
+	FivePrimePartial  bool       // start was marked with "<".
+	ThreePrimePartial bool       // end was marked with ">".
+	Separator         string     // "^" for a site between two bases like 100^101, "." for one base within 100.200.
+	SubLocations      []Location // segments of a join(...) or order(...) location in the order they were written.
+}
+
+// parses a genbank location string like complement(join(12..78,134..202)) into a location struct.
+func parseLocation(locationString string) (Location, error) {
+	// locations can wrap across lines so all whitespace is insignificant.
+	locationString = strings.Join(strings.Fields(locationString), "")
+
+	var parsedLocation Location
+	if strings.HasPrefix(locationString, "complement(") && strings.HasSuffix(locationString, ")") {
+		innerLocation, err := parseLocation(locationString[len("complement(") : len(locationString)-1])
+		if err != nil {
+			return Location{}, err
+		}
+		innerLocation.Complement = !innerLocation.Complement
+		return innerLocation, nil
+	}
+
+	var joinPrefix string
+	if strings.HasPrefix(locationString, "join(") {
+		joinPrefix = "join("
+	} else if strings.HasPrefix(locationString, "order(") {
+		joinPrefix = "order("
+	}
+	if joinPrefix != "" && strings.HasSuffix(locationString, ")") {
+		parsedLocation.Join = true
+		parsedLocation.Order = joinPrefix == "order("
+		for _, subLocationString := range splitTopLevelCommas(locationString[len(joinPrefix) : len(locationString)-1]) {
+			subLocation, err := parseLocation(subLocationString)
+			if err != nil {
+				return Location{}, err
+			}
+			parsedLocation.SubLocations = append(parsedLocation.SubLocations, subLocation)
+		}
+		if len(parsedLocation.SubLocations) == 0 {
+			return Location{}, fmt.Errorf("empty %s) location", joinPrefix)
+		}
+		// the outer span covers every segment.
+		parsedLocation.Start = parsedLocation.SubLocations[0].Start
+		parsedLocation.End = parsedLocation.SubLocations[0].End
+		for _, subLocation := range parsedLocation.SubLocations {
+			if subLocation.Start < parsedLocation.Start {
+				parsedLocation.Start = subLocation.Start
+			}
+			if subLocation.End > parsedLocation.End {
+				parsedLocation.End = subLocation.End
+			}
+			parsedLocation.FivePrimePartial = parsedLocation.FivePrimePartial || subLocation.FivePrimePartial
+			parsedLocation.ThreePrimePartial = parsedLocation.ThreePrimePartial || subLocation.ThreePrimePartial
+		}
+		return parsedLocation, nil
+	}
+
+	// anything left is a simple range (100..200), a single base (100), a site between two bases (100^101),
+	// or a single base within a range (100.200).
+	if strings.Contains(locationString, ":") {
+		return Location{}, fmt.Errorf("remote location %q is not supported", locationString)
+	}
+	var startString, endString string
+	if strings.Contains(locationString, "..") {
+		rangeSplit := strings.SplitN(locationString, "..", 2)
+		startString, endString = rangeSplit[0], rangeSplit[1]
+	} else if separatorIndex := strings.IndexAny(locationString, "^."); separatorIndex >= 0 {
+		rangeSplit := strings.FieldsFunc(locationString, func(character rune) bool { return character == '^' || character == '.' })
+		if len(rangeSplit) != 2 {
+			return Location{}, fmt.Errorf("malformed location %q", locationString)
+		}
+		startString, endString = rangeSplit[0], rangeSplit[1]
+		parsedLocation.Separator = locationString[separatorIndex : separatorIndex+1]
+	} else {
+		startString, endString = locationString, locationString
+	}
+
+	// "<" marks a partial start and ">" a partial end. Single base locations can carry either.
+	for _, coordinateString := range []*string{&startString, &endString} {
+		if strings.HasPrefix(*coordinateString, "<") {
+			parsedLocation.FivePrimePartial = true
+			*coordinateString = (*coordinateString)[1:]
+		} else if strings.HasPrefix(*coordinateString, ">") {
+			parsedLocation.ThreePrimePartial = true
+			*coordinateString = (*coordinateString)[1:]
+		}
+	}
+
+	var err error
+	parsedLocation.Start, err = strconv.Atoi(startString)
+	if err != nil {
+		return Location{}, fmt.Errorf("malformed location %q: %v", locationString, err)
+	}
+	parsedLocation.End, err = strconv.Atoi(endString)
+	if err != nil {
+		return Location{}, fmt.Errorf("malformed location %q: %v", locationString, err)
+	}
+	return parsedLocation, nil
+}
+
+// ParseLocation parses a genbank location string like complement(join(12..78,134..202)) into a Location. Start and End
+// are the outer span of every segment, "<" and ">" are flagged as FivePrimePartial and ThreePrimePartial, and the
+// segments of join(...) and order(...) locations are kept in SubLocations. Whitespace is ignored so locations that wrap
+// across lines can be passed in as they are. Locations that can't be parsed, like remote locations on other records,
+// return an empty Location with a Start of 0.
+func ParseLocation(locationString string) Location {
+	parsedLocation, err := parseLocation(locationString)
+	if err != nil {
+		return Location{}
+	}
+	return parsedLocation
+}
+
+// reports whether every range of a location is on another record, like J00194.1:100..202 or
+// complement(join(J00194.1:1..10,J00195.1:1..10)), so it has no coordinates on this sequence at all.
+func isRemoteLocation(locationString string) bool {
+	parts := strings.FieldsFunc(locationString, func(character rune) bool {
+		return character == ',' || character == '(' || character == ')'
+	})
+	remote := false
+	for _, part := range parts {
+		switch part = strings.TrimSpace(part); {
+		case part == "" || part == "join" || part == "order" || part == "complement":
+			continue
+		case strings.Contains(part, ":"):
+			remote = true
+		default:
+			return false
+		}
+	}
+	return remote
+}
+
+// splits a string on commas that aren't nested inside parentheses.
+func splitTopLevelCommas(locationString string) []string {
+	var parts []string
+	depth := 0
+	partStart := 0
+	for index, character := range locationString {
+		switch character {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, locationString[partStart:index])
+				partStart = index + 1
+			}
+		}
+	}
+	if partStart < len(locationString) {
+		parts = append(parts, locationString[partStart:])
+	}
+	return parts
+}
+
+// formats a location back into a genbank location string, the reverse of parseLocation.
+func formatLocation(featureLocation Location) string {
+	var locationString string
+	if featureLocation.Join {
+		subLocationStrings := make([]string, len(featureLocation.SubLocations))
+		for subLocationIndex, subLocation := range featureLocation.SubLocations {
+			subLocationStrings[subLocationIndex] = formatLocation(subLocation)
+		}
+		joinPrefix := "join("
+		if featureLocation.Order {
+			joinPrefix = "order("
+		}
+		locationString = joinPrefix + strings.Join(subLocationStrings, ",") + ")"
+	} else {
+		startString, endString := strconv.Itoa(featureLocation.Start), strconv.Itoa(featureLocation.End)
+		if featureLocation.FivePrimePartial {
+			startString = "<" + startString
+		}
+		if featureLocation.ThreePrimePartial {
+			endString = ">" + endString
+		}
+		if featureLocation.Separator != "" {
+			locationString = startString + featureLocation.Separator + endString
+		} else if featureLocation.Start == featureLocation.End && !(featureLocation.FivePrimePartial && featureLocation.ThreePrimePartial) {
+			locationString = startString
+			if featureLocation.ThreePrimePartial {
+				locationString = endString
+			}
+		} else {
+			locationString = startString + ".." + endString
+		}
+	}
+
+	if featureLocation.Complement {
+		locationString = "complement(" + locationString + ")"
+	}
+	return locationString
+}
+
+// sequenceQualifiers are qualifiers whose values are sequences or locations, which can't contain spaces.
+var sequenceQualifiers = map[string]bool{
+	"translation":   true,
+	"rpt_unit_seq":  true,
+	"transl_except": true,
+	"anticodon":     true,
+}
+
+// returns what goes between the lines of a qualifier that wraps, given the line it starts on. Wrapped free text like
+// /note was broken at a space that has to be put back, while sequences like /translation are broken anywhere.
+func qualifierContinuationSeparator(qualifierLine string) string {
+	qualifier := strings.TrimPrefix(strings.TrimSpace(qualifierLine), "/")
+	qualifier = strings.SplitN(qualifier, "=", 2)[0]
+	if sequenceQualifiers[qualifier] {
+		return ""
+	}
+	return " "
+}
+
 func getFeatures(lines []string) []Feature {
-	lineIndex := 0
 	features := []Feature{}
 
-	// regex to remove quotes and slashes from qualifiers
-	reg, _ := regexp.Compile("[\"/\n]+")
+	// regex to remove quotes and newlines from qualifiers
+	reg, _ := regexp.Compile("[\"\n]+")
+
+	// blank lines between or inside features carry nothing, so they're skipped wherever they appear rather than ending
+	// the features early. Running out of lines reads as the end of the record.
+	lineIndex := -1
+	nextLine := func() string {
+		for lineIndex++; lineIndex < len(lines) && strings.TrimSpace(lines[lineIndex]) == ""; lineIndex++ {
+		}
+		if lineIndex >= len(lines) {
+			return "//"
+		}
+		return lines[lineIndex]
+	}
 
 	// go through every line.
+	line := nextLine()
 	for lineIndex < len(lines) {
-		line := lines[lineIndex]
 		// This is a break to ensure that cursor doesn't go beyond ORIGIN which is the last top level feature.
 		// This could pick up random sequence strings that aren't helpful and will mess with parser.
 		// DO NOT MOVE/REMOVE WITHOUT CAUSE AND CONSIDERATION
@@ -773,18 +1674,28 @@ func getFeatures(lines []string) []Feature {
 		feature := Feature{}
 
 		// split the current line for feature type and location fields.
-		splitLine := strings.Split(strings.TrimSpace(line), " ")
+		splitLine := strings.Fields(line)
 
-		// assign type and location to feature.
-		feature.Type = strings.TrimSpace(splitLine[0])
-		feature.Location = strings.TrimSpace(splitLine[len(splitLine)-1])
+		// assign type and location to feature. Everything after the type is location since locations can break after
+		// a comma.
+		feature.Type = splitLine[0]
+		var locationBuilder strings.Builder
+		locationBuilder.WriteString(strings.Join(splitLine[1:], ""))
 
 		// initialize attributes.
 		feature.Attributes = make(map[string]string)
 
-		// end of feature declaration line. Bump to next line and begin looking for qualifiers.
-		lineIndex++
-		line = lines[lineIndex]
+		// end of feature declaration line. Bump to next line, where long locations continue before any qualifiers.
+		line = nextLine()
+		for quickQualifierSubLineCheck(line) {
+			locationBuilder.WriteString(strings.TrimSpace(line))
+			line = nextLine()
+		}
+		feature.Location = locationBuilder.String()
+		// the outer span and strand give features read from genbank real coordinates when they're written to gff.
+		featureLocation := ParseLocation(feature.Location)
+		feature.Start, feature.End = featureLocation.Start, featureLocation.End
+		feature.Strand = locationStrand(featureLocation)
 
 		// loop through potential qualifiers. Break if not a qualifier or sub line.
 		// Definition of qualifiers here: http://www.insdc.org/files/feature_table.html#3.3
@@ -794,11 +1705,13 @@ func getFeatures(lines []string) []Feature {
 			if !quickQualifierCheck(line) {
 				break
 			}
-			qualifier := line
+			// using a builder since qualifiers like /translation can wrap across dozens of lines.
+			var qualifierBuilder strings.Builder
+			qualifierBuilder.WriteString(line)
+			separator := qualifierContinuationSeparator(line)
 
 			// end of qualifier declaration line. Bump to next line and begin looking for qualifier sublines.
-			lineIndex++
-			line = lines[lineIndex]
+			line = nextLine()
 
 			// loop through any potential continuing lines of qualifiers. Break if not.
 			for {
@@ -807,25 +1720,41 @@ func getFeatures(lines []string) []Feature {
 					break
 				}
 				//append to current qualifier
-				qualifier += strings.TrimSpace(line)
+				qualifierBuilder.WriteString(separator + strings.TrimSpace(line))
 
 				// nextline
-				lineIndex++
-				line = lines[lineIndex]
+				line = nextLine()
 			}
 			//add qualifier to feature.
-			attributeSplit := strings.Split(reg.ReplaceAllString(qualifier, ""), "=")
+			// only the leading slash is removed since values like /db_xref="UniProtKB/Swiss-Prot:P0AD86" contain slashes.
+			qualifier := strings.TrimPrefix(strings.TrimSpace(qualifierBuilder.String()), "/")
+			attributeSplit := strings.SplitN(reg.ReplaceAllString(qualifier, ""), "=", 2)
 			attributeLabel := strings.TrimSpace(attributeSplit[0])
 			var attributeValue string
 			if len(attributeSplit) < 2 {
-				attributeValue = ""
+				// flag qualifiers like /pseudo have no value. They're stored as "true", which is also how gff writes them,
+				// and marked so a quoted /note="true" isn't mistaken for one.
+				attributeValue = "true"
+				if feature.FlagQualifiers == nil {
+					feature.FlagQualifiers = make(map[string]bool)
+				}
+				feature.FlagQualifiers[attributeLabel] = true
 			} else {
 				attributeValue = strings.TrimSpace(attributeSplit[1])
 			}
-			feature.Attributes[attributeLabel] = attributeValue
+			// qualifiers like /db_xref can repeat. Attributes keeps the first value and the rest go to RepeatedQualifiers.
+			if _, repeated := feature.Attributes[attributeLabel]; repeated {
+				if feature.RepeatedQualifiers == nil {
+					feature.RepeatedQualifiers = make(map[string][]string)
+				}
+				feature.RepeatedQualifiers[attributeLabel] = append(feature.RepeatedQualifiers[attributeLabel], attributeValue)
+			} else {
+				feature.Attributes[attributeLabel] = attributeValue
+			}
 		}
 
 		//append the parsed feature to the features list to be returned.
+		feature.Provenance = &Provenance{Tool: "ParseGbk"}
 		features = append(features, feature)
 
 	}
@@ -840,15 +1769,71 @@ func getSequence(subLines []string) Sequence {
 	if err != nil {
 		log.Fatal(err)
 	}
-	for _, subLine := range subLines {
+	for numSubLine, subLine := range subLines {
+		// "//" marks the end of the record.
+		if strings.HasPrefix(subLine, "//") {
+			break
+		}
+		// line numbers and spacing are expected to be stripped. Anything else getting stripped means the ORIGIN block is corrupt.
+		if unexpected := unexpectedOriginCharacters(subLine); unexpected != "" {
+			log.Printf("removing unexpected characters %q from line %d of ORIGIN: %q", unexpected, numSubLine+1, subLine)
+		}
 		sequenceBuffer.WriteString(subLine)
 	}
 	sequence.Sequence = reg.ReplaceAllString(sequenceBuffer.String(), "")
 	return sequence
 }
 
-// ParseGbk takes in a string representing a gbk/gb/genbank file and parses it into an AnnotatedSequence object.
+// returns every character in an ORIGIN line that isn't a letter, digit, or whitespace.
+func unexpectedOriginCharacters(originLine string) string {
+	var unexpected strings.Builder
+	for _, character := range originLine {
+		isLetter := (character >= 'a' && character <= 'z') || (character >= 'A' && character <= 'Z')
+		if !isLetter && !unicode.IsDigit(character) && !unicode.IsSpace(character) {
+			unexpected.WriteRune(character)
+		}
+	}
+	return unexpected.String()
+}
+
+// ParseGbk takes in a string representing a gbk/gb/genbank file and parses it into an AnnotatedSequence object. Only the
+// first record of a multi-record file is parsed, use ParseGbkMulti to get all of them.
 func ParseGbk(gbk string) AnnotatedSequence {
+	record, _ := splitGbkRecord(gbk)
+	return parseGbkRecord(record)
+}
+
+// ParseGbkMulti takes in a string representing a gbk/gb/genbank file with any number of records, each ending in a "//"
+// line, and parses every one of them into an AnnotatedSequence object in the order they appear. Anything after the last
+// "//" is parsed as a final record unless it's only whitespace.
+func ParseGbkMulti(gbk string) []AnnotatedSequence {
+	var annotatedSequences []AnnotatedSequence
+	for strings.TrimSpace(gbk) != "" {
+		var record string
+		record, gbk = splitGbkRecord(gbk)
+		annotatedSequences = append(annotatedSequences, parseGbkRecord(record))
+	}
+	return annotatedSequences
+}
+
+// splits the first record, up to and including its "//" line, from the rest of a genbank file. The whole file is the
+// record if it has no "//" line.
+func splitGbkRecord(gbk string) (record, rest string) {
+	for lineStart := 0; lineStart < len(gbk); {
+		lineEnd := len(gbk)
+		if newlineIndex := strings.IndexByte(gbk[lineStart:], '\n'); newlineIndex != -1 {
+			lineEnd = lineStart + newlineIndex + 1
+		}
+		if strings.TrimSpace(gbk[lineStart:lineEnd]) == "//" {
+			return gbk[:lineEnd], gbk[lineEnd:]
+		}
+		lineStart = lineEnd
+	}
+	return gbk, ""
+}
+
+// parses a single genbank record into an AnnotatedSequence.
+func parseGbkRecord(gbk string) AnnotatedSequence {
 
 	lines := strings.Split(gbk, "\n")
 
@@ -860,20 +1845,21 @@ func ParseGbk(gbk string) AnnotatedSequence {
 
 	// Create sequence struct
 	sequence := Sequence{}
+	var seguid string
 
+	// This is to keep the cursor from scrolling to the bottom another time after getSequence() is called.
+	// Break has to be in scope and can't be called within switch statement.
+	// Otherwise it will just break the switch which is redundant.
+	// The flag is declared outside the loop so it survives to the next line, which is where the break happens.
+	sequenceBreakFlag := false
 	for numLine := 0; numLine < len(lines); numLine++ {
+		if sequenceBreakFlag {
+			break
+		}
 		line := lines[numLine]
 		splitLine := strings.Split(line, " ")
 		subLines := lines[numLine+1:]
 
-		// This is to keep the cursor from scrolling to the bottom another time after getSequence() is called.
-		// Break has to be in scope and can't be called within switch statement.
-		// Otherwise it will just break the switch which is redundant.
-		sequenceBreakFlag := false
-		if sequenceBreakFlag == true {
-			break
-		}
-
 		switch splitLine[0] {
 
 		case "":
@@ -889,12 +1875,20 @@ func ParseGbk(gbk string) AnnotatedSequence {
 		case "KEYWORDS":
 			meta.Keywords = joinSubLines(splitLine, subLines)
 		case "SOURCE":
-			meta.Source, meta.Organism = getSourceOrganism(splitLine, subLines)
+			meta.Source, meta.Organism, meta.Taxonomy = getSourceOrganism(splitLine, subLines)
 		case "REFERENCE":
 			meta.References = append(meta.References, getReference(splitLine, subLines))
 			continue
 		case "FEATURES":
 			features = getFeatures(subLines)
+		case "COMMENT":
+			// the checksum GbkOptions.IncludeChecksum writes.
+			if comment := joinSubLines(splitLine, subLines); strings.HasPrefix(comment, gbkSeguidComment) {
+				seguid = strings.TrimSpace(strings.TrimPrefix(comment, gbkSeguidComment))
+			}
+		case "CONTIG":
+			// the join expression can wrap anywhere, so all whitespace is insignificant.
+			meta.Contig = strings.Join(strings.Fields(joinSubLines(splitLine, subLines)), "")
 		case "ORIGIN":
 			sequence = getSequence(subLines)
 			sequenceBreakFlag = true
@@ -903,6 +1897,10 @@ func ParseGbk(gbk string) AnnotatedSequence {
 		}
 
 	}
+	meta.TaxonID = getSourceTaxonID(features)
+	sequence.Quality = getSourceQuality(features, len(sequence.Sequence))
+	sequence.Seguid = seguid
+
 	var annotatedSequence AnnotatedSequence
 	annotatedSequence.Meta = meta
 	annotatedSequence.Features = features
@@ -911,6 +1909,136 @@ func ParseGbk(gbk string) AnnotatedSequence {
 	return annotatedSequence
 }
 
+// returns the NCBI taxon id from the first source feature's /db_xref="taxon:..." qualifier, or an empty string if there
+// isn't one.
+func getSourceTaxonID(features []Feature) string {
+	for _, feature := range features {
+		if feature.Type != "source" {
+			continue
+		}
+		for _, dbxref := range feature.QualifierValues("db_xref") {
+			if strings.HasPrefix(dbxref, "taxon:") {
+				return strings.TrimPrefix(dbxref, "taxon:")
+			}
+		}
+	}
+	return ""
+}
+
+// returns the phred quality score of every base from the first source feature's /phred qualifier, which Sanger and
+// consensus tools write as one score per base separated by spaces or commas. Parsed scores are removed from the source
+// feature so Sequence.Quality is the only copy, and BuildGbk writes them back from there. Returns nil, leaving /phred
+// where it is, if there's no /phred, or if it can't be parsed or doesn't have a score for every base, which is logged.
+func getSourceQuality(features []Feature, sequenceLength int) []int {
+	for _, feature := range features {
+		if feature.Type != "source" {
+			continue
+		}
+		phred, ok := feature.Attributes["phred"]
+		if !ok {
+			return nil
+		}
+		scores := strings.FieldsFunc(phred, func(character rune) bool {
+			return character == ',' || unicode.IsSpace(character)
+		})
+		if len(scores) != sequenceLength {
+			log.Printf("ignoring /phred with %d quality scores for %d bases", len(scores), sequenceLength)
+			return nil
+		}
+		quality := make([]int, len(scores))
+		for scoreIndex, score := range scores {
+			var err error
+			if quality[scoreIndex], err = strconv.Atoi(score); err != nil || quality[scoreIndex] < 0 {
+				log.Printf("ignoring /phred with quality score %q, expected a whole number of at least 0", score)
+				return nil
+			}
+		}
+		delete(feature.Attributes, "phred")
+		delete(feature.RepeatedQualifiers, "phred")
+		return quality
+	}
+	return nil
+}
+
+// returns quality scores as a /phred value, separated by spaces so long values wrap between scores.
+func formatPhred(quality []int) string {
+	scores := make([]string, len(quality))
+	for scoreIndex, score := range quality {
+		scores[scoreIndex] = strconv.Itoa(score)
+	}
+	return strings.Join(scores, " ")
+}
+
+// unknownContigGapLength is how many Ns ResolveContig uses for a gap() of unknown length, the same length NCBI uses.
+const unknownContigGapLength = 100
+
+// ResolveContig builds the sequence of a scaffold record whose sequence is defined by a CONTIG line (Meta.Contig) joining
+// parts of other records rather than by an ORIGIN block. lookup is called once per accession the CONTIG line references,
+// like AE000001.1, and should return that record, typically by reading it from disk or fetching it from NCBI. Parts like
+// complement(AE000002.1:1..500) are reverse complemented and gap(100) and gap(unk100) become runs of N, with gap() of
+// unknown length 100 Ns long. Returns an error if there's no CONTIG line, it can't be parsed, a lookup fails, or a part
+// is outside of the record it refers to.
+func (annotatedSequence AnnotatedSequence) ResolveContig(lookup func(accession string) (AnnotatedSequence, error)) (string, error) {
+	contig := annotatedSequence.Meta.Contig
+	if contig == "" {
+		return "", fmt.Errorf("record has no CONTIG line")
+	}
+	if strings.HasPrefix(contig, "join(") && strings.HasSuffix(contig, ")") {
+		contig = contig[len("join(") : len(contig)-1]
+	}
+
+	records := make(map[string]AnnotatedSequence)
+	var sequenceBuilder strings.Builder
+	for _, part := range splitTopLevelCommas(contig) {
+		if strings.HasPrefix(part, "gap(") && strings.HasSuffix(part, ")") {
+			gapLength := strings.TrimPrefix(part[len("gap("):len(part)-1], "unk")
+			if gapLength == "" {
+				sequenceBuilder.WriteString(strings.Repeat("N", unknownContigGapLength))
+				continue
+			}
+			length, err := strconv.Atoi(gapLength)
+			if err != nil || length < 0 {
+				return "", fmt.Errorf("malformed CONTIG gap %q", part)
+			}
+			sequenceBuilder.WriteString(strings.Repeat("N", length))
+			continue
+		}
+
+		complement := strings.HasPrefix(part, "complement(") && strings.HasSuffix(part, ")")
+		if complement {
+			part = part[len("complement(") : len(part)-1]
+		}
+		colonIndex := strings.LastIndex(part, ":")
+		if colonIndex == -1 {
+			return "", fmt.Errorf("malformed CONTIG part %q, expected accession:start..end", part)
+		}
+		accession := part[:colonIndex]
+		partLocation, err := parseLocation(part[colonIndex+1:])
+		if err != nil || partLocation.Join || partLocation.Complement {
+			return "", fmt.Errorf("malformed CONTIG part %q, expected accession:start..end", part)
+		}
+
+		record, ok := records[accession]
+		if !ok {
+			record, err = lookup(accession)
+			if err != nil {
+				return "", fmt.Errorf("could not look up CONTIG accession %s: %w", accession, err)
+			}
+			records[accession] = record
+		}
+		recordSequence := record.Sequence.Sequence
+		if partLocation.Start < 1 || partLocation.End > len(recordSequence) || partLocation.Start > partLocation.End {
+			return "", fmt.Errorf("CONTIG part %q is outside of %s, which has length %d", part, accession, len(recordSequence))
+		}
+		partSequence := recordSequence[partLocation.Start-1 : partLocation.End]
+		if complement {
+			partSequence = ReverseComplement(partSequence)
+		}
+		sequenceBuilder.WriteString(partSequence)
+	}
+	return sequenceBuilder.String(), nil
+}
+
 // ReadGbk reads a Gbk from path and parses into an Annotated sequence struct.
 func ReadGbk(path string) AnnotatedSequence {
 	file, err := ioutil.ReadFile(path)
@@ -925,37 +2053,1848 @@ func ReadGbk(path string) AnnotatedSequence {
 	return annotatedSequence
 }
 
-/******************************************************************************
+// ReadGbkMulti reads a genbank file with any number of records from path and parses every one of them into an
+// AnnotatedSequence struct. Returns nil if the file can't be read.
+func ReadGbkMulti(path string) []AnnotatedSequence {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return ParseGbkMulti(string(file))
+}
 
-GBK specific IO related things end here.
+// ReadGbkMeta reads only the Meta of the first record in a genbank file, stopping after the first feature, the source
+// feature that TaxonID comes from, so neither the rest of the features nor the sequence are read. This makes cataloging
+// the accessions, organisms, and lengths of large numbers of records much faster than ReadGbk.
+func ReadGbkMeta(path string) (Meta, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Meta{}, err
+	}
+	defer file.Close()
+
+	var headerBuilder strings.Builder
+	reader := bufio.NewReader(file)
+	inFeatures := false
+	featureCount := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, "ORIGIN") || strings.HasPrefix(line, "//") {
+			break
+		}
+		if strings.HasPrefix(line, "FEATURES") {
+			inFeatures = true
+		} else if inFeatures && len(line) > subMetaIndex && quickFeatureCheck(line) {
+			featureCount++
+			if featureCount > 1 {
+				break
+			}
+		}
+		headerBuilder.WriteString(line)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Meta{}, err
+		}
+	}
+	if inFeatures {
+		// the feature parser needs a line after the source feature to know it has ended.
+		headerBuilder.WriteString("ORIGIN\n")
+	}
+	if !strings.HasPrefix(headerBuilder.String(), "LOCUS") {
+		return Meta{}, fmt.Errorf("%s does not start with a LOCUS line", path)
+	}
+	return ParseGbk(headerBuilder.String()).Meta, nil
+}
 
-******************************************************************************/
+// ReadGbkVerified reads a Gbk from path like ReadGbk but returns an error if the file can't be read or the SEGUID of its
+// sequence doesn't match expectedSEGUID, catching truncated or corrupted files before they're used.
+func ReadGbkVerified(path, expectedSEGUID string) (AnnotatedSequence, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return AnnotatedSequence{}, err
+	}
+	annotatedSequence := ParseGbk(string(file))
+	if seguid := Seguid(annotatedSequence.Sequence.Sequence); seguid != expectedSEGUID {
+		return AnnotatedSequence{}, fmt.Errorf("%s has SEGUID %s, expected %s", path, seguid, expectedSEGUID)
+	}
+	return annotatedSequence, nil
+}
 
-/******************************************************************************
+// RecordOffset holds the name of a genbank record and the byte offset of its LOCUS line within a file.
+type RecordOffset struct {
+	Name   string
+	Offset int64
+}
 
-JSON specific IO related things begin here.
+// IndexGbk scans a multi-record genbank file and returns the name and byte offset of every record in it without parsing
+// them, so individual records can later be read with ReadGbkAt.
+func IndexGbk(path string) ([]RecordOffset, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var recordOffsets []RecordOffset
+	var offset int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, "LOCUS") {
+			var name string
+			if locusFields := strings.Fields(line); len(locusFields) > 1 {
+				name = locusFields[1]
+			}
+			recordOffsets = append(recordOffsets, RecordOffset{Name: name, Offset: offset})
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return recordOffsets, nil
+}
 
-******************************************************************************/
+// ReadGbkAt reads and parses the single genbank record starting at a byte offset within a file, like one returned by
+// IndexGbk. Reading stops at the record's terminating "//" line.
+func ReadGbkAt(path string, offset int64) (AnnotatedSequence, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return AnnotatedSequence{}, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return AnnotatedSequence{}, err
+	}
 
-// WriteJSON writes an AnnotatedSequence struct out to json.
-func WriteJSON(annotatedSequence AnnotatedSequence, path string) {
-	file, _ := json.MarshalIndent(annotatedSequence, "", " ")
-	_ = ioutil.WriteFile(path, file, 0644)
+	var recordBuilder strings.Builder
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if recordBuilder.Len() == 0 && !strings.HasPrefix(line, "LOCUS") {
+			return AnnotatedSequence{}, fmt.Errorf("no genbank record starts at byte %d of %s", offset, path)
+		}
+		recordBuilder.WriteString(line)
+		if strings.TrimSpace(line) == "//" || err == io.EOF {
+			break
+		}
+		if err != nil {
+			return AnnotatedSequence{}, err
+		}
+	}
+	return ParseGbk(recordBuilder.String()), nil
 }
 
-// ReadJSON reads an AnnotatedSequence JSON file.
-func ReadJSON(path string) AnnotatedSequence {
-	file, err := ioutil.ReadFile(path)
+// ReadErrors collects the errors from reading many records at once, so one bad record doesn't stop the rest from being read.
+type ReadErrors []error
+
+// Error lists every error in order.
+func (readErrors ReadErrors) Error() string {
+	messages := make([]string, len(readErrors))
+	for index, err := range readErrors {
+		messages[index] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ReadGbkTarGz reads every genbank record in the genbank files (.gbk, .gb, or .genbank) of a gzipped tar archive, like the
+// ones NCBI datasets ships, without extracting it to disk. Members are decompressed one at a time as the archive streams
+// and parsed by workers goroutines concurrently. Every record of a multi-record member is read, and records come back in
+// the order they appear in the archive. Members that can't be
+// parsed are skipped and their errors returned together as ReadErrors alongside the records that could be, while a
+// corrupt archive stops reading altogether.
+func ReadGbkTarGz(path string, workers int) ([]AnnotatedSequence, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		// return 0, fmt.Errorf("Failed to open file %s for unpack: %s", gzFilePath, err)
+		return nil, err
 	}
-	var annotatedSequence AnnotatedSequence
-	json.Unmarshal([]byte(file), &annotatedSequence)
-	return annotatedSequence
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress %s: %w", path, err)
+	}
+	defer gzipReader.Close()
+	if workers < 1 {
+		workers = 1
+	}
+
+	type member struct {
+		index int
+		name  string
+		gbk   string
+	}
+	type parsedMember struct {
+		index              int
+		annotatedSequences []AnnotatedSequence
+		err                error
+	}
+	members := make(chan member)
+	parsedMembers := make(chan parsedMember)
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for member := range members {
+				annotatedSequences, err := parseGbkMember(member.name, member.gbk)
+				parsedMembers <- parsedMember{member.index, annotatedSequences, err}
+			}
+		}()
+	}
+
+	// members are read on their own goroutine so results can be collected while the archive is still streaming.
+	archiveErr := make(chan error, 1)
+	go func() {
+		defer close(members)
+		tarReader := tar.NewReader(gzipReader)
+		for index := 0; ; {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				archiveErr <- nil
+				return
+			}
+			if err != nil {
+				archiveErr <- fmt.Errorf("could not read %s: %w", path, err)
+				return
+			}
+			if header.Typeflag != tar.TypeReg || formatsByExtension[strings.ToLower(filepath.Ext(header.Name))] != "gbk" {
+				continue
+			}
+			gbk, err := ioutil.ReadAll(tarReader)
+			if err != nil {
+				archiveErr <- fmt.Errorf("could not read %s from %s: %w", header.Name, path, err)
+				return
+			}
+			members <- member{index, header.Name, string(gbk)}
+			index++
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(parsedMembers)
+	}()
+
+	var results []parsedMember
+	for parsed := range parsedMembers {
+		results = append(results, parsed)
+	}
+	if err := <-archiveErr; err != nil {
+		return nil, err
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].index < results[j].index })
+
+	var annotatedSequences []AnnotatedSequence
+	var readErrors ReadErrors
+	for _, result := range results {
+		if result.err != nil {
+			readErrors = append(readErrors, result.err)
+			continue
+		}
+		annotatedSequences = append(annotatedSequences, result.annotatedSequences...)
+	}
+	if readErrors != nil {
+		return annotatedSequences, readErrors
+	}
+	return annotatedSequences, nil
 }
 
-/******************************************************************************
+// parses every record of a genbank file read from an archive. Returns an error naming the file if it isn't genbank.
+func parseGbkMember(name, gbk string) ([]AnnotatedSequence, error) {
+	if !strings.HasPrefix(gbk, "LOCUS") {
+		return nil, fmt.Errorf("%s is not a genbank file, it does not start with LOCUS", name)
+	}
+	return ParseGbkMulti(gbk), nil
+}
 
-JSON specific IO related things end here.
+// MinimalGbk wraps a bare sequence in the smallest AnnotatedSequence that still makes a valid genbank record: a LOCUS with
+// the sequence's length, molecule type, topology, and a placeholder date, plus a single source feature spanning the whole
+// sequence. Sequences containing U are treated as RNA and everything else as DNA.
+func MinimalGbk(name, sequence string, circular bool) AnnotatedSequence {
+	moleculeType := "DNA"
+	if strings.ContainsAny(sequence, "Uu") {
+		moleculeType = "RNA"
+	}
+	source := Feature{
+		Type: "source",
+		Attributes: map[string]string{
+			"organism": "synthetic construct",
+			"mol_type": "other " + moleculeType,
+		},
+	}
+	source.setLocation(Location{Start: 1, End: len(sequence)})
+	return AnnotatedSequence{
+		Meta: Meta{
+			Name:       name,
+			Definition: name,
+			Locus: Locus{
+				Name:            name,
+				SequenceLength:  strconv.Itoa(len(sequence)) + " bp",
+				MoleculeType:    moleculeType,
+				GenBankDivision: "SYN",
+				ModDate:         gbkPlaceholderDate,
+				Circular:        circular,
+			},
+		},
+		Features: []Feature{source},
+		Sequence: Sequence{Description: name, Sequence: sequence},
+	}
+}
+
+// gbkSeguidComment starts the COMMENT line that GbkOptions.IncludeChecksum writes the sequence's SEGUID to.
+const gbkSeguidComment = "SEGUID "
+
+// gbkPlaceholderDate is the LOCUS date of records that don't have one, since genbank requires a date.
+const gbkPlaceholderDate = "01-JAN-1980"
+
+// genbank lines are at most 79 characters. Header values start at column 12 and feature locations and qualifiers at
+// column 21, the qualifierIndex.
+const gbkLineWidth = 79
+const gbkHeaderIndex = 12
+
+// unquotedQualifiers are qualifiers whose values genbank writes without quotes, like /codon_start=1.
+var unquotedQualifiers = map[string]bool{
+	"anticodon":        true,
+	"citation":         true,
+	"codon_start":      true,
+	"compare":          true,
+	"direction":        true,
+	"estimated_length": true,
+	"mod_base":         true,
+	"number":           true,
+	"rpt_type":         true,
+	"rpt_unit_range":   true,
+	"tag_peptide":      true,
+	"transl_except":    true,
+	"transl_table":     true,
+}
+
+// flagQualifiers are the INSDC qualifiers that have no value, like /pseudo. They're stored as "true" and written bare.
+var flagQualifiers = map[string]bool{
+	"circular_RNA":         true,
+	"environmental_sample": true,
+	"focus":                true,
+	"germline":             true,
+	"macronuclear":         true,
+	"partial":              true,
+	"proviral":             true,
+	"pseudo":               true,
+	"rearranged":           true,
+	"ribosomal_slippage":   true,
+	"trans_splicing":       true,
+	"transgenic":           true,
+}
+
+// GbkOptions controls what BuildGbkWithOptions includes in a genbank file.
+type GbkOptions struct {
+	IncludeChecksum bool         // write the sequence's SEGUID in a "COMMENT     SEGUID" line before the features.
+	Case            SequenceCase // case of the sequence in the ORIGIN block.
+}
+
+// BuildGbk takes an AnnotatedSequence and returns a byte array representing a genbank file to be written out.
+func BuildGbk(annotatedSequence AnnotatedSequence) []byte {
+	return BuildGbkWithOptions(annotatedSequence, GbkOptions{})
+}
+
+// BuildGbkWithOptions takes an AnnotatedSequence and GbkOptions and returns a byte array representing a genbank file to
+// be written out. The LOCUS line, DEFINITION, ACCESSION, VERSION, KEYWORDS, SOURCE, REFERENCE, CONTIG, FEATURES, and
+// ORIGIN blocks are written in NCBI's layout so ParseGbk reads the file back into an equivalent AnnotatedSequence. The
+// feature table follows INSDC's fixed columns, with keys at column 6 and locations and qualifiers at column 22.
+// Qualifiers are written in alphabetical order since Attributes doesn't keep the order they were read in, and qualifiers
+// with the value "true" are written as flags like /pseudo. Features without a Location, like those read from a gff, get
+// one made from their Start, End, and Strand.
+func BuildGbkWithOptions(annotatedSequence AnnotatedSequence, options GbkOptions) []byte {
+	var gbkBuffer bytes.Buffer
+	meta := annotatedSequence.Meta
+	sequence := annotatedSequence.Sequence.Sequence
+
+	gbkBuffer.WriteString(formatGbkLocus(meta, sequence))
+	writeGbkField(&gbkBuffer, "DEFINITION", meta.Definition)
+	writeGbkField(&gbkBuffer, "ACCESSION", meta.Accession)
+	writeGbkField(&gbkBuffer, "VERSION", meta.Version)
+	writeGbkField(&gbkBuffer, "KEYWORDS", meta.Keywords)
+
+	// ParseGbk expects an ORGANISM line after SOURCE, so it's written even when the organism is unknown.
+	if meta.Source != "" || meta.Organism != "" || len(meta.Taxonomy) > 0 {
+		writeGbkField(&gbkBuffer, "SOURCE", meta.Source)
+		gbkBuffer.WriteString(strings.TrimRight("  ORGANISM  "+meta.Organism, " ") + "\n")
+		if len(meta.Taxonomy) > 0 {
+			writeGbkField(&gbkBuffer, "", strings.Join(meta.Taxonomy, "; ")+".")
+		}
+	}
+
+	for _, reference := range meta.References {
+		writeGbkField(&gbkBuffer, "REFERENCE", strings.TrimSpace(reference.Index+"  "+reference.Range))
+		writeGbkField(&gbkBuffer, "  AUTHORS", reference.Authors)
+		writeGbkField(&gbkBuffer, "  TITLE", reference.Title)
+		writeGbkField(&gbkBuffer, "  JOURNAL", reference.Journal)
+		writeGbkField(&gbkBuffer, "   PUBMED", reference.PubMed)
+		writeGbkField(&gbkBuffer, "  REMARK", reference.Remark)
+	}
+
+	if options.IncludeChecksum {
+		writeGbkField(&gbkBuffer, "COMMENT", gbkSeguidComment+Seguid(sequence))
+	}
+
+	gbkBuffer.WriteString(fmt.Sprintf("%-*sLocation/Qualifiers\n", qualifierIndex, "FEATURES"))
+	quality := annotatedSequence.Sequence.Quality
+	for _, feature := range annotatedSequence.Features {
+		// quality is written as /phred on the first source feature, the same place ParseGbk reads it from.
+		if feature.Type == "source" && quality != nil {
+			feature = feature.WithAttribute("phred", formatPhred(quality))
+			quality = nil
+		}
+		gbkBuffer.WriteString(formatGbkFeature(feature))
+	}
+
+	// the join expression can be broken anywhere since ParseGbk ignores whitespace in it.
+	if meta.Contig != "" {
+		for lineIndex, line := range chunkString(meta.Contig, gbkLineWidth-gbkHeaderIndex) {
+			if lineIndex == 0 {
+				gbkBuffer.WriteString("CONTIG      " + line + "\n")
+			} else {
+				gbkBuffer.WriteString(strings.Repeat(" ", gbkHeaderIndex) + line + "\n")
+			}
+		}
+	}
+
+	// scaffold records made of a CONTIG join don't have a sequence of their own.
+	if sequence != "" || meta.Contig == "" {
+		gbkBuffer.WriteString("ORIGIN      \n")
+		for lineIndex, line := range chunkString(options.Case.apply(sequence), 60) {
+			gbkBuffer.WriteString(fmt.Sprintf("%9d", lineIndex*60+1))
+			for _, block := range chunkString(line, 10) {
+				gbkBuffer.WriteString(" " + block)
+			}
+			gbkBuffer.WriteString("\n")
+		}
+	}
+	gbkBuffer.WriteString("//\n")
+	return gbkBuffer.Bytes()
+}
+
+// returns the LOCUS line of a genbank file. The sequence's length is used over Locus.SequenceLength unless there's no
+// sequence, and missing fields are filled in since ParseGbk needs every one of them.
+func formatGbkLocus(meta Meta, sequence string) string {
+	locus := meta.Locus
+	name := gbkRecordName(meta)
+
+	length, unit := strconv.Itoa(len(sequence)), "bp"
+	if lengthFields := strings.Fields(locus.SequenceLength); len(lengthFields) == 2 {
+		if sequence == "" {
+			length = lengthFields[0]
+		}
+		unit = lengthFields[1]
+	}
+
+	// the first three characters are for the strandedness of the molecule, like ss-RNA.
+	moleculeType := locus.MoleculeType
+	if moleculeType == "" {
+		moleculeType = "DNA"
+	}
+	if !strings.HasPrefix(moleculeType, "ss-") && !strings.HasPrefix(moleculeType, "ds-") && !strings.HasPrefix(moleculeType, "ms-") {
+		moleculeType = "   " + moleculeType
+	}
+
+	topology := "linear"
+	if locus.Circular {
+		topology = "circular"
+	}
+
+	division := locus.GenBankDivision
+	if division == "" {
+		division = "UNK"
+	}
+	modDate := locus.ModDate
+	if modDate == "" {
+		modDate = gbkPlaceholderDate
+	}
+	return fmt.Sprintf("LOCUS       %-16s %11s %s %-9s  %-8s %s %s\n", name, length, unit, moleculeType, topology, division, modDate)
+}
+
+// returns the name a genbank record goes by, the LOCUS name, or failing that the same name BuildGff would use.
+func gbkRecordName(meta Meta) string {
+	if meta.Locus.Name != "" {
+		return meta.Locus.Name
+	}
+	name, _, _ := gffSequenceRegion(meta)
+	return name
+}
+
+// writes a header field like DEFINITION with its value starting at column 12, wrapping at spaces the way ParseGbk joins
+// continuation lines back together. Empty values aren't written.
+func writeGbkField(gbkBuffer *bytes.Buffer, keyword, value string) {
+	if value == "" {
+		return
+	}
+	for lineIndex, line := range wrapGbkText(value, gbkLineWidth-gbkHeaderIndex) {
+		if lineIndex == 0 {
+			gbkBuffer.WriteString(fmt.Sprintf("%-12s%s\n", keyword, line))
+		} else {
+			gbkBuffer.WriteString(strings.Repeat(" ", gbkHeaderIndex) + line + "\n")
+		}
+	}
+}
+
+// returns a feature's key line and qualifiers as they're written in a genbank feature table.
+func formatGbkFeature(feature Feature) string {
+	var featureBuilder strings.Builder
+	featureLocation := feature.Location
+	if featureLocation == "" {
+		parsedLocation, _ := feature.location()
+		featureLocation = formatLocation(parsedLocation)
+	}
+	featureType := feature.Type
+	if featureType == "" {
+		featureType = "misc_feature"
+	}
+	// INSDC puts the key at column 6 and the location at column 22, counting from 1, which are the subMetaIndex and
+	// qualifierIndex ParseGbk checks. Keys longer than the 15 columns between them still get a space before the location.
+	// Long locations continue on lines of their own, broken after a comma.
+	qualifierIndent := strings.Repeat(" ", qualifierIndex)
+	for lineIndex, line := range wrapGbkLocation(featureLocation, gbkLineWidth-qualifierIndex) {
+		if lineIndex == 0 {
+			featureBuilder.WriteString(fmt.Sprintf("%*s%-*s %s\n", subMetaIndex, "", qualifierIndex-subMetaIndex-1, featureType, line))
+		} else {
+			featureBuilder.WriteString(qualifierIndent + line + "\n")
+		}
+	}
+
+	qualifiers := make([]string, 0, len(feature.Attributes))
+	for qualifier := range feature.Attributes {
+		qualifiers = append(qualifiers, qualifier)
+	}
+	sort.Strings(qualifiers)
+
+	for _, qualifier := range qualifiers {
+		for _, value := range feature.QualifierValues(qualifier) {
+			var qualifierString string
+			if value == "true" && (flagQualifiers[qualifier] || feature.FlagQualifiers[qualifier]) {
+				qualifierString = "/" + qualifier
+			} else if unquotedQualifiers[qualifier] {
+				qualifierString = "/" + qualifier + "=" + value
+			} else {
+				qualifierString = "/" + qualifier + "=\"" + strings.Replace(value, "\"", "\"\"", -1) + "\""
+			}
+
+			var lines []string
+			if sequenceQualifiers[qualifier] {
+				lines = chunkString(qualifierString, gbkLineWidth-qualifierIndex)
+			} else {
+				lines = wrapGbkText(qualifierString, gbkLineWidth-qualifierIndex)
+			}
+			for _, line := range lines {
+				featureBuilder.WriteString(qualifierIndent + line + "\n")
+			}
+		}
+	}
+	return featureBuilder.String()
+}
+
+// splits a location into lines of at most width characters, breaking after commas. Locations without a comma to break at
+// are left whole.
+func wrapGbkLocation(locationString string, width int) []string {
+	var lines []string
+	for len(locationString) > width {
+		breakIndex := strings.LastIndex(locationString[:width], ",")
+		if breakIndex == -1 {
+			break
+		}
+		lines = append(lines, locationString[:breakIndex+1])
+		locationString = locationString[breakIndex+1:]
+	}
+	return append(lines, locationString)
+}
+
+// splits text into lines of at most width characters, breaking at spaces. Lines never start with "/" so they can't be
+// mistaken for a new qualifier, and words longer than width are left whole on a line of their own rather than broken
+// since ParseGbk would put a space in the break.
+func wrapGbkText(text string, width int) []string {
+	var lines []string
+	for len(text) > width {
+		breakIndex := -1
+		for index := width; index > 0; index-- {
+			if text[index] == ' ' && index+1 < len(text) && text[index+1] != '/' && text[index+1] != ' ' {
+				breakIndex = index
+				break
+			}
+		}
+		if breakIndex == -1 {
+			// no space to break at within width so the line runs long up to the next one.
+			for index := width + 1; index < len(text)-1; index++ {
+				if text[index] == ' ' && text[index+1] != '/' && text[index+1] != ' ' {
+					breakIndex = index
+					break
+				}
+			}
+			if breakIndex == -1 {
+				break
+			}
+		}
+		lines = append(lines, strings.TrimRight(text[:breakIndex], " "))
+		text = text[breakIndex+1:]
+	}
+	return append(lines, text)
+}
+
+// splits a string into chunks of chunkLength characters, the last of which may be shorter.
+func chunkString(text string, chunkLength int) []string {
+	var chunks []string
+	for len(text) > chunkLength {
+		chunks = append(chunks, text[:chunkLength])
+		text = text[chunkLength:]
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// WriteGbk takes an AnnotatedSequence struct and a path string and writes out a genbank file to that path.
+func WriteGbk(annotatedSequence AnnotatedSequence, path string) {
+	gbk := BuildGbk(annotatedSequence)
+	_ = ioutil.WriteFile(path, gbk, 0644)
+}
+
+// WriteGbkWithOptions takes an AnnotatedSequence struct, GbkOptions, and a path string and writes out a genbank file to
+// that path.
+func WriteGbkWithOptions(annotatedSequence AnnotatedSequence, options GbkOptions, path string) {
+	gbk := BuildGbkWithOptions(annotatedSequence, options)
+	_ = ioutil.WriteFile(path, gbk, 0644)
+}
+
+// WriteErrors collects the errors from writing many records at once, so one bad write doesn't stop the rest from being
+// written.
+type WriteErrors []error
+
+// Error lists every error in order.
+func (writeErrors WriteErrors) Error() string {
+	messages := make([]string, len(writeErrors))
+	for index, err := range writeErrors {
+		messages[index] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// WriteGbkEach writes every record to its own genbank file in dir, the inverse of reading a batch of records like
+// ReadGbkTarGz does. Records are built and written by workers goroutines concurrently. Each file is named after its
+// record's LOCUS name, or its accession if it has none, as dir/<name>.gbk. Records sharing a name are numbered in the
+// order they're given, so the second NC_000913 is written to NC_000913_2.gbk. Records that can't be written don't stop
+// the rest and their errors are returned together as WriteErrors in record order. Returns an error if dir can't be
+// created.
+func WriteGbkEach(records []AnnotatedSequence, dir string, workers int) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	// names are settled up front so collisions are numbered the same way however the writes are scheduled.
+	paths := make([]string, len(records))
+	usedNames := make(map[string]bool)
+	for recordIndex, record := range records {
+		name := unsafeFilenameCharacters.ReplaceAllString(gbkRecordName(record.Meta), "_")
+		for uniqueName, copyNumber := name, 2; ; copyNumber++ {
+			if !usedNames[uniqueName] {
+				name = uniqueName
+				break
+			}
+			uniqueName = name + "_" + strconv.Itoa(copyNumber)
+		}
+		usedNames[name] = true
+		paths[recordIndex] = filepath.Join(dir, name+".gbk")
+	}
+
+	recordIndexes := make(chan int)
+	writeErrs := make([]error, len(records))
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for recordIndex := range recordIndexes {
+				if err := ioutil.WriteFile(paths[recordIndex], BuildGbk(records[recordIndex]), 0644); err != nil {
+					writeErrs[recordIndex] = fmt.Errorf("could not write record %d to %s: %w", recordIndex, paths[recordIndex], err)
+				}
+			}
+		}()
+	}
+	for recordIndex := range records {
+		recordIndexes <- recordIndex
+	}
+	close(recordIndexes)
+	wg.Wait()
+
+	var writeErrors WriteErrors
+	for _, err := range writeErrs {
+		if err != nil {
+			writeErrors = append(writeErrors, err)
+		}
+	}
+	if writeErrors != nil {
+		return writeErrors
+	}
+	return nil
+}
+
+/******************************************************************************
+
+GBK specific IO related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+JSON specific IO related things begin here.
+
+******************************************************************************/
+
+// WriteJSON writes an AnnotatedSequence struct out to json.
+func WriteJSON(annotatedSequence AnnotatedSequence, path string) {
+	file, _ := json.MarshalIndent(annotatedSequence, "", " ")
+	_ = ioutil.WriteFile(path, file, 0644)
+}
+
+// JSONOptions holds options for WriteJSONWithOptions.
+type JSONOptions struct {
+	IncludeChecksum bool // fill in the Seguid field of the sequence so it can be verified after it's read back.
+}
+
+// WriteJSONWithOptions writes an AnnotatedSequence struct out to json following the given JSONOptions.
+func WriteJSONWithOptions(annotatedSequence AnnotatedSequence, options JSONOptions, path string) error {
+	if options.IncludeChecksum {
+		annotatedSequence.Sequence.Seguid = Seguid(annotatedSequence.Sequence.Sequence)
+	}
+	file, err := json.MarshalIndent(annotatedSequence, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, file, 0644)
+}
+
+// WriteJSONResolved writes an AnnotatedSequence struct out to json with every feature's Sequence filled in with the
+// subsequence it covers, so the json can be used without resolving locations against the parent sequence.
+func WriteJSONResolved(annotatedSequence AnnotatedSequence, path string) error {
+	// copying features so the caller's AnnotatedSequence isn't modified.
+	resolvedFeatures := make([]Feature, len(annotatedSequence.Features))
+	for featureIndex, feature := range annotatedSequence.Features {
+		featureSequence, err := annotatedSequence.FeatureSequence(feature)
+		if err != nil {
+			return fmt.Errorf("could not resolve sequence of %s feature %d: %w", feature.Type, featureIndex, err)
+		}
+		feature.Sequence = featureSequence
+		resolvedFeatures[featureIndex] = feature
+	}
+	annotatedSequence.Features = resolvedFeatures
+
+	file, err := json.MarshalIndent(annotatedSequence, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, file, 0644)
+}
+
+// ReadJSON reads an AnnotatedSequence JSON file.
+func ReadJSON(path string) AnnotatedSequence {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		// return 0, fmt.Errorf("Failed to open file %s for unpack: %s", gzFilePath, err)
+	}
+	var annotatedSequence AnnotatedSequence
+	json.Unmarshal([]byte(file), &annotatedSequence)
+	return annotatedSequence
+}
+
+/******************************************************************************
+
+JSON specific IO related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Feature table specific IO related things begin here.
+
+******************************************************************************/
+
+// BuildFeatureTable builds a tab separated table with a header row and one row per feature for opening in a spreadsheet or
+// dataframe. The columns type, start, end, strand, location, name, source, score, and phase come from the feature itself
+// and any other column is looked up as a qualifier, producing an empty cell if a feature doesn't have it. Start, end, and
+// strand are worked out from the location for genbank features. Tabs and newlines inside values are replaced with spaces.
+func BuildFeatureTable(annotatedSequence AnnotatedSequence, columns []string) []byte {
+	var tableBuffer bytes.Buffer
+	tableBuffer.WriteString(strings.Join(columns, "\t") + "\n")
+
+	cellCleaner := strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+	for _, feature := range annotatedSequence.Features {
+		start, end, strand := feature.Start, feature.End, feature.Strand
+		if feature.Location != "" {
+			if featureLocation, err := feature.location(); err == nil {
+				start, end = featureLocation.Start, featureLocation.End
+				if strand == "" && featureLocation.isMinusStrand() {
+					strand = "-"
+				} else if strand == "" {
+					strand = "+"
+				}
+			}
+		}
+
+		cells := make([]string, len(columns))
+		for columnIndex, column := range columns {
+			var cell string
+			switch column {
+			case "type":
+				cell = feature.Type
+			case "start":
+				cell = strconv.Itoa(start)
+			case "end":
+				cell = strconv.Itoa(end)
+			case "strand":
+				cell = strand
+			case "location":
+				cell = feature.Location
+			case "name":
+				cell = feature.Name
+			case "source":
+				cell = feature.Source
+			case "score":
+				cell = feature.Score
+			case "phase":
+				cell = feature.Phase
+			default:
+				cell = feature.Attributes[column]
+			}
+			cells[columnIndex] = cellCleaner.Replace(cell)
+		}
+		tableBuffer.WriteString(strings.Join(cells, "\t") + "\n")
+	}
+	return tableBuffer.Bytes()
+}
+
+/******************************************************************************
+
+Feature table specific IO related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+GenePred specific IO related things begin here.
+
+******************************************************************************/
+
+// BuildGenePred builds a UCSC genePred table with one row per transcript, for loading gene models into the genome browser
+// and tools like genePredToGtf. Each tab separated row has the columns name, chrom, strand, txStart, txEnd, cdsStart,
+// cdsEnd, exonCount, exonStarts, and exonEnds, with starts 0-based and ends 1-based exclusive, as is usual for UCSC, and
+// exon lists comma terminated.
+//
+// Gff transcripts are the features that exon or CDS features name as their Parent, with the transcript's exons, or its
+// CDS if it has no exons, as exons. Genbank files don't link features this way, so each mRNA is a transcript with its
+// segments as exons and the coding region of its own CDS: the one with the same /transcript_id, or else the first CDS
+// sharing its /gene or /locus_tag and strand that lies within its exons. CDS without an mRNA are transcripts of their
+// own. Non-coding transcripts have cdsStart and cdsEnd equal to txEnd.
+//
+// Transcripts are named after their transcript_id, ID, locus_tag, or protein_id, with any whitespace replaced by
+// underscores since the name is a single column. Transcripts with none of these are named after their type and span,
+// like mRNA_1000_1300.
+func BuildGenePred(annotatedSequence AnnotatedSequence) []byte {
+	chrom, _, _ := gffSequenceRegion(annotatedSequence.Meta)
+
+	type genePredTranscript struct {
+		feature Feature
+		exons   [][2]int
+		cds     [][2]int
+	}
+	var transcripts []*genePredTranscript
+
+	isGff := false
+	for _, feature := range annotatedSequence.Features {
+		isGff = isGff || len(feature.parents()) > 0
+	}
+	if isGff {
+		parts := make(map[string][]Feature)
+		for _, feature := range annotatedSequence.Features {
+			if feature.Type == "exon" || feature.Type == "CDS" {
+				for _, parent := range feature.parents() {
+					parts[parent] = append(parts[parent], feature)
+				}
+			}
+		}
+		for _, feature := range annotatedSequence.Features {
+			transcriptParts, ok := parts[feature.Attributes["ID"]]
+			if !ok {
+				continue
+			}
+			transcript := &genePredTranscript{feature: feature}
+			for _, part := range transcriptParts {
+				partLocation, err := part.location()
+				if err != nil {
+					continue
+				}
+				if part.Type == "exon" {
+					transcript.exons = append(transcript.exons, partLocation.segments()...)
+				} else {
+					transcript.cds = append(transcript.cds, partLocation.segments()...)
+				}
+			}
+			if transcript.exons == nil {
+				transcript.exons = transcript.cds
+			}
+			transcripts = append(transcripts, transcript)
+		}
+	} else {
+		geneKey := func(feature Feature) string {
+			if gene, ok := feature.Attributes["gene"]; ok {
+				return "gene " + gene
+			}
+			if locusTag, ok := feature.Attributes["locus_tag"]; ok {
+				return "locus_tag " + locusTag
+			}
+			return ""
+		}
+		for _, feature := range annotatedSequence.Features {
+			if feature.Type != "mRNA" {
+				continue
+			}
+			featureLocation, err := feature.location()
+			if err != nil {
+				continue
+			}
+			transcripts = append(transcripts, &genePredTranscript{feature: feature, exons: featureLocation.segments()})
+		}
+		mRNACount := len(transcripts)
+		for _, feature := range annotatedSequence.Features {
+			if feature.Type != "CDS" {
+				continue
+			}
+			featureLocation, err := feature.location()
+			if err != nil {
+				continue
+			}
+			cdsSegments := featureLocation.segments()
+
+			// a shared transcript_id pins a CDS to its mRNA, otherwise it goes to the first free mRNA of its gene it fits in.
+			match := -1
+			transcriptID, hasTranscriptID := feature.Attributes["transcript_id"]
+			for transcriptIndex, transcript := range transcripts[:mRNACount] {
+				if transcript.cds == nil && hasTranscriptID && transcript.feature.Attributes["transcript_id"] == transcriptID {
+					match = transcriptIndex
+					break
+				}
+			}
+			key := geneKey(feature)
+			for transcriptIndex := 0; match < 0 && key != "" && transcriptIndex < mRNACount; transcriptIndex++ {
+				transcript := transcripts[transcriptIndex]
+				transcriptLocation, _ := transcript.feature.location()
+				if transcript.cds == nil && geneKey(transcript.feature) == key &&
+					transcriptLocation.isMinusStrand() == featureLocation.isMinusStrand() && segmentsContain(transcript.exons, cdsSegments) {
+					match = transcriptIndex
+				}
+			}
+			if match >= 0 {
+				transcripts[match].cds = cdsSegments
+				continue
+			}
+			transcripts = append(transcripts, &genePredTranscript{feature: feature, exons: cdsSegments, cds: cdsSegments})
+		}
+	}
+
+	var genePredBuffer bytes.Buffer
+	for _, transcript := range transcripts {
+		if len(transcript.exons) == 0 {
+			continue
+		}
+		exons := append([][2]int(nil), transcript.exons...)
+		sort.Slice(exons, func(i, j int) bool { return exons[i][0] < exons[j][0] })
+		txStart, txEnd := exons[0][0], exons[0][1]
+		var exonStarts, exonEnds strings.Builder
+		for _, exon := range exons {
+			txStart, txEnd = minInt(txStart, exon[0]), maxInt(txEnd, exon[1])
+			exonStarts.WriteString(strconv.Itoa(exon[0]-1) + ",")
+			exonEnds.WriteString(strconv.Itoa(exon[1]) + ",")
+		}
+		// non-coding transcripts have an empty coding region at txEnd.
+		cdsStart, cdsEnd := txEnd+1, txEnd
+		if len(transcript.cds) > 0 {
+			cdsStart, cdsEnd = transcript.cds[0][0], transcript.cds[0][1]
+		}
+		for _, cdsSegment := range transcript.cds {
+			cdsStart, cdsEnd = minInt(cdsStart, cdsSegment[0]), maxInt(cdsEnd, cdsSegment[1])
+		}
+
+		transcriptChrom := chrom
+		if transcript.feature.Name != "" {
+			transcriptChrom = transcript.feature.Name
+		}
+		strand := transcript.feature.Strand
+		if strand != "+" && strand != "-" {
+			strand = "+"
+			if featureLocation, err := transcript.feature.location(); err == nil && featureLocation.isMinusStrand() {
+				strand = "-"
+			}
+		}
+		name := fmt.Sprintf("%s_%d_%d", strings.Join(strings.Fields(transcript.feature.Type), "_"), txStart-1, txEnd)
+		for _, nameKey := range []string{"transcript_id", "ID", "locus_tag", "protein_id"} {
+			if identifier := strings.Fields(transcript.feature.Attributes[nameKey]); len(identifier) > 0 {
+				name = strings.Join(identifier, "_")
+				break
+			}
+		}
+		columns := []string{
+			name,
+			transcriptChrom,
+			strand,
+			strconv.Itoa(txStart - 1),
+			strconv.Itoa(txEnd),
+			strconv.Itoa(cdsStart - 1),
+			strconv.Itoa(cdsEnd),
+			strconv.Itoa(len(exons)),
+			exonStarts.String(),
+			exonEnds.String(),
+		}
+		genePredBuffer.WriteString(strings.Join(columns, "\t") + "\n")
+	}
+	return genePredBuffer.Bytes()
+}
+
+/******************************************************************************
+
+GenePred specific IO related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+FASTA specific IO related things begin here.
+
+******************************************************************************/
+
+// BuildFasta takes a slice of Sequence structs and builds a fasta file with each sequence wrapped at 70 bases per line.
+// Each record's header is its Description with any leading ">" removed.
+func BuildFasta(sequences []Sequence) []byte {
+	return BuildFastaWithOptions(sequences, FastaOptions{})
+}
+
+// FastaOptions holds options for BuildFastaWithOptions and WriteFastaWithOptions.
+type FastaOptions struct {
+	Case SequenceCase // case of the written sequences, e.g. CaseAsIs to keep soft masking.
+}
+
+// BuildFastaWithOptions builds a fasta file like BuildFasta following the given FastaOptions.
+func BuildFastaWithOptions(sequences []Sequence, options FastaOptions) []byte {
+	var fastaBuffer bytes.Buffer
+	for _, sequence := range sequences {
+		fastaBuffer.WriteString(">" + strings.TrimPrefix(sequence.Description, ">") + "\n")
+		casedSequence := options.Case.apply(sequence.Sequence)
+		for lineStart := 0; lineStart < len(casedSequence); lineStart += 70 {
+			fastaBuffer.WriteString(casedSequence[lineStart:minInt(lineStart+70, len(casedSequence))] + "\n")
+		}
+	}
+	return fastaBuffer.Bytes()
+}
+
+// ParseFasta takes in a string representing a fasta file and parses it into a slice of Sequence structs, one per record.
+// Each Description is the record's header without its leading ">" and the sequence lines are joined with whitespace
+// removed. Lines before the first header and ";" comment lines are ignored.
+func ParseFasta(fasta string) []Sequence {
+	var sequences []Sequence
+	var sequenceBuilder strings.Builder
+	for _, line := range strings.Split(fasta, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, ">") {
+			if len(sequences) > 0 {
+				sequences[len(sequences)-1].Sequence = sequenceBuilder.String()
+				sequenceBuilder.Reset()
+			}
+			sequences = append(sequences, Sequence{Description: line[1:]})
+		} else if len(sequences) > 0 && !strings.HasPrefix(line, ";") {
+			sequenceBuilder.WriteString(strings.Join(strings.Fields(line), ""))
+		}
+	}
+	if len(sequences) > 0 {
+		sequences[len(sequences)-1].Sequence = sequenceBuilder.String()
+	}
+	return sequences
+}
+
+// ReadFasta reads the fasta file at path and parses it into a slice of Sequence structs.
+func ReadFasta(path string) ([]Sequence, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFasta(string(file)), nil
+}
+
+// WriteFasta takes a slice of Sequence structs and a path string and writes out a fasta file to that path.
+func WriteFasta(sequences []Sequence, path string) error {
+	return ioutil.WriteFile(path, BuildFasta(sequences), 0644)
+}
+
+// WriteFastaWithOptions takes a slice of Sequence structs, FastaOptions, and a path string and writes out a fasta file
+// to that path.
+func WriteFastaWithOptions(sequences []Sequence, options FastaOptions, path string) error {
+	return ioutil.WriteFile(path, BuildFastaWithOptions(sequences, options), 0644)
+}
+
+// a single record of a samtools style .fai index.
+type faidxEntry struct {
+	name      string
+	length    int64 // number of bases in the sequence.
+	offset    int64 // byte offset of the sequence's first base.
+	lineBases int64 // bases per sequence line.
+	lineWidth int64 // bytes per sequence line including the line ending.
+}
+
+// unsafeFilenameCharacters matches anything that shouldn't be in a file name written by WriteFeatureSequences or WriteGbkEach.
+var unsafeFilenameCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// WriteFeatureSequences writes the sequence of every feature of featureType to its own fasta file in dir, creating dir if
+// it doesn't exist. Files are named after the feature's ID, or locus_tag or protein_id if it has no ID, with anything
+// that isn't safe in a file name replaced by underscores. Features that would share a name get their start and end
+// appended, like b0001_190-255.fasta.
+func (annotatedSequence AnnotatedSequence) WriteFeatureSequences(dir, featureType string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	usedNames := make(map[string]bool)
+	for _, feature := range annotatedSequence.Features {
+		if feature.Type != featureType {
+			continue
+		}
+		featureSequence, err := annotatedSequence.FeatureSequence(feature)
+		if err != nil {
+			return fmt.Errorf("could not get the sequence of %s: %w", describeFeature(feature), err)
+		}
+
+		name := unsafeFilenameCharacters.ReplaceAllString(feature.identifier(), "_")
+		if usedNames[name] {
+			featureLocation, _ := feature.location()
+			segments := featureLocation.segments()
+			start, end := segments[0][0], segments[0][1]
+			for _, segment := range segments {
+				start, end = minInt(start, segment[0]), maxInt(end, segment[1])
+			}
+			name += "_" + strconv.Itoa(start) + "-" + strconv.Itoa(end)
+		}
+		// features at the same coordinates with the same name are numbered instead.
+		for uniqueName, copyNumber := name, 2; ; copyNumber++ {
+			if !usedNames[uniqueName] {
+				name = uniqueName
+				break
+			}
+			uniqueName = name + "_" + strconv.Itoa(copyNumber)
+		}
+		usedNames[name] = true
+
+		fasta := []Sequence{{Description: name + " " + describeFeature(feature), Sequence: featureSequence}}
+		if err := WriteFasta(fasta, filepath.Join(dir, name+".fasta")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildFaidx writes a samtools style .fai index for the fasta file at path to path + ".fai". Every sequence line of a
+// record except the last must be the same length so bases can be found by arithmetic, the same restriction samtools has.
+// Blank lines are only allowed at the end of a record.
+func BuildFaidx(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var entries []faidxEntry
+	var entry *faidxEntry
+	var offset int64
+	sawShortLine := false
+	sawBlankLine := false
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		lineWidth := int64(len(line))
+		bases := int64(len(strings.TrimRight(line, "\r\n")))
+		offset += lineWidth
+
+		if strings.HasPrefix(line, ">") {
+			var name string
+			if nameFields := strings.Fields(line[1:]); len(nameFields) > 0 {
+				name = nameFields[0]
+			}
+			entries = append(entries, faidxEntry{name: name, offset: offset})
+			entry = &entries[len(entries)-1]
+			sawShortLine = false
+			sawBlankLine = false
+		} else if bases > 0 {
+			if entry == nil {
+				return fmt.Errorf("line %d of %s has sequence before the first fasta header", lineNumber, path)
+			}
+			// a blank line would throw off the arithmetic that finds the bases after it.
+			if sawBlankLine {
+				return fmt.Errorf("line %d of %s follows a blank line inside %s, so it can't be indexed", lineNumber, path, entry.name)
+			}
+			if entry.lineBases == 0 {
+				entry.lineBases, entry.lineWidth = bases, lineWidth
+			} else if sawShortLine || bases > entry.lineBases {
+				return fmt.Errorf("line %d of %s has a different length than the lines before it, so %s can't be indexed", lineNumber, path, entry.name)
+			}
+			sawShortLine = sawShortLine || bases < entry.lineBases
+			entry.length += bases
+		} else if lineWidth > 0 {
+			sawBlankLine = true
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	var faiBuffer bytes.Buffer
+	for _, entry := range entries {
+		faiBuffer.WriteString(fmt.Sprintf("%s\t%d\t%d\t%d\t%d\n", entry.name, entry.length, entry.offset, entry.lineBases, entry.lineWidth))
+	}
+	return ioutil.WriteFile(path+".fai", faiBuffer.Bytes(), 0644)
+}
+
+// reads the entry for a single sequence out of a .fai index.
+func readFaidxEntry(faiPath string, name string) (faidxEntry, error) {
+	fai, err := ioutil.ReadFile(faiPath)
+	if err != nil {
+		return faidxEntry{}, err
+	}
+	for lineIndex, line := range strings.Split(string(fai), "\n") {
+		columns := strings.Split(line, "\t")
+		if columns[0] != name {
+			continue
+		}
+		if len(columns) < 5 {
+			return faidxEntry{}, fmt.Errorf("line %d of %s has %d columns, expected 5", lineIndex+1, faiPath, len(columns))
+		}
+		entry := faidxEntry{name: name}
+		for columnIndex, field := range []*int64{&entry.length, &entry.offset, &entry.lineBases, &entry.lineWidth} {
+			if *field, err = strconv.ParseInt(columns[columnIndex+1], 10, 64); err != nil {
+				return faidxEntry{}, fmt.Errorf("line %d of %s: %w", lineIndex+1, faiPath, err)
+			}
+		}
+		return entry, nil
+	}
+	return faidxEntry{}, fmt.Errorf("sequence %q is not in %s", name, faiPath)
+}
+
+// FetchRegion returns the bases from start up to but not including end (0-based, half-open, like TwoBitIndex.Range) of
+// the named sequence in a fasta file. Only the bytes covering the region are read, using the offsets in a .fai index
+// built by BuildFaidx or samtools faidx.
+func FetchRegion(fastaPath, faiPath, name string, start, end int) (string, error) {
+	entry, err := readFaidxEntry(faiPath, name)
+	if err != nil {
+		return "", err
+	}
+	if start < 0 || end < start || int64(end) > entry.length {
+		return "", fmt.Errorf("region %d-%d is outside of %s which has length %d", start, end, name, entry.length)
+	}
+	if start == end {
+		return "", nil
+	}
+
+	// byte offset of a 0-based base position accounting for line endings.
+	byteOffset := func(position int64) int64 {
+		return entry.offset + position/entry.lineBases*entry.lineWidth + position%entry.lineBases
+	}
+	regionStart, regionEnd := byteOffset(int64(start)), byteOffset(int64(end-1))+1
+
+	file, err := os.Open(fastaPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	region := make([]byte, regionEnd-regionStart)
+	if _, err := file.ReadAt(region, regionStart); err != nil {
+		return "", err
+	}
+
+	bases := make([]byte, 0, end-start)
+	for _, character := range region {
+		if character != '\n' && character != '\r' {
+			bases = append(bases, character)
+		}
+	}
+	return string(bases), nil
+}
+
+/******************************************************************************
+
+FASTA specific IO related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+FASTQ specific IO related things begin here.
+
+******************************************************************************/
+
+// ParseFastq takes in a string representing a fastq file and parses it into a slice of Read structs. Every record is
+// exactly four lines, so empty reads keep their blank sequence and quality lines and qualities starting with "@" aren't
+// mistaken for headers. Only the newline ending the last line is trimmed, so an empty read at the end of the file keeps
+// its blank quality line. Returns an error naming the line of the first record that isn't well formed.
+func ParseFastq(fastq string) ([]Read, error) {
+	reads := []Read{}
+	fastq = strings.TrimSuffix(fastq, "\n")
+	lines := strings.Split(fastq, "\n")
+	if len(lines) == 1 && strings.TrimSuffix(lines[0], "\r") == "" {
+		return reads, nil
+	}
+	for lineIndex := range lines {
+		lines[lineIndex] = strings.TrimRight(lines[lineIndex], "\r")
+	}
+
+	// every fastq record is exactly four lines: @identifier, sequence, + separator, and quality.
+	for lineIndex := 0; lineIndex < len(lines); lineIndex += 4 {
+		if lineIndex+3 >= len(lines) {
+			return nil, fmt.Errorf("line %d of fastq: record has %d of its 4 lines", lineIndex+1, len(lines)-lineIndex)
+		}
+		if !strings.HasPrefix(lines[lineIndex], "@") || !strings.HasPrefix(lines[lineIndex+2], "+") {
+			return nil, fmt.Errorf("line %d of fastq: record doesn't start with an @ header followed by a sequence and a + separator", lineIndex+1)
+		}
+		read := Read{}
+		read.Identifier = strings.TrimPrefix(lines[lineIndex], "@")
+		read.Sequence = lines[lineIndex+1]
+		read.Quality = lines[lineIndex+3]
+		if len(read.Quality) != len(read.Sequence) {
+			return nil, fmt.Errorf("line %d of fastq: record %s has %d quality scores for %d bases", lineIndex+1, read.Identifier, len(read.Quality), len(read.Sequence))
+		}
+		reads = append(reads, read)
+	}
+	return reads, nil
+}
+
+// ReadFastq reads a fastq file from path and parses it into a slice of Read structs.
+func ReadFastq(path string) []Read {
+	file, err := ioutil.ReadFile(path)
+	var reads []Read
+	if err != nil {
+		// return 0, fmt.Errorf("Failed to open file %s for unpack: %s", gzFilePath, err)
+	} else {
+		reads, err = ParseFastq(string(file))
+		if err != nil {
+			log.Printf("could not parse fastq from %s: %s", path, err)
+		}
+	}
+	return reads
+}
+
+/******************************************************************************
+
+FASTQ specific IO related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+2bit specific IO related things begin here.
+
+2bit is UCSC's compact binary format for storing many sequences with fast
+random access. Every base is packed into 2 bits (T=0, C=1, A=2, G=3) so a
+reader can seek straight to the bytes of a region without loading the whole
+sequence. Spec: https://genome.ucsc.edu/FAQ/FAQformat.html#format7
+
+2bit can only represent A, C, G, and T. Any other character (N or IUPAC
+ambiguity codes) is stored as part of an N block and is read back out as N.
+Lowercase (soft masked) bases are stored as mask blocks and read back out as
+lowercase.
+
+******************************************************************************/
+
+const twoBitSignature = 0x1A412743
+
+// 2bit packing codes for each base.
+var twoBitBaseCodes = map[byte]byte{'T': 0, 'C': 1, 'A': 2, 'G': 3}
+
+// 2bit packing codes mapped back to their bases.
+const twoBitCodeBases = "TCAG"
+
+// TwoBitIndex holds the offsets of every sequence in a 2bit file so that regions can be read without loading whole sequences.
+type TwoBitIndex struct {
+	path      string
+	byteOrder binary.ByteOrder
+	offsets   map[string]uint32
+	names     []string
+}
+
+// returns the name of a sequence for use in 2bit and other indexed formats: the first word of its description.
+func sequenceName(sequence Sequence) string {
+	nameFields := strings.Fields(strings.TrimPrefix(sequence.Description, ">"))
+	if len(nameFields) == 0 {
+		return ""
+	}
+	return nameFields[0]
+}
+
+// returns the [start, size] of every run of bases in sequence that satisfy check.
+func twoBitBlocks(sequence string, check func(base byte) bool) [][2]uint32 {
+	var blocks [][2]uint32
+	for baseIndex := 0; baseIndex < len(sequence); baseIndex++ {
+		if !check(sequence[baseIndex]) {
+			continue
+		}
+		blockStart := baseIndex
+		for baseIndex < len(sequence) && check(sequence[baseIndex]) {
+			baseIndex++
+		}
+		blocks = append(blocks, [2]uint32{uint32(blockStart), uint32(baseIndex - blockStart)})
+	}
+	return blocks
+}
+
+// encodes a single sequence record: its size, N blocks, mask blocks, and packed bases.
+func buildTwoBitRecord(sequence string) []byte {
+	var recordBuffer bytes.Buffer
+	writeUint32 := func(value uint32) { binary.Write(&recordBuffer, binary.LittleEndian, value) }
+	writeBlocks := func(blocks [][2]uint32) {
+		writeUint32(uint32(len(blocks)))
+		for _, block := range blocks {
+			writeUint32(block[0])
+		}
+		for _, block := range blocks {
+			writeUint32(block[1])
+		}
+	}
+
+	writeUint32(uint32(len(sequence)))
+	writeBlocks(twoBitBlocks(sequence, func(base byte) bool {
+		_, ok := twoBitBaseCodes[byte(unicode.ToUpper(rune(base)))]
+		return !ok
+	}))
+	writeBlocks(twoBitBlocks(sequence, func(base byte) bool { return unicode.IsLower(rune(base)) }))
+	writeUint32(0) // reserved
+
+	// pack 4 bases per byte with the first base in the most significant bits. Bases in N blocks are packed as T.
+	packed := make([]byte, (len(sequence)+3)/4)
+	for baseIndex := 0; baseIndex < len(sequence); baseIndex++ {
+		code := twoBitBaseCodes[byte(unicode.ToUpper(rune(sequence[baseIndex])))]
+		packed[baseIndex/4] |= code << uint(6-2*(baseIndex%4))
+	}
+	recordBuffer.Write(packed)
+	return recordBuffer.Bytes()
+}
+
+// Build2bit takes a slice of Sequence structs and returns a byte array representing a 2bit file to be written out.
+// Sequences are named by the first word of their Description.
+func Build2bit(sequences []Sequence) ([]byte, error) {
+	var twoBitBuffer bytes.Buffer
+	writeUint32 := func(value uint32) { binary.Write(&twoBitBuffer, binary.LittleEndian, value) }
+
+	names := make([]string, len(sequences))
+	indexSize := 0
+	for sequenceIndex, sequence := range sequences {
+		name := sequenceName(sequence)
+		if name == "" || len(name) > 255 {
+			return nil, fmt.Errorf("sequence %d needs a name between 1 and 255 characters long to be written to 2bit", sequenceIndex)
+		}
+		names[sequenceIndex] = name
+		indexSize += 1 + len(name) + 4
+	}
+
+	// header: signature, version, sequence count, reserved.
+	writeUint32(twoBitSignature)
+	writeUint32(0)
+	writeUint32(uint32(len(sequences)))
+	writeUint32(0)
+
+	records := make([][]byte, len(sequences))
+	offset := int64(16 + indexSize)
+	for sequenceIndex, sequence := range sequences {
+		records[sequenceIndex] = buildTwoBitRecord(sequence.Sequence)
+		twoBitBuffer.WriteByte(byte(len(names[sequenceIndex])))
+		twoBitBuffer.WriteString(names[sequenceIndex])
+		writeUint32(uint32(offset))
+		offset += int64(len(records[sequenceIndex]))
+	}
+	if offset > math.MaxUint32 {
+		return nil, fmt.Errorf("sequences are too large to fit in a single 2bit file")
+	}
+
+	for _, record := range records {
+		twoBitBuffer.Write(record)
+	}
+	return twoBitBuffer.Bytes(), nil
+}
+
+// Write2bit takes a slice of Sequence structs and a path string and writes out a 2bit file to that path.
+func Write2bit(sequences []Sequence, path string) error {
+	twoBit, err := Build2bit(sequences)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, twoBit, 0644)
+}
+
+// ReadTwoBitIndex reads the header and index of a 2bit file so regions of its sequences can be read with Range.
+func ReadTwoBitIndex(path string) (TwoBitIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return TwoBitIndex{}, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+
+	index := TwoBitIndex{path: path, offsets: make(map[string]uint32)}
+	header := make([]uint32, 4)
+	if err := binary.Read(reader, binary.LittleEndian, header); err != nil {
+		return TwoBitIndex{}, fmt.Errorf("could not read 2bit header of %s: %w", path, err)
+	}
+
+	// 2bit files can be written in either byte order. The signature tells us which one was used.
+	switch {
+	case header[0] == twoBitSignature:
+		index.byteOrder = binary.LittleEndian
+	case bits.ReverseBytes32(header[0]) == twoBitSignature:
+		index.byteOrder = binary.BigEndian
+		for headerIndex := range header {
+			header[headerIndex] = bits.ReverseBytes32(header[headerIndex])
+		}
+	default:
+		return TwoBitIndex{}, fmt.Errorf("%s is not a 2bit file", path)
+	}
+	if header[1] != 0 {
+		return TwoBitIndex{}, fmt.Errorf("unsupported 2bit version %d in %s", header[1], path)
+	}
+
+	for sequenceIndex := uint32(0); sequenceIndex < header[2]; sequenceIndex++ {
+		nameSize, err := reader.ReadByte()
+		if err != nil {
+			return TwoBitIndex{}, fmt.Errorf("could not read 2bit index of %s: %w", path, err)
+		}
+		name := make([]byte, nameSize)
+		if _, err := io.ReadFull(reader, name); err != nil {
+			return TwoBitIndex{}, fmt.Errorf("could not read 2bit index of %s: %w", path, err)
+		}
+		var offset uint32
+		if err := binary.Read(reader, index.byteOrder, &offset); err != nil {
+			return TwoBitIndex{}, fmt.Errorf("could not read 2bit index of %s: %w", path, err)
+		}
+		index.offsets[string(name)] = offset
+		index.names = append(index.names, string(name))
+	}
+	return index, nil
+}
+
+// Names returns the names of every sequence in a 2bit file in the order they were written.
+func (index TwoBitIndex) Names() []string {
+	return append([]string(nil), index.names...)
+}
+
+// Range reads bases [start, end) of a named sequence from a 2bit file. Coordinates are 0-based and end exclusive, like
+// slicing a string. Only the bytes covering the region are read from disk.
+func (index TwoBitIndex) Range(name string, start, end int) (string, error) {
+	offset, ok := index.offsets[name]
+	if !ok {
+		return "", fmt.Errorf("sequence %q not found in %s", name, index.path)
+	}
+
+	file, err := os.Open(index.path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.Seek(int64(offset), io.SeekStart); err != nil {
+		return "", err
+	}
+	reader := bufio.NewReader(file)
+
+	readUint32s := func(count uint32) ([]uint32, error) {
+		values := make([]uint32, count)
+		err := binary.Read(reader, index.byteOrder, values)
+		return values, err
+	}
+	readBlocks := func() ([]uint32, []uint32, error) {
+		blockCount, err := readUint32s(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		blockStarts, err := readUint32s(blockCount[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		blockSizes, err := readUint32s(blockCount[0])
+		return blockStarts, blockSizes, err
+	}
+
+	size, err := readUint32s(1)
+	if err != nil {
+		return "", fmt.Errorf("could not read 2bit record %q: %w", name, err)
+	}
+	if start < 0 || end > int(size[0]) || start > end {
+		return "", fmt.Errorf("range %d-%d is outside of %q which has length %d", start, end, name, size[0])
+	}
+	nBlockStarts, nBlockSizes, err := readBlocks()
+	if err != nil {
+		return "", fmt.Errorf("could not read 2bit record %q: %w", name, err)
+	}
+	maskBlockStarts, maskBlockSizes, err := readBlocks()
+	if err != nil {
+		return "", fmt.Errorf("could not read 2bit record %q: %w", name, err)
+	}
+	if _, err := readUint32s(1); err != nil { // reserved
+		return "", fmt.Errorf("could not read 2bit record %q: %w", name, err)
+	}
+
+	// skip straight to the packed bytes that hold the region.
+	if _, err := reader.Discard(start / 4); err != nil {
+		return "", fmt.Errorf("could not read 2bit record %q: %w", name, err)
+	}
+	packed := make([]byte, (end+3)/4-start/4)
+	if _, err := io.ReadFull(reader, packed); err != nil {
+		return "", fmt.Errorf("could not read 2bit record %q: %w", name, err)
+	}
+
+	bases := make([]byte, end-start)
+	for baseIndex := start; baseIndex < end; baseIndex++ {
+		packedByte := packed[baseIndex/4-start/4]
+		bases[baseIndex-start] = twoBitCodeBases[(packedByte>>uint(6-2*(baseIndex%4)))&3]
+	}
+
+	// overlay N blocks and lowercase mask blocks that overlap the region.
+	overlay := func(blockStarts, blockSizes []uint32, apply func(base byte) byte) {
+		for blockIndex := range blockStarts {
+			blockStart, blockEnd := int(blockStarts[blockIndex]), int(blockStarts[blockIndex]+blockSizes[blockIndex])
+			for baseIndex := maxInt(blockStart, start); baseIndex < minInt(blockEnd, end); baseIndex++ {
+				bases[baseIndex-start] = apply(bases[baseIndex-start])
+			}
+		}
+	}
+	overlay(nBlockStarts, nBlockSizes, func(base byte) byte { return 'N' })
+	overlay(maskBlockStarts, maskBlockSizes, func(base byte) byte { return byte(unicode.ToLower(rune(base))) })
+	return string(bases), nil
+}
+
+/******************************************************************************
+
+2bit specific IO related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Conversion related things begin here.
+
+******************************************************************************/
+
+// the format names Convert uses for each file extension it recognizes.
+var formatsByExtension = map[string]string{
+	".gbk":     "gbk",
+	".gb":      "gbk",
+	".genbank": "gbk",
+	".gff":     "gff",
+	".gff3":    "gff",
+	".json":    "json",
+	".fasta":   "fasta",
+	".fa":      "fasta",
+	".fna":     "fasta",
+	".2bit":    "2bit",
+}
+
+// gffToGenbankQualifierNames maps gff attribute names to the genbank qualifiers that hold the same information.
+//
+//	gff      genbank
+//	ID       locus_tag
+//	Name     gene
+//	Alias    gene_synonym
+//	Note     note
+//	Dbxref   db_xref
+//
+// Parent has no genbank equivalent since genbank doesn't link features, so it and any other attribute not listed here
+// are passed through unchanged.
+var gffToGenbankQualifierNames = map[string]string{
+	"ID":     "locus_tag",
+	"Name":   "gene",
+	"Alias":  "gene_synonym",
+	"Note":   "note",
+	"Dbxref": "db_xref",
+}
+
+// genbankToGffQualifierNames is gffToGenbankQualifierNames the other way around.
+var genbankToGffQualifierNames = func() map[string]string {
+	names := make(map[string]string, len(gffToGenbankQualifierNames))
+	for gffName, genbankName := range gffToGenbankQualifierNames {
+		names[genbankName] = gffName
+	}
+	return names
+}()
+
+// GffToGenbankQualifiers returns a copy of gff attributes with their names translated to genbank qualifiers, following
+// the table in gffToGenbankQualifierNames. Attributes with no genbank equivalent keep their names. If an attribute
+// translates to a qualifier that's also present under its own name, like ID alongside locus_tag, the qualifier already
+// there wins and the attribute keeps its gff name so neither value is lost. The names of those attributes are returned
+// as collisions, in sorted order.
+func GffToGenbankQualifiers(attributes map[string]string) (qualifiers map[string]string, collisions []string) {
+	return renameQualifiers(attributes, gffToGenbankQualifierNames)
+}
+
+// GenbankToGffQualifiers is the inverse of GffToGenbankQualifiers, translating genbank qualifier names to gff attributes.
+func GenbankToGffQualifiers(qualifiers map[string]string) (attributes map[string]string, collisions []string) {
+	return renameQualifiers(qualifiers, genbankToGffQualifierNames)
+}
+
+// returns a copy of qualifiers with every key in names renamed, along with the keys that weren't renamed because a
+// qualifier already has the new name.
+func renameQualifiers(qualifiers map[string]string, names map[string]string) (map[string]string, []string) {
+	if qualifiers == nil {
+		return nil, nil
+	}
+	renamed := make(map[string]string, len(qualifiers))
+	var renamedKeys []string
+	for key, value := range qualifiers {
+		if _, ok := names[key]; ok {
+			renamedKeys = append(renamedKeys, key)
+		} else {
+			renamed[key] = value
+		}
+	}
+	sort.Strings(renamedKeys)
+
+	var collisions []string
+	for _, key := range renamedKeys {
+		if _, taken := renamed[names[key]]; taken {
+			renamed[key] = qualifiers[key]
+			collisions = append(collisions, key)
+		} else {
+			renamed[names[key]] = qualifiers[key]
+		}
+	}
+	return renamed, collisions
+}
+
+// converts the qualifier names of every feature between the gff and genbank vocabularies when converting from
+// inputFormat to outputFormat, "gff" or "gbk", logging how many of each collided. Other conversions are returned unchanged.
+func convertQualifierNames(annotatedSequence AnnotatedSequence, inputFormat, outputFormat string) AnnotatedSequence {
+	var names map[string]string
+	switch {
+	case inputFormat == "gff" && outputFormat == "gbk":
+		names = gffToGenbankQualifierNames
+	case inputFormat == "gbk" && outputFormat == "gff":
+		names = genbankToGffQualifierNames
+	default:
+		return annotatedSequence
+	}
+
+	features := make([]Feature, len(annotatedSequence.Features))
+	collisionCounts := make(map[string]int)
+	for featureIndex, feature := range annotatedSequence.Features {
+		feature = feature.clone()
+		var collisions []string
+		feature.Attributes, collisions = renameQualifiers(feature.Attributes, names)
+		collided := make(map[string]bool, len(collisions))
+		for _, key := range collisions {
+			collided[key] = true
+			collisionCounts[key]++
+		}
+		// repeated values follow their first value, which stays put if it collided.
+		if feature.RepeatedQualifiers != nil {
+			repeatedQualifiers := make(map[string][]string, len(feature.RepeatedQualifiers))
+			for key, values := range feature.RepeatedQualifiers {
+				if newKey, ok := names[key]; ok && !collided[key] {
+					key = newKey
+				}
+				repeatedQualifiers[key] = values
+			}
+			feature.RepeatedQualifiers = repeatedQualifiers
+		}
+		features[featureIndex] = feature
+	}
+	collidedKeys := make([]string, 0, len(collisionCounts))
+	for key := range collisionCounts {
+		collidedKeys = append(collidedKeys, key)
+	}
+	sort.Strings(collidedKeys)
+	for _, key := range collidedKeys {
+		log.Printf("kept %s under its own name on %d features that already have %s", key, collisionCounts[key], names[key])
+	}
+	annotatedSequence.Features = features
+	return annotatedSequence
+}
+
+// Convert reads the file at inputPath and writes it to outputPath, detecting both formats from their file extensions.
+// Genbank, gff, and json can be read, and genbank, gff, json, fasta, and 2bit can be written. Qualifier names are
+// translated with GffToGenbankQualifiers or GenbankToGffQualifiers when converting between genbank and gff.
+func Convert(inputPath, outputPath string) error {
+	inputFormat := formatsByExtension[strings.ToLower(filepath.Ext(inputPath))]
+	outputFormat := formatsByExtension[strings.ToLower(filepath.Ext(outputPath))]
+
+	file, err := ioutil.ReadFile(inputPath)
+	if err != nil {
+		return err
+	}
+
+	var annotatedSequence AnnotatedSequence
+	switch inputFormat {
+	case "gbk":
+		annotatedSequence = ParseGbk(string(file))
+	case "gff":
+		if annotatedSequence, err = ParseGff(string(file)); err != nil {
+			return fmt.Errorf("could not parse gff from %s: %w", inputPath, err)
+		}
+	case "json":
+		if err := json.Unmarshal(file, &annotatedSequence); err != nil {
+			return fmt.Errorf("could not parse json from %s: %w", inputPath, err)
+		}
+	default:
+		return fmt.Errorf("unsupported input format for %s, expected a genbank, gff, or json file", inputPath)
+	}
+
+	// fasta and 2bit records need a name and genbank files don't set one on their Sequence.
+	sequence := annotatedSequence.Sequence
+	if sequence.Description == "" {
+		if annotatedSequence.Meta.Name != "" {
+			sequence.Description = annotatedSequence.Meta.Name
+		} else if annotatedSequence.Meta.Locus.Name != "" {
+			sequence.Description = annotatedSequence.Meta.Locus.Name
+		} else {
+			sequence.Description = annotatedSequence.Meta.Accession
+		}
+	}
+
+	annotatedSequence = convertQualifierNames(annotatedSequence, inputFormat, outputFormat)
+
+	var output []byte
+	switch outputFormat {
+	case "gff":
+		output = BuildGff(annotatedSequence)
+	case "json":
+		output, err = json.MarshalIndent(annotatedSequence, "", " ")
+	case "fasta":
+		if sequence.Sequence == "" {
+			return fmt.Errorf("cannot convert %s to fasta because it has no sequence", inputPath)
+		}
+		output = BuildFasta([]Sequence{sequence})
+	case "2bit":
+		if sequence.Sequence == "" {
+			return fmt.Errorf("cannot convert %s to 2bit because it has no sequence", inputPath)
+		}
+		output, err = Build2bit([]Sequence{sequence})
+	case "gbk":
+		output = BuildGbk(annotatedSequence)
+	default:
+		return fmt.Errorf("unsupported output format for %s, expected a genbank, gff, json, fasta, or 2bit file", outputPath)
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outputPath, output, 0644)
+}
+
+/******************************************************************************
+
+Conversion related things end here.
 
 ******************************************************************************/
@@ -1,9 +1,19 @@
 package main
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pmezard/go-difflib/difflib"
@@ -14,8 +24,13 @@ import (
 File is structured as so:
 
 Gff - io tests, and benchmarks.
-Gbk/gb/genbank - benchmarks.
+Gbk/gb/genbank - tests, and benchmarks.
 JSON - io tests.
+Feature table - tests.
+GenePred - tests.
+Fasta - io tests.
+2bit - io tests.
+Conversion - tests.
 
 ******************************************************************************/
 
@@ -60,6 +75,406 @@ func TestGffIO(t *testing.T) {
 
 }
 
+func TestGffVersion(t *testing.T) {
+	gff := "##gff-version 3.1.26\n##sequence-region test 1 8\ntest\tfeature\tgene\t1\t8\t.\t+\t.\tID=gene1\n"
+	annotatedSequence, _ := ParseGff(gff)
+	if annotatedSequence.Meta.GffVersion != "3.1.26" {
+		t.Errorf("ParseGff() did not preserve the minor gff version. Got %q", annotatedSequence.Meta.GffVersion)
+	}
+
+	versionLine := strings.Split(string(BuildGff(annotatedSequence)), "\n")[0]
+	if versionLine != "##gff-version 3.1.26" {
+		t.Errorf("BuildGff() did not emit the parsed gff version. Got %q", versionLine)
+	}
+
+	annotatedSequence.Meta.GffVersion = ""
+	versionLine = strings.Split(string(BuildGff(annotatedSequence)), "\n")[0]
+	if versionLine != "##gff-version 3" {
+		t.Errorf("BuildGff() default version line should be \"##gff-version 3\". Got %q", versionLine)
+	}
+}
+
+func TestBuildGffWithoutFasta(t *testing.T) {
+	annotatedSequence, _ := ParseGff("##gff-version 3\n##sequence-region test 1 8\ntest\tfeature\tgene\t1\t8\t.\t+\t.\tID=gene1\n##FASTA\n>test\nATGCATGC\n")
+
+	withoutFasta := string(BuildGffWithOptions(annotatedSequence, GffOptions{IncludeFasta: false}))
+	if strings.Contains(withoutFasta, "##FASTA") || strings.Contains(withoutFasta, "ATGCATGC") {
+		t.Errorf("BuildGffWithOptions() included a FASTA block when IncludeFasta was false:\n%s", withoutFasta)
+	}
+	if !strings.Contains(withoutFasta, "ID=gene1") {
+		t.Errorf("BuildGffWithOptions() dropped features when IncludeFasta was false:\n%s", withoutFasta)
+	}
+
+	withFasta := string(BuildGffWithOptions(annotatedSequence, GffOptions{IncludeFasta: true}))
+	if withFasta != string(BuildGff(annotatedSequence)) || !strings.Contains(withFasta, "##FASTA\n>test\nATGCATGC\n") {
+		t.Errorf("BuildGffWithOptions() with IncludeFasta should match BuildGff():\n%s", withFasta)
+	}
+}
+
+func TestGffUndefinedCoordinates(t *testing.T) {
+	featureLine := "test\tfeature\tregion\t.\t.\t.\t+\t.\tID=region1"
+	annotatedSequence, _ := ParseGff("##gff-version 3\n##sequence-region test 1 8\n" + featureLine + "\n")
+
+	feature := annotatedSequence.Features[0]
+	if feature.Start != UndefinedCoordinate || feature.End != UndefinedCoordinate {
+		t.Errorf("ParseGff() should parse \".\" coordinates as UndefinedCoordinate. Got %d and %d", feature.Start, feature.End)
+	}
+
+	if gff := string(BuildGff(annotatedSequence)); !strings.Contains(gff, featureLine+"\n") {
+		t.Errorf("BuildGff() did not write undefined coordinates back out as \".\":\n%s", gff)
+	}
+}
+
+func TestGffCircularity(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Meta:     Meta{Name: "plasmid", Locus: Locus{Name: "plasmid", SequenceLength: "8 bp", Circular: true}},
+		Features: []Feature{{Type: "gene", Start: 1, End: 6, Strand: "+", Attributes: map[string]string{"ID": "gene1"}}},
+		Sequence: Sequence{Sequence: "ATGCATGC"},
+	}
+
+	gff := string(BuildGff(annotatedSequence))
+	if !strings.Contains(gff, "##sequence-region plasmid 1 8\nplasmid\tfeature\tregion\t1\t8\t.\t+\t.\tID=plasmid;Is_circular=true\n") {
+		t.Errorf("BuildGff() did not add a circular region for a circular sequence:\n%s", gff)
+	}
+
+	parsed, _ := ParseGff(gff)
+	if !parsed.Meta.Locus.Circular {
+		t.Errorf("ParseGff() did not read circularity from the Is_circular region")
+	}
+	if rebuilt := string(BuildGff(parsed)); rebuilt != gff {
+		t.Errorf("BuildGff() did not round trip a circular gff. Got:\n%s", rebuilt)
+	}
+
+	annotatedSequence.Meta.Locus.Circular = false
+	if gff := string(BuildGff(annotatedSequence)); strings.Contains(gff, "Is_circular") {
+		t.Errorf("BuildGff() marked a linear sequence as circular:\n%s", gff)
+	}
+}
+
+func TestGffWhitespaceColumns(t *testing.T) {
+	gff := "##gff-version 3\n##sequence-region test 1 8\n" +
+		"test  feature gene 1   8 . + . ID=gene1;Note=has spaces in it\n" +
+		"test\tfeature\tCDS\t1\t6\t.\t+\t0\tID=cds1;Parent=gene1\n"
+	annotatedSequence, err := ParseGff(gff)
+	if err != nil {
+		t.Fatalf("ParseGff() returned an error: %s", err)
+	}
+
+	if len(annotatedSequence.Features) != 2 {
+		t.Fatalf("ParseGff() expected 2 features. Got %d", len(annotatedSequence.Features))
+	}
+	gene := annotatedSequence.Features[0]
+	if gene.Type != "gene" || gene.Start != 1 || gene.End != 8 || gene.Attributes["Note"] != "has spaces in it" {
+		t.Errorf("ParseGff() did not split a space separated line into columns. Got %+v", gene)
+	}
+	if annotatedSequence.Features[1].Attributes["Parent"] != "gene1" {
+		t.Errorf("ParseGff() did not parse a tab separated line. Got %+v", annotatedSequence.Features[1])
+	}
+
+	if _, err := ParseGff(gff + "test feature truncated\n"); err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("ParseGff() should return an error for the truncated line 5. Got %v", err)
+	}
+}
+
+func TestParseGffErrors(t *testing.T) {
+	header := "##gff-version 3\n##sequence-region test 1 8\n"
+	featureLine := "test\tfeature\tgene\t1\t8\t.\t+\t.\tID=gene1\n"
+	malformed := map[string]string{
+		"missing version":           "##sequence-region test 1 8\n" + featureLine,
+		"malformed sequence-region": "##gff-version 3\n##sequence-region test 1\n" + featureLine,
+		"non integer region":        "##gff-version 3\n##sequence-region test one 8\n" + featureLine,
+		"too few columns":           header + "test\tfeature\tgene\t1\t8\n",
+		"non integer coordinate":    header + "test\tfeature\tgene\tone\t8\t.\t+\t.\tID=gene1\n",
+		"attribute without equals":  header + "test\tfeature\tgene\t1\t8\t.\t+\t.\tID=gene1;orphan\n",
+		"empty input":               "",
+	}
+	for name, gff := range malformed {
+		if _, err := ParseGff(gff); err == nil {
+			t.Errorf("ParseGff() should return an error for %s", name)
+		}
+	}
+
+	annotatedSequence, err := ParseGff("# generated by hand\ntrack name=genes\n" + header + featureLine)
+	if err != nil {
+		t.Fatalf("ParseGff() should skip comment and track lines. Got %s", err)
+	}
+	if annotatedSequence.Meta.Name != "test" || len(annotatedSequence.Features) != 1 {
+		t.Errorf("ParseGff() did not parse around comment and track lines. Got %+v", annotatedSequence)
+	}
+
+	withoutRegion, err := ParseGff("##gff-version 3\n" + featureLine)
+	if err != nil || withoutRegion.Meta.Name != "test" {
+		t.Errorf("ParseGff() without ##sequence-region expected Meta.Name from the first feature's seqid. Got %q, %v", withoutRegion.Meta.Name, err)
+	}
+	if gff := string(BuildGff(withoutRegion)); !strings.Contains(gff, "##sequence-region test ") || !strings.Contains(gff, "\ntest\tfeature\tgene\t") {
+		t.Errorf("BuildGff() of a gff parsed without ##sequence-region lost its landmark:\n%s", gff)
+	}
+}
+
+func TestGffWhitespaceEscaping(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Meta: Meta{Name: "test"},
+		Features: []Feature{{Type: "gene", Start: 1, End: 8, Strand: "+", Attributes: map[string]string{
+			"ID":      "gene1",
+			"Note":    "first\tsecond\r\nthird",
+			"comment": "50%09 done, 100% of %3B",
+		}}},
+	}
+
+	gff := string(BuildGffWithOptions(annotatedSequence, GffOptions{}))
+	if !strings.Contains(gff, "ID=gene1;Note=first%09second%0D%0Athird;comment=50%2509 done, 100%25 of %3B\n") {
+		t.Errorf("BuildGff() did not percent encode attribute values:\n%s", gff)
+	}
+
+	parsed, _ := ParseGff(gff)
+	if diff := cmp.Diff(annotatedSequence.Features[0].Attributes, parsed.Features[0].Attributes); diff != "" {
+		t.Errorf("ParseGff() did not decode percent encoded attribute values (-want +got):\n%s", diff)
+	}
+}
+
+func TestGffSeqid(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Meta: Meta{Locus: Locus{Name: "NC_000964", SequenceLength: "9 bp"}},
+		Features: []Feature{
+			{Type: "gene", Start: 1, End: 9, Strand: "+", Attributes: map[string]string{"Name": "thrL", "ID": "gene0"}},
+			{Name: "plasmid1", Type: "gene", Start: 1, End: 9, Strand: "+"},
+		},
+		Sequence: Sequence{Sequence: "ATGAAATAA"},
+	}
+	lines := strings.Split(string(BuildGff(annotatedSequence)), "\n")
+	if lines[1] != "##sequence-region NC_000964 1 9" {
+		t.Errorf("BuildGff() expected the landmark in ##sequence-region. Got %s", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "NC_000964\t") || !strings.HasSuffix(lines[2], "\tID=gene0;Name=thrL") {
+		t.Errorf("BuildGff() expected a feature without a Name on the landmark with its own Name attribute. Got %s", lines[2])
+	}
+	if !strings.HasPrefix(lines[3], "plasmid1\t") {
+		t.Errorf("BuildGff() expected a feature with a Name on that seqid. Got %s", lines[3])
+	}
+	if lines[6] != ">NC_000964" {
+		t.Errorf("BuildGff() expected the landmark in the ##FASTA header. Got %s", lines[6])
+	}
+}
+
+func TestGffChecksum(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Meta:     Meta{Name: "chr1"},
+		Features: []Feature{{Type: "gene", Start: 1, End: 8, Strand: "+", Attributes: map[string]string{"ID": "gene1"}}},
+		Sequence: Sequence{Sequence: "ATGCATGC"},
+	}
+	if strings.Contains(string(BuildGff(annotatedSequence)), gffSeguidComment) {
+		t.Errorf("BuildGff() should only write a checksum when asked to")
+	}
+
+	gff := string(BuildGffWithOptions(annotatedSequence, GffOptions{IncludeFasta: true, IncludeChecksum: true}))
+	if lines := strings.Split(gff, "\n"); lines[2] != "# seguid "+Seguid("ATGCATGC") {
+		t.Errorf("BuildGffWithOptions() expected a seguid comment after the directives. Got %s", lines[2])
+	}
+	parsed, _ := ParseGff(gff)
+	if parsed.Sequence.Seguid != Seguid("ATGCATGC") || len(parsed.Features) != 1 || parsed.VerifySeguid() != nil {
+		t.Errorf("ParseGff() should read the seguid comment and verify. Got %q and %d features", parsed.Sequence.Seguid, len(parsed.Features))
+	}
+
+	corrupted, _ := ParseGff(strings.Replace(gff, "ATGCATGC\n", "ATGCATGG\n", 1))
+	if corrupted.VerifySeguid() == nil {
+		t.Errorf("VerifySeguid() should fail for a corrupted sequence")
+	}
+	if (AnnotatedSequence{Sequence: Sequence{Sequence: "ATGC"}}).VerifySeguid() != nil {
+		t.Errorf("VerifySeguid() should pass a sequence without a checksum")
+	}
+}
+
+func TestGffSpecies(t *testing.T) {
+	gff := "##gff-version 3\n##sequence-region chrI 1 9\n##species https://www.ncbi.nlm.nih.gov/Taxonomy/Browser/wwwtax.cgi?id=6239\nchrI\t.\tgene\t1\t9\t.\t+\t.\tID=gene0\n"
+	annotatedSequence, _ := ParseGff(gff)
+	if annotatedSequence.Meta.TaxonID != "6239" || annotatedSequence.Meta.SpeciesTaxonURL != ncbiTaxonomyURL+"6239" {
+		t.Errorf("ParseGff() expected taxon 6239 from ##species. Got %q and %q", annotatedSequence.Meta.TaxonID, annotatedSequence.Meta.SpeciesTaxonURL)
+	}
+	if built := string(BuildGff(annotatedSequence)); !strings.HasPrefix(built, gff) {
+		t.Errorf("BuildGff() should write ##species back out unchanged. Got:\n%s", built)
+	}
+
+	annotatedSequence, _ = ParseGff("##gff-version 3\n##sequence-region chrI 1 9\n##species taxon:6239\n")
+	if taxonID := annotatedSequence.Meta.TaxonID; taxonID != "6239" {
+		t.Errorf("ParseGff() expected taxon 6239 from a taxon: ##species. Got %q", taxonID)
+	}
+
+	bsub := ReadGbk("data/bsub.gbk")
+	if bsub.Meta.TaxonID != "224308" {
+		t.Errorf("ReadGbk() expected the source feature's taxon 224308. Got %q", bsub.Meta.TaxonID)
+	}
+	bsub.Features = nil
+	bsub.Sequence.Sequence = ""
+	if lines := strings.Split(string(BuildGff(bsub)), "\n"); lines[2] != "##species "+ncbiTaxonomyURL+"224308" {
+		t.Errorf("BuildGff() expected a ##species directive for a genbank taxon. Got %s", lines[2])
+	}
+}
+
+func TestGffFastaOnlyWithSequence(t *testing.T) {
+	annotationOnly := AnnotatedSequence{
+		Meta:     Meta{Locus: Locus{Name: "annotations", SequenceLength: "100 bp"}},
+		Features: []Feature{{Type: "gene", Start: 1, End: 50, Strand: "+", Attributes: map[string]string{"ID": "gene0"}}},
+	}
+	if gff := string(BuildGff(annotationOnly)); strings.Contains(gff, "##FASTA") || !strings.HasSuffix(gff, "###\n") {
+		t.Errorf("BuildGff() should not write a ##FASTA block without a sequence. Got:\n%s", gff)
+	}
+
+	annotationOnly.Sequence.Sequence = "ATGC"
+	if gff := string(BuildGff(annotationOnly)); !strings.HasSuffix(gff, "##FASTA\n>annotations\nATGC\n") {
+		t.Errorf("BuildGff() should name the ##FASTA record even without a Meta.Name. Got:\n%s", gff)
+	}
+
+	features := make(chan Feature)
+	close(features)
+	var streamed bytes.Buffer
+	_ = WriteGffStream(&streamed, annotationOnly.Meta, features, strings.NewReader(""))
+	if strings.Contains(streamed.String(), "##FASTA") {
+		t.Errorf("WriteGffStream() should not write a ##FASTA block for an empty sequence. Got:\n%s", streamed.String())
+	}
+}
+
+func TestWriteGffStream(t *testing.T) {
+	annotatedSequence := ReadGff("data/ecoli-mg1655.gff")
+	features := make(chan Feature)
+	go func() {
+		for _, feature := range annotatedSequence.Features {
+			features <- feature
+		}
+		close(features)
+	}()
+
+	var streamed bytes.Buffer
+	if err := WriteGffStream(&streamed, annotatedSequence.Meta, features, strings.NewReader(annotatedSequence.Sequence.Sequence)); err != nil {
+		t.Fatalf("WriteGffStream() returned an unexpected error: %s", err)
+	}
+	built := BuildGffWithOptions(annotatedSequence, GffOptions{})
+	if !bytes.HasPrefix(streamed.Bytes(), built) {
+		t.Errorf("WriteGffStream() wrote different headers or features than BuildGffWithOptions()")
+	}
+	fasta := bytes.TrimPrefix(streamed.Bytes(), built)
+	expectedFasta := "##FASTA\n" + string(BuildFasta([]Sequence{{Description: annotatedSequence.Meta.Name, Sequence: annotatedSequence.Sequence.Sequence}}))
+	if string(fasta) != expectedFasta {
+		t.Errorf("WriteGffStream() wrote an unexpected ##FASTA block")
+	}
+
+	// a failing writer should return its error without leaving the sender blocked.
+	features = make(chan Feature)
+	sent := make(chan bool)
+	go func() {
+		for _, feature := range annotatedSequence.Features[:100] {
+			features <- feature
+		}
+		close(features)
+		sent <- true
+	}()
+	if err := WriteGffStream(failingWriter{}, annotatedSequence.Meta, features, nil); err == nil {
+		t.Errorf("WriteGffStream() should return the writer's error")
+	}
+	<-sent
+}
+
+// failingWriter is an io.Writer that always fails.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestReadGffWithFasta(t *testing.T) {
+	gffPath, fastaPath := "data/test_separate.gff", "data/test_separate.fasta"
+	_ = ioutil.WriteFile(gffPath, []byte("##gff-version 3\n##sequence-region chr2 1 8\nchr2\t.\tgene\t1\t6\t.\t+\t.\tID=gene1\n"), 0644)
+	defer os.Remove(gffPath)
+	_ = ioutil.WriteFile(fastaPath, []byte(">chr1 first\nAAAAAAAA\n>chr2 second\nATGCAT\nGC\n"), 0644)
+	defer os.Remove(fastaPath)
+
+	annotatedSequence, err := ReadGffWithFasta(gffPath, fastaPath)
+	if err != nil {
+		t.Fatalf("ReadGffWithFasta() returned an unexpected error: %s", err)
+	}
+	if annotatedSequence.Sequence.Sequence != "ATGCATGC" || annotatedSequence.Sequence.Description != "chr2 second" {
+		t.Errorf("ReadGffWithFasta() expected chr2's sequence. Got %+v", annotatedSequence.Sequence)
+	}
+	if geneSequence, _ := annotatedSequence.FeatureSequence(annotatedSequence.Features[0]); geneSequence != "ATGCAT" {
+		t.Errorf("ReadGffWithFasta() gene expected ATGCAT. Got %s", geneSequence)
+	}
+
+	_ = ioutil.WriteFile(fastaPath, []byte(">chr1\nAAAAAAAA\n"), 0644)
+	if _, err := ReadGffWithFasta(gffPath, fastaPath); err == nil {
+		t.Errorf("ReadGffWithFasta() should return an error when the fasta has no record for the seqid")
+	}
+	if _, err := ReadGffWithFasta(gffPath, "data/does_not_exist.fasta"); err == nil {
+		t.Errorf("ReadGffWithFasta() should return an error for a missing fasta")
+	}
+
+	// ##sequence-region is optional, so the seqid can come from the features instead.
+	_ = ioutil.WriteFile(gffPath, []byte("##gff-version 3\nchr2\t.\tgene\t1\t6\t.\t+\t.\tID=gene1\n"), 0644)
+	_ = ioutil.WriteFile(fastaPath, []byte(">chr1 first\nAAAAAAAA\n>chr2 second\nATGCATGC\n"), 0644)
+	if annotatedSequence, err := ReadGffWithFasta(gffPath, fastaPath); err != nil || annotatedSequence.Sequence.Sequence != "ATGCATGC" {
+		t.Errorf("ReadGffWithFasta() without ##sequence-region expected chr2's sequence. Got %+v, %v", annotatedSequence.Sequence, err)
+	}
+}
+
+func TestMergeGff(t *testing.T) {
+	header := "##gff-version 3\n##sequence-region chr1 1 8\n"
+	gene := "chr1\tgenemark\tgene\t1\t8\t.\t+\t.\tID=gene1\n"
+	repeat := "chr1\trepeatmasker\trepeat_region\t2\t5\t.\t+\t.\tID=repeat1\n"
+	fasta := "##FASTA\n>chr1\nATGCATGC\n"
+	files := map[string]string{
+		"data/test_merge_genes.gff":      header + gene + fasta,
+		"data/test_merge_repeats.gff":    header + gene + repeat,
+		"data/test_merge_mismatched.gff": header + repeat + "##FASTA\n>chr1\nATGCATGG\n",
+	}
+	for path, gff := range files {
+		_ = ioutil.WriteFile(path, []byte(gff), 0644)
+		defer os.Remove(path)
+	}
+
+	merged, err := MergeGff([]string{"data/test_merge_genes.gff", "data/test_merge_repeats.gff"})
+	if err != nil {
+		t.Fatalf("MergeGff() returned an unexpected error: %s", err)
+	}
+	if len(merged.Features) != 2 || merged.Features[0].Type != "gene" || merged.Features[1].Type != "repeat_region" {
+		t.Errorf("MergeGff() expected a deduplicated gene and repeat_region. Got %+v", merged.Features)
+	}
+	if merged.Features[1].Provenance.Tool != "ParseGff" || merged.Features[1].Provenance.Parameters["path"] != "data/test_merge_repeats.gff" {
+		t.Errorf("MergeGff() did not record the path a feature came from. Got %+v", merged.Features[1].Provenance)
+	}
+	if merged.Sequence.Sequence != "ATGCATGC" || merged.Meta.Name != "chr1" {
+		t.Errorf("MergeGff() lost the sequence or meta. Got %+v", merged)
+	}
+
+	if _, err := MergeGff([]string{"data/test_merge_genes.gff", "data/test_merge_mismatched.gff"}); err == nil {
+		t.Errorf("MergeGff() should return an error when sequences disagree")
+	}
+	if _, err := MergeGff([]string{"data/test_merge_genes.gff", "data/does_not_exist.gff"}); err == nil {
+		t.Errorf("MergeGff() should return an error for a missing file")
+	}
+}
+
+func TestGffTarget(t *testing.T) {
+	var logBuffer bytes.Buffer
+	log.SetOutput(&logBuffer)
+	defer log.SetOutput(os.Stderr)
+
+	gff := "##gff-version 3\n##sequence-region chr1 1 1000\n" +
+		"chr1\texonerate\tmatch_part\t100\t200\t.\t+\t.\tID=match1;Target=EST23 1 101 -\n" +
+		"chr1\texonerate\tmatch_part\t300\t400\t.\t+\t.\tID=match2;Target=EST23 102 202\n" +
+		"chr1\texonerate\tmatch_part\t500\t600\t.\t+\t.\tID=match3;Target=EST23 one 101\n"
+	annotatedSequence, _ := ParseGff(gff)
+
+	expected := []*Target{{ID: "EST23", Start: 1, End: 101, Strand: "-"}, {ID: "EST23", Start: 102, End: 202}, nil}
+	for featureIndex, feature := range annotatedSequence.Features {
+		if diff := cmp.Diff(expected[featureIndex], feature.Target); diff != "" {
+			t.Errorf("ParseGff() Target of feature %d mismatch (-want +got):\n%s", featureIndex, diff)
+		}
+	}
+	if !strings.Contains(logBuffer.String(), "line 5") {
+		t.Errorf("ParseGff() did not warn about the malformed Target on line 5. Got %q", logBuffer.String())
+	}
+
+	if _, err := ParseTarget("EST23 1 101 ?"); err == nil {
+		t.Errorf("ParseTarget() should return an error for an invalid strand")
+	}
+}
+
 func BenchmarkReadGff(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		ParseGff("data/ecoli-mg1655.gff")
@@ -80,10 +495,698 @@ Gff related tests and benchmarks end here.
 
 /******************************************************************************
 
-Gbk/gb/genbank related benchmarks begin here.
+Gbk/gb/genbank related tests and benchmarks begin here.
 
 ******************************************************************************/
 
+func TestParseLocation(t *testing.T) {
+	parsedLocation, err := parseLocation("complement(join(12..78,\n134..>202))")
+	if err != nil {
+		t.Fatalf("parseLocation() returned an unexpected error: %s", err)
+	}
+	expected := Location{
+		Start:             12,
+		End:               202,
+		Complement:        true,
+		Join:              true,
+		ThreePrimePartial: true,
+		SubLocations: []Location{
+			{Start: 12, End: 78},
+			{Start: 134, End: 202, ThreePrimePartial: true},
+		},
+	}
+	if diff := cmp.Diff(expected, parsedLocation); diff != "" {
+		t.Errorf("parseLocation() mismatch (-want +got):\n%s", diff)
+	}
+
+	singleBase, err := parseLocation("<1")
+	if err != nil || singleBase.Start != 1 || singleBase.End != 1 || !singleBase.FivePrimePartial {
+		t.Errorf("parseLocation() did not parse a partial single base location. Got %+v, %v", singleBase, err)
+	}
+
+	if _, err := parseLocation("12..abc"); err == nil {
+		t.Errorf("parseLocation() should return an error for malformed coordinates")
+	}
+
+	if diff := cmp.Diff(expected, ParseLocation("complement(join(12..78, 134..>202))")); diff != "" {
+		t.Errorf("ParseLocation() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(Location{}, ParseLocation("J00194.1:100..202")); diff != "" {
+		t.Errorf("ParseLocation() should return an empty Location for a remote location (-want +got):\n%s", diff)
+	}
+}
+
+func TestGbkWrappedLocation(t *testing.T) {
+	segments := make([]string, 12)
+	for segmentIndex := range segments {
+		segments[segmentIndex] = strconv.Itoa(segmentIndex*100+1) + ".." + strconv.Itoa(segmentIndex*100+50)
+	}
+	joinedLocation := "complement(join(" + strings.Join(segments, ",") + "))"
+	annotatedSequence := MinimalGbk("pJoin", strings.Repeat("A", 1200), false)
+	annotatedSequence.Features = append(annotatedSequence.Features, Feature{Type: "CDS", Location: joinedLocation, Attributes: map[string]string{"gene": "joined"}})
+
+	gbk := string(BuildGbk(annotatedSequence))
+	if !strings.Contains(gbk, "     CDS             complement(join(1..50,101..150,201..250,301..350,401..450,\n                     501..550,") {
+		t.Errorf("BuildGbk() did not wrap a long location after a comma:\n%s", gbk)
+	}
+	cds := ParseGbk(gbk).Features[1]
+	if cds.Location != joinedLocation || cds.Start != 1 || cds.End != 1150 || cds.Strand != "-" || cds.Attributes["gene"] != "joined" {
+		t.Errorf("ParseGbk() did not read a wrapped location back. Got %+v", cds)
+	}
+}
+
+func TestFormatLocation(t *testing.T) {
+	for _, locationString := range []string{"1..10", "5", "<1..>10", "complement(join(1..10,<20..30))", "join(complement(5..8),complement(1..3))", "order(1..2,5^6)", "complement(10.20)"} {
+		parsedLocation, err := parseLocation(locationString)
+		if err != nil {
+			t.Fatalf("parseLocation(%q) returned an unexpected error: %s", locationString, err)
+		}
+		if formatted := formatLocation(parsedLocation); formatted != locationString {
+			t.Errorf("formatLocation() expected %q. Got %q", locationString, formatted)
+		}
+	}
+}
+
+func TestQualifierContinuationLines(t *testing.T) {
+	gbk := "LOCUS       wrapped                   12 bp    DNA     linear   SYN 01-JAN-2020\n" +
+		"FEATURES             Location/Qualifiers\n" +
+		"     CDS             1..12\n" +
+		"                     /note=\"a note that wraps onto\n" +
+		"                     a second line\"\n" +
+		"                     /transl_except=(pos:4..6,\n" +
+		"                     aa:Sec)\n" +
+		"                     /translation=\"MKVL\n" +
+		"                     AAGT\"\n" +
+		"ORIGIN\n" +
+		"        1 atgaaagtgt aa\n" +
+		"//\n"
+	attributes := ParseGbk(gbk).Features[0].Attributes
+	if attributes["note"] != "a note that wraps onto a second line" {
+		t.Errorf("ParseGbk() should join wrapped free text with a space. Got %q", attributes["note"])
+	}
+	if attributes["translation"] != "MKVLAAGT" {
+		t.Errorf("ParseGbk() should join a wrapped translation without spaces. Got %q", attributes["translation"])
+	}
+	if attributes["transl_except"] != "(pos:4..6,aa:Sec)" {
+		t.Errorf("ParseGbk() should join a wrapped transl_except without spaces. Got %q", attributes["transl_except"])
+	}
+}
+
+func TestRegisterFeatureType(t *testing.T) {
+	if !IsValidFeatureType("CDS") || !IsValidQualifier("gene") || !IsValidQualifier("/pseudo") || !IsValidQualifier("/gene=") {
+		t.Errorf("INSDC feature types and qualifiers should be valid")
+	}
+
+	annotatedSequence := AnnotatedSequence{
+		Features: []Feature{{Type: "test_widget", Location: "1..9", Attributes: map[string]string{"test_widget_id": "w1"}}},
+	}
+	if IsValidFeatureType("test_widget") || IsValidQualifier("test_widget_id") {
+		t.Fatalf("custom feature types and qualifiers should not be valid before they're registered")
+	}
+	if errs := annotatedSequence.ValidateForSubmission(); len(errs) != 2 {
+		t.Errorf("ValidateForSubmission() expected 2 errors for an unregistered type and qualifier. Got %v", errs)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			RegisterFeatureType("test_widget")
+			RegisterQualifier("/test_widget_id=")
+			IsValidFeatureType("test_widget")
+		}()
+	}
+	wg.Wait()
+
+	if !IsValidFeatureType("test_widget") || !IsValidQualifier("test_widget_id") || !geneQualifierTypeCheck("/test_widget_id=\"w1\"") {
+		t.Errorf("registered feature types and qualifiers should be valid")
+	}
+	if errs := annotatedSequence.ValidateForSubmission(); len(errs) != 0 {
+		t.Errorf("ValidateForSubmission() should accept registered types and qualifiers. Got %v", errs)
+	}
+}
+
+func TestGbkBlankLinesInFeatures(t *testing.T) {
+	annotatedSequence := ParseGbk(`LOCUS       BLANKS                    12 bp    DNA     linear   SYN 01-JAN-1980
+FEATURES             Location/Qualifiers
+
+     gene            1..6
+                     /gene="first"
+
+                     /note="after a blank
+   
+                     line"
+
+     gene            7..12
+                     /gene="second"
+
+ORIGIN
+        1 atgcatgcat gc
+//
+`)
+	if len(annotatedSequence.Features) != 2 {
+		t.Fatalf("ParseGbk() expected 2 features around blank lines. Got %d", len(annotatedSequence.Features))
+	}
+	if annotatedSequence.Features[0].Attributes["note"] != "after a blank line" || annotatedSequence.Features[1].Attributes["gene"] != "second" {
+		t.Errorf("ParseGbk() lost qualifiers around blank lines. Got %v and %v", annotatedSequence.Features[0].Attributes, annotatedSequence.Features[1].Attributes)
+	}
+	if annotatedSequence.Sequence.Sequence != "atgcatgcatgc" {
+		t.Errorf("ParseGbk() expected the sequence after the features. Got %q", annotatedSequence.Sequence.Sequence)
+	}
+}
+
+func TestFlagQualifiers(t *testing.T) {
+	flagQualifiers := []string{"environmental_sample", "focus", "germline", "macronuclear", "partial", "proviral", "pseudo",
+		"rearranged", "ribosomal_slippage", "transgenic", "trans_splicing"}
+	gbk := "LOCUS       flags                      9 bp    DNA     linear   SYN 01-JAN-2020\n" +
+		"FEATURES             Location/Qualifiers\n" +
+		"     CDS             1..9\n"
+	for _, flagQualifier := range flagQualifiers {
+		gbk += "                     /" + flagQualifier + "\n"
+	}
+	gbk += "                     /note=\"\"\n" +
+		"                     /standard_name=\"true\"\n" +
+		"                     /lab_flag\n" +
+		"ORIGIN\n" +
+		"        1 atgaaataa\n" +
+		"//\n"
+
+	feature := ParseGbk(gbk).Features[0]
+	for _, flagQualifier := range flagQualifiers {
+		if value, ok := feature.Attributes[flagQualifier]; !ok || value != "true" {
+			t.Errorf("ParseGbk() expected flag qualifier /%s to be stored as true. Got %q, %t", flagQualifier, value, ok)
+		}
+	}
+	if value, ok := feature.Attributes["note"]; !ok || value != "" {
+		t.Errorf("ParseGbk() expected an empty /note to stay empty. Got %q, %t", value, ok)
+	}
+	if !feature.IsPseudo() || !feature.IsPartial() {
+		t.Errorf("IsPseudo() and IsPartial() should be true for features with /pseudo and /partial flags")
+	}
+
+	built := formatGbkFeature(feature)
+	for _, line := range []string{"/pseudo\n", "/lab_flag\n", "/standard_name=\"true\"\n"} {
+		if !strings.Contains(built, line) {
+			t.Errorf("formatGbkFeature() expected %q. Got:\n%s", line, built)
+		}
+	}
+	built = formatGbkFeature(Feature{Type: "CDS", Location: "1..9", Attributes: map[string]string{"pseudo": "true", "note": "true"}})
+	if !strings.Contains(built, "/pseudo\n") || !strings.Contains(built, "/note=\"true\"\n") {
+		t.Errorf("formatGbkFeature() should only write INSDC flags bare for features that weren't parsed. Got:\n%s", built)
+	}
+}
+
+func TestRepeatedQualifiers(t *testing.T) {
+	gbk := "LOCUS       tiny                       9 bp    DNA     linear   SYN 01-JAN-2020\n" +
+		"FEATURES             Location/Qualifiers\n" +
+		"     CDS             1..9\n" +
+		"                     /gene=\"tiny\"\n" +
+		"                     /db_xref=\"GI:1786182\"\n" +
+		"                     /db_xref=\"ASAP:ABE-0000006\"\n" +
+		"                     /db_xref=\"UniProtKB/Swiss-Prot:P0AD86\"\n" +
+		"ORIGIN\n" +
+		"        1 atgaaataa\n" +
+		"//\n"
+	feature := ParseGbk(gbk).Features[0]
+
+	expected := []string{"GI:1786182", "ASAP:ABE-0000006", "UniProtKB/Swiss-Prot:P0AD86"}
+	if diff := cmp.Diff(expected, feature.QualifierValues("db_xref")); diff != "" {
+		t.Errorf("QualifierValues() mismatch for a repeated qualifier (-want +got):\n%s", diff)
+	}
+	if feature.Attributes["db_xref"] != "GI:1786182" || len(feature.RepeatedQualifiers["db_xref"]) != 2 {
+		t.Errorf("ParseGbk() should keep the first value of a repeated qualifier in Attributes and the rest in RepeatedQualifiers. Got %q and %q", feature.Attributes["db_xref"], feature.RepeatedQualifiers["db_xref"])
+	}
+	// a value holding a comma is still one value.
+	feature.Attributes["note"] = "first, with a comma"
+	feature.RepeatedQualifiers["note"] = []string{"second"}
+	if diff := cmp.Diff([]string{"first, with a comma", "second"}, feature.QualifierValues("note")); diff != "" {
+		t.Errorf("QualifierValues() mismatch for a repeated qualifier holding a comma (-want +got):\n%s", diff)
+	}
+	reparsed := ParseGbk(string(BuildGbk(AnnotatedSequence{Sequence: Sequence{Sequence: "atgaaataa"}, Features: []Feature{feature}}))).Features[0]
+	if diff := cmp.Diff(feature.QualifierValues("note"), reparsed.QualifierValues("note")); diff != "" {
+		t.Errorf("repeated qualifiers did not survive a genbank round trip (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"tiny"}, feature.QualifierValues("gene")); diff != "" {
+		t.Errorf("QualifierValues() mismatch for a single qualifier (-want +got):\n%s", diff)
+	}
+	if feature.QualifierValues("product") != nil {
+		t.Errorf("QualifierValues() should return nil for a missing qualifier")
+	}
+}
+
+func TestGetSequenceWarnings(t *testing.T) {
+	var logBuffer bytes.Buffer
+	log.SetOutput(&logBuffer)
+	defer log.SetOutput(os.Stderr)
+
+	cleanSequence := getSequence([]string{"        1 atgc atgc", "//"})
+	if cleanSequence.Sequence != "atgcatgc" || logBuffer.Len() != 0 {
+		t.Errorf("getSequence() expected atgcatgc without warnings. Got %q and %q", cleanSequence.Sequence, logBuffer.String())
+	}
+
+	corruptSequence := getSequence([]string{"        1 atgc atgc", "        9 at?c @tgc", "//"})
+	if corruptSequence.Sequence != "atgcatgcatctgc" {
+		t.Errorf("getSequence() expected atgcatgcatctgc. Got %q", corruptSequence.Sequence)
+	}
+	if !strings.Contains(logBuffer.String(), `"?@"`) || !strings.Contains(logBuffer.String(), "line 2") {
+		t.Errorf("getSequence() did not warn about the stripped characters on line 2. Got %q", logBuffer.String())
+	}
+}
+
+func TestJoinSubLines(t *testing.T) {
+	gbk := "LOCUS       wrapped                    4 bp    DNA     linear   SYN 01-JAN-2020\n" +
+		"DEFINITION  Escherichia coli str. K-12 substr. MG1655 plasmid with a very long\n" +
+		"            definition that wraps across four lines,   \n" +
+		"            \n" +
+		"            one of which is blank and another of which is\n" +
+		"  x\n" +
+		"            complete sequence.\n" +
+		"ACCESSION   NC_000000\n" +
+		"ORIGIN\n" +
+		"        1 atgc\n" +
+		"//\n"
+	meta := ParseGbk(gbk).Meta
+	expected := "Escherichia coli str. K-12 substr. MG1655 plasmid with a very long definition that wraps across four lines, " +
+		"one of which is blank and another of which is x complete sequence."
+	if meta.Definition != expected {
+		t.Errorf("ParseGbk() expected definition %q. Got %q", expected, meta.Definition)
+	}
+	if meta.Accession != "NC_000000" {
+		t.Errorf("ParseGbk() expected the definition to stop at ACCESSION. Got accession %q", meta.Accession)
+	}
+}
+
+func TestResolveContig(t *testing.T) {
+	scaffold := ParseGbk(`LOCUS       SCAFFOLD1                 31 bp    DNA     linear   CON 01-JAN-2020
+DEFINITION  test scaffold.
+FEATURES             Location/Qualifiers
+     source          1..31
+                     /mol_type="genomic DNA"
+CONTIG      join(CTG1.1:3..8,gap(5),complement(CTG2.1:1..4),
+            gap(unk10),CTG1.1:1..2,gap(),CTG2.1:2)
+//
+`)
+	if scaffold.Meta.Contig != "join(CTG1.1:3..8,gap(5),complement(CTG2.1:1..4),gap(unk10),CTG1.1:1..2,gap(),CTG2.1:2)" {
+		t.Fatalf("ParseGbk() expected the CONTIG line with whitespace removed. Got %q", scaffold.Meta.Contig)
+	}
+	if len(scaffold.Features) != 1 {
+		t.Errorf("ParseGbk() expected the source feature before CONTIG. Got %d features", len(scaffold.Features))
+	}
+
+	lookups := 0
+	contigs := map[string]string{"CTG1.1": "AACCGGTTAA", "CTG2.1": "ATGCCC"}
+	lookup := func(accession string) (AnnotatedSequence, error) {
+		lookups++
+		sequence, ok := contigs[accession]
+		if !ok {
+			return AnnotatedSequence{}, errors.New("not found")
+		}
+		return AnnotatedSequence{Sequence: Sequence{Sequence: sequence}}, nil
+	}
+	sequence, err := scaffold.ResolveContig(lookup)
+	if err != nil {
+		t.Fatalf("ResolveContig() returned an unexpected error: %s", err)
+	}
+	expected := "CCGGTT" + "NNNNN" + "GCAT" + strings.Repeat("N", 10) + "AA" + strings.Repeat("N", 100) + "T"
+	if sequence != expected {
+		t.Errorf("ResolveContig() expected %s. Got %s", expected, sequence)
+	}
+	if lookups != 2 {
+		t.Errorf("ResolveContig() should look up each accession once. Got %d lookups", lookups)
+	}
+
+	scaffold.Meta.Contig = "join(CTG3.1:1..5)"
+	if _, err := scaffold.ResolveContig(lookup); err == nil {
+		t.Errorf("ResolveContig() should return an error when a lookup fails")
+	}
+	scaffold.Meta.Contig = "join(CTG1.1:5..20)"
+	if _, err := scaffold.ResolveContig(lookup); err == nil {
+		t.Errorf("ResolveContig() should return an error for a part outside its record")
+	}
+	if _, err := (AnnotatedSequence{}).ResolveContig(lookup); err == nil {
+		t.Errorf("ResolveContig() should return an error without a CONTIG line")
+	}
+}
+
+func TestGbkTaxonomy(t *testing.T) {
+	meta := ReadGbk("data/bsub.gbk").Meta
+	if meta.Organism != "Bacillus subtilis subsp. subtilis str. 168" {
+		t.Errorf("ParseGbk() expected organism Bacillus subtilis subsp. subtilis str. 168. Got %s", meta.Organism)
+	}
+	expected := []string{"Bacteria", "Firmicutes", "Bacilli", "Bacillales", "Bacillaceae", "Bacillus"}
+	if diff := cmp.Diff(expected, meta.Taxonomy); diff != "" {
+		t.Errorf("ParseGbk() taxonomy mismatch (-want +got):\n%s", diff)
+	}
+
+	wrapped := "LOCUS       wrapped                    4 bp    DNA     linear   BCT 01-JAN-2020\n" +
+		"SOURCE      Escherichia coli\n" +
+		"  ORGANISM  Escherichia coli str. K-12 substr. MG1655 with a name long enough\n" +
+		"            to wrap\n" +
+		"            Bacteria; Proteobacteria; Gammaproteobacteria; Enterobacterales;\n" +
+		"            Enterobacteriaceae; Escherichia.\n" +
+		"ORIGIN\n" +
+		"        1 atgc\n" +
+		"//\n"
+	meta = ParseGbk(wrapped).Meta
+	if meta.Organism != "Escherichia coli str. K-12 substr. MG1655 with a name long enough to wrap" {
+		t.Errorf("ParseGbk() did not join a wrapped organism name. Got %s", meta.Organism)
+	}
+	expected = []string{"Bacteria", "Proteobacteria", "Gammaproteobacteria", "Enterobacterales", "Enterobacteriaceae", "Escherichia"}
+	if diff := cmp.Diff(expected, meta.Taxonomy); diff != "" {
+		t.Errorf("ParseGbk() wrapped taxonomy mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGbkWithoutFeatures(t *testing.T) {
+	gbk := "LOCUS       bare                      70 bp    DNA     circular SYN 01-JAN-2020\n" +
+		"DEFINITION  a record with no feature table.\n" +
+		"ORIGIN\n" +
+		"        1 atgcatgcat gcatgcatgc atgcatgcat gcatgcatgc atgcatgcat gcatgcatgc\n" +
+		"       61 aaaaaaaaaa\n" +
+		"//\n" +
+		"LOCUS       next                       4 bp    DNA     linear   SYN 01-JAN-2020\n" +
+		"ORIGIN\n" +
+		"        1 cccc\n" +
+		"//\n"
+	annotatedSequence := ParseGbk(gbk)
+
+	expectedLocus := Locus{Name: "bare", SequenceLength: "70 bp", MoleculeType: "DNA", GenBankDivision: "SYN", ModDate: "01-JAN-2020", Circular: true}
+	if diff := cmp.Diff(expectedLocus, annotatedSequence.Meta.Locus); diff != "" {
+		t.Errorf("ParseGbk() locus mismatch (-want +got):\n%s", diff)
+	}
+	if len(annotatedSequence.Features) != 0 {
+		t.Errorf("ParseGbk() expected no features. Got %+v", annotatedSequence.Features)
+	}
+	if expected := strings.Repeat("atgc", 15) + strings.Repeat("a", 10); annotatedSequence.Sequence.Sequence != expected {
+		t.Errorf("ParseGbk() expected sequence %s. Got %s", expected, annotatedSequence.Sequence.Sequence)
+	}
+}
+
+func TestParsedModDate(t *testing.T) {
+	modDate, err := ReadGbk("data/bsub.gbk").Meta.Locus.ParsedModDate()
+	if err != nil {
+		t.Fatalf("ParsedModDate() returned an unexpected error: %s", err)
+	}
+	if expected := time.Date(2018, time.September, 18, 0, 0, 0, 0, time.UTC); !modDate.Equal(expected) {
+		t.Errorf("ParsedModDate() expected %s. Got %s", expected, modDate)
+	}
+
+	for _, invalidDate := range []string{"", "2014-12-16", "16-DEX-2014", "32-DEC-2014"} {
+		if _, err := (Locus{ModDate: invalidDate}).ParsedModDate(); err == nil {
+			t.Errorf("ParsedModDate() should return an error for %q", invalidDate)
+		}
+	}
+}
+
+func TestReadGbkMeta(t *testing.T) {
+	meta, err := ReadGbkMeta("data/bsub.gbk")
+	if err != nil {
+		t.Fatalf("ReadGbkMeta() returned an unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(ReadGbk("data/bsub.gbk").Meta, meta); diff != "" {
+		t.Errorf("ReadGbkMeta() mismatch with ReadGbk() (-want +got):\n%s", diff)
+	}
+
+	if _, err := ReadGbkMeta("data/ecoli-mg1655.gff"); err == nil {
+		t.Errorf("ReadGbkMeta() should return an error for a file that isn't genbank")
+	}
+	if _, err := ReadGbkMeta("data/does_not_exist.gbk"); err == nil {
+		t.Errorf("ReadGbkMeta() should return an error for a missing file")
+	}
+}
+
+func BenchmarkReadGbkMeta(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		_, _ = ReadGbkMeta("data/bsub.gbk")
+	}
+}
+
+func TestReadGbkVerified(t *testing.T) {
+	tiny := "LOCUS       tiny                       8 bp    DNA     linear   SYN 01-JAN-2020\n" +
+		"ORIGIN\n" +
+		"        1 atgcatgc\n" +
+		"//\n"
+	testOutputPath := "data/test_verified.gbk"
+	_ = ioutil.WriteFile(testOutputPath, []byte(tiny), 0644)
+	defer os.Remove(testOutputPath)
+
+	annotatedSequence, err := ReadGbkVerified(testOutputPath, "ib39AZ7gpKDhfR2sKvOg4lCj2Ow")
+	if err != nil {
+		t.Fatalf("ReadGbkVerified() returned an unexpected error: %s", err)
+	}
+	if annotatedSequence.Sequence.Sequence != "atgcatgc" {
+		t.Errorf("ReadGbkVerified() expected sequence atgcatgc. Got %s", annotatedSequence.Sequence.Sequence)
+	}
+
+	if _, err := ReadGbkVerified(testOutputPath, "not-the-seguid"); err == nil {
+		t.Errorf("ReadGbkVerified() should return an error on a SEGUID mismatch")
+	}
+	if _, err := ReadGbkVerified("data/does_not_exist.gbk", "ib39AZ7gpKDhfR2sKvOg4lCj2Ow"); err == nil {
+		t.Errorf("ReadGbkVerified() should return an error for a missing file")
+	}
+}
+
+func TestReadGbkTarGz(t *testing.T) {
+	bsub, _ := ioutil.ReadFile("data/bsub.gbk")
+	tiny := "LOCUS       tiny                       8 bp    DNA     linear   SYN 01-JAN-2020\n" +
+		"ORIGIN\n" +
+		"        1 atgcatgc\n" +
+		"//\n"
+	members := []struct{ name, content string }{
+		{"ncbi_dataset/bsub.gbk", string(bsub)},
+		{"ncbi_dataset/README.md", "not a genbank file"},
+		{"ncbi_dataset/broken.gb", "this is not genbank"},
+		{"ncbi_dataset/tiny.genbank", tiny + strings.Replace(tiny, "tiny", "tinier", 1)},
+		// a LOCUS line and feature lines cut short, and a blank line in the SOURCE block, used to panic the parser.
+		{"ncbi_dataset/truncated.gbk", "LOCUS       trunc\nSOURCE      x\n\n  ORGANISM  x\nFEATURES             Location/Qualifiers\n     CDS             1..3\n          /\n     gene\n"},
+	}
+
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, member := range members {
+		_ = tarWriter.WriteHeader(&tar.Header{Name: member.name, Mode: 0644, Size: int64(len(member.content)), Typeflag: tar.TypeReg})
+		_, _ = tarWriter.Write([]byte(member.content))
+	}
+	_ = tarWriter.Close()
+	_ = gzipWriter.Close()
+	testOutputPath := "data/test_archive.tar.gz"
+	_ = ioutil.WriteFile(testOutputPath, archive.Bytes(), 0644)
+	defer os.Remove(testOutputPath)
+
+	annotatedSequences, err := ReadGbkTarGz(testOutputPath, 4)
+	readErrors, ok := err.(ReadErrors)
+	if !ok || len(readErrors) != 1 || !strings.Contains(readErrors[0].Error(), "broken.gb") {
+		t.Errorf("ReadGbkTarGz() expected a single error for broken.gb. Got %v", err)
+	}
+	var names []string
+	for _, annotatedSequence := range annotatedSequences {
+		names = append(names, annotatedSequence.Meta.Locus.Name)
+	}
+	if diff := cmp.Diff([]string{"NC_000964", "tiny", "tinier", "trunc"}, names); diff != "" {
+		t.Fatalf("ReadGbkTarGz() expected every record of every member in archive order (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(ParseGbk(string(bsub)), annotatedSequences[0]); diff != "" {
+		t.Errorf("ReadGbkTarGz() parsed bsub differently than ParseGbk() (-want +got):\n%s", diff)
+	}
+
+	if _, err := ReadGbkTarGz("data/bsub.gbk", 1); err == nil {
+		t.Errorf("ReadGbkTarGz() should return an error for a file that isn't gzipped")
+	}
+}
+
+func TestMinimalGbk(t *testing.T) {
+	annotatedSequence := MinimalGbk("pTiny", "ATGCATGCAA", true)
+	expectedLocus := Locus{Name: "pTiny", SequenceLength: "10 bp", MoleculeType: "DNA", GenBankDivision: "SYN", ModDate: "01-JAN-1980", Circular: true}
+	if diff := cmp.Diff(expectedLocus, annotatedSequence.Meta.Locus); diff != "" {
+		t.Errorf("MinimalGbk() locus mismatch (-want +got):\n%s", diff)
+	}
+	if len(annotatedSequence.Features) != 1 || annotatedSequence.Features[0].Type != "source" || annotatedSequence.Features[0].Location != "1..10" {
+		t.Errorf("MinimalGbk() expected a single source feature spanning 1..10. Got %+v", annotatedSequence.Features)
+	}
+	if gff := string(BuildGff(annotatedSequence)); !strings.Contains(gff, "pTiny\tfeature\tsource\t1\t10\t.\t+\t.\t") {
+		t.Errorf("BuildGff() of a MinimalGbk() expected a source line from 1 to 10 on the plus strand. Got:\n%s", gff)
+	}
+
+	if rna := MinimalGbk("tinyRNA", "AUGC", false); rna.Meta.Locus.MoleculeType != "RNA" || rna.Features[0].Attributes["mol_type"] != "other RNA" {
+		t.Errorf("MinimalGbk() expected an RNA molecule type for a sequence with U. Got %+v", rna.Meta.Locus)
+	}
+}
+
+func TestBuildGbk(t *testing.T) {
+	// data/bsub_fragment.gbk is the golden file, the first 3200 bases of bsub.gbk with a few features added to cover
+	// joins, partial ends, flags, and qualifiers too long to wrap.
+	golden, _ := ioutil.ReadFile("data/bsub_fragment.gbk")
+	fragment := ParseGbk(string(golden))
+	if built := BuildGbk(fragment); !bytes.Equal(golden, built) {
+		t.Errorf("BuildGbk() did not reproduce data/bsub_fragment.gbk. Got:\n%s", built)
+	}
+
+	bsub := ReadGbk("data/bsub.gbk")
+	if diff := cmp.Diff(bsub, ParseGbk(string(BuildGbk(bsub)))); diff != "" {
+		t.Errorf("BuildGbk() did not round trip data/bsub.gbk (-want +got):\n%s", diff)
+	}
+
+	testGbkOutputPath := "data/test_write.gbk"
+	WriteGbk(fragment, testGbkOutputPath)
+	defer os.Remove(testGbkOutputPath)
+	if diff := cmp.Diff(fragment, ReadGbk(testGbkOutputPath)); diff != "" {
+		t.Errorf("WriteGbk() did not round trip through ReadGbk (-want +got):\n%s", diff)
+	}
+
+	minimal := MinimalGbk("pTiny", "atgcatgcaa", true)
+	minimalGbk := string(BuildGbkWithOptions(minimal, GbkOptions{IncludeChecksum: true, Case: CaseUpper}))
+	if !strings.HasPrefix(minimalGbk, "LOCUS       pTiny                     10 bp    DNA     circular SYN 01-JAN-1980\n") {
+		t.Errorf("BuildGbkWithOptions() wrote an unexpected LOCUS line:\n%s", minimalGbk)
+	}
+	if !strings.Contains(minimalGbk, "        1 ATGCATGCAA\n//\n") {
+		t.Errorf("BuildGbkWithOptions() did not uppercase the ORIGIN block:\n%s", minimalGbk)
+	}
+	parsed := ParseGbk(minimalGbk)
+	if parsed.Sequence.Seguid == "" || parsed.VerifySeguid() != nil {
+		t.Errorf("ParseGbk() should read the SEGUID comment and verify. Got %q", parsed.Sequence.Seguid)
+	}
+}
+
+func TestGbkFeatureColumns(t *testing.T) {
+	// features read from a gff have no Location of their own, so they also cover locations made from Start and End.
+	ecoli := ReadGff("data/ecoli-mg1655.gff")
+	fragment := ReadGbk("data/bsub_fragment.gbk")
+	for _, annotatedSequence := range []AnnotatedSequence{ecoli, fragment} {
+		gbk := string(BuildGbk(annotatedSequence))
+		featureTable := gbk[strings.Index(gbk, "\nFEATURES")+1 : strings.Index(gbk, "\nORIGIN")]
+		for lineIndex, line := range strings.Split(featureTable, "\n")[1:] {
+			if strings.TrimSpace(line[:qualifierIndex]) == "" {
+				// qualifiers and their continuation lines start at column 22.
+				if line[qualifierIndex] == ' ' {
+					t.Errorf("BuildGbk() wrote feature table line %d without anything at column 22: %q", lineIndex+2, line)
+				}
+				continue
+			}
+			// feature keys start at column 6 and their locations at column 22.
+			if line[:subMetaIndex] != "     " || line[subMetaIndex] == ' ' || line[qualifierIndex-1] != ' ' || line[qualifierIndex] == ' ' {
+				t.Errorf("BuildGbk() wrote feature table line %d with a key or location out of column: %q", lineIndex+2, line)
+			}
+		}
+
+		reparsed := ParseGbk(gbk)
+		if len(reparsed.Features) != len(annotatedSequence.Features) {
+			t.Errorf("ParseGbk() expected %d features from BuildGbk(). Got %d", len(annotatedSequence.Features), len(reparsed.Features))
+			continue
+		}
+		for featureIndex, feature := range reparsed.Features {
+			if feature.Type != annotatedSequence.Features[featureIndex].Type || feature.Length() != annotatedSequence.Features[featureIndex].Length() {
+				t.Errorf("ParseGbk() feature %d of BuildGbk() expected %s of length %d. Got %s of length %d", featureIndex, annotatedSequence.Features[featureIndex].Type, annotatedSequence.Features[featureIndex].Length(), feature.Type, feature.Length())
+				break
+			}
+		}
+	}
+}
+
+func TestParseGbkMulti(t *testing.T) {
+	first, second := MinimalGbk("pFirst", "ATGCATGC", false), MinimalGbk("pSecond", "GGCC", true)
+	multiGbk := string(BuildGbk(first)) + string(BuildGbk(second)) + "\n\n"
+
+	annotatedSequences := ParseGbkMulti(multiGbk)
+	if len(annotatedSequences) != 2 {
+		t.Fatalf("ParseGbkMulti() expected 2 records without an extra one for the trailing blank lines. Got %d", len(annotatedSequences))
+	}
+	for recordIndex, expected := range []AnnotatedSequence{first, second} {
+		if got := annotatedSequences[recordIndex]; got.Meta.Locus.Name != expected.Meta.Locus.Name || got.Sequence.Sequence != expected.Sequence.Sequence {
+			t.Errorf("ParseGbkMulti() record %d expected %s with sequence %s. Got %s with sequence %s", recordIndex, expected.Meta.Locus.Name, expected.Sequence.Sequence, got.Meta.Locus.Name, got.Sequence.Sequence)
+		}
+	}
+	if diff := cmp.Diff(annotatedSequences[0], ParseGbk(multiGbk)); diff != "" {
+		t.Errorf("ParseGbk() should parse the first record of a multi-record file (-want +got):\n%s", diff)
+	}
+
+	// a final record missing its "//" is still parsed.
+	unterminated := strings.TrimSuffix(string(BuildGbk(second)), "//\n")
+	if records := ParseGbkMulti(string(BuildGbk(first)) + unterminated); len(records) != 2 || records[1].Sequence.Sequence != "GGCC" {
+		t.Errorf("ParseGbkMulti() did not parse a final record without a terminator. Got %+v", records)
+	}
+
+	multiGbkPath := "data/test_multi.gbk"
+	_ = ioutil.WriteFile(multiGbkPath, []byte(multiGbk), 0644)
+	defer os.Remove(multiGbkPath)
+	if diff := cmp.Diff(annotatedSequences, ReadGbkMulti(multiGbkPath)); diff != "" {
+		t.Errorf("ReadGbkMulti() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteGbkEach(t *testing.T) {
+	dir := "data/test_write_each"
+	defer os.RemoveAll(dir)
+	records := []AnnotatedSequence{
+		MinimalGbk("pTiny", "ATGCATGCAA", true),
+		MinimalGbk("pTiny", "GGGCCCAAAT", false),
+		MinimalGbk("", "ATAT", false),
+	}
+	records[2].Meta.Accession = "AB000001"
+
+	// a directory where pOther.gbk should go makes that one write fail without stopping the others.
+	other := MinimalGbk("pOther", "CCCC", false)
+	if err := os.MkdirAll(filepath.Join(dir, "pOther.gbk"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := WriteGbkEach(append(records, other), dir, 2)
+	writeErrors, ok := err.(WriteErrors)
+	if !ok || len(writeErrors) != 1 || !strings.Contains(writeErrors.Error(), "pOther.gbk") {
+		t.Errorf("WriteGbkEach() expected a single WriteErrors for pOther.gbk. Got %v", err)
+	}
+
+	for recordIndex, name := range []string{"pTiny", "pTiny_2", "AB000001"} {
+		written := ReadGbk(filepath.Join(dir, name+".gbk"))
+		if written.Sequence.Sequence != records[recordIndex].Sequence.Sequence {
+			t.Errorf("WriteGbkEach() expected %s.gbk to hold record %d. Got sequence %q", name, recordIndex, written.Sequence.Sequence)
+		}
+	}
+}
+
+func TestIndexGbk(t *testing.T) {
+	bsub, _ := ioutil.ReadFile("data/bsub.gbk")
+	tiny := "LOCUS       tiny                       8 bp    DNA     linear   SYN 01-JAN-2020\n" +
+		"FEATURES             Location/Qualifiers\n" +
+		"     gene            1..8\n" +
+		"                     /gene=\"tiny\"\n" +
+		"ORIGIN\n" +
+		"        1 atgcatgc\n" +
+		"//\n"
+	testOutputPath := "data/test_index.gbk"
+	_ = ioutil.WriteFile(testOutputPath, append(bsub, tiny...), 0644)
+	defer os.Remove(testOutputPath)
+
+	recordOffsets, err := IndexGbk(testOutputPath)
+	if err != nil {
+		t.Fatalf("IndexGbk() returned an unexpected error: %s", err)
+	}
+	expected := []RecordOffset{{Name: "NC_000964", Offset: 0}, {Name: "tiny", Offset: int64(len(bsub))}}
+	if diff := cmp.Diff(expected, recordOffsets); diff != "" {
+		t.Fatalf("IndexGbk() mismatch (-want +got):\n%s", diff)
+	}
+
+	tinyRecord, err := ReadGbkAt(testOutputPath, recordOffsets[1].Offset)
+	if err != nil {
+		t.Fatalf("ReadGbkAt() returned an unexpected error: %s", err)
+	}
+	if tinyRecord.Meta.Locus.Name != "tiny" || tinyRecord.Sequence.Sequence != "atgcatgc" || len(tinyRecord.Features) != 1 {
+		t.Errorf("ReadGbkAt() did not read the second record. Got %+v", tinyRecord)
+	}
+
+	bsubRecord, err := ReadGbkAt(testOutputPath, recordOffsets[0].Offset)
+	if err != nil {
+		t.Fatalf("ReadGbkAt() returned an unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(ReadGbk("data/bsub.gbk"), bsubRecord); diff != "" {
+		t.Errorf("ReadGbkAt() on the first record mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := ReadGbkAt(testOutputPath, 1); err == nil {
+		t.Errorf("ReadGbkAt() should return an error for an offset that isn't the start of a record")
+	}
+}
+
 func BenchmarkReadGbk(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		ReadGbk("data/bsub.gbk")
@@ -96,9 +1199,32 @@ func BenchmarkReadGbk100(b *testing.B)   { BenchmarkReadGbk(b) }
 func BenchmarkReadGbk1000(b *testing.B)  { BenchmarkReadGbk(b) }
 func BenchmarkReadGbk10000(b *testing.B) { BenchmarkReadGbk(b) }
 
+// builds a feature table with a single CDS whose /translation qualifier wraps across many lines.
+func longTranslationFeatureLines(residues int) []string {
+	translation := strings.Repeat("M", residues)
+	lines := []string{"     CDS             1.." + strconv.Itoa(residues*3)}
+	qualifier := "/translation=\"" + translation + "\""
+	lines = append(lines, "                     "+qualifier[:58])
+	for index := 58; index < len(qualifier); index += 58 {
+		end := index + 58
+		if end > len(qualifier) {
+			end = len(qualifier)
+		}
+		lines = append(lines, "                     "+qualifier[index:end])
+	}
+	return append(lines, "ORIGIN      ")
+}
+
+func BenchmarkGetFeaturesLongTranslation(b *testing.B) {
+	lines := longTranslationFeatureLines(2000)
+	for i := 0; i < b.N; i++ {
+		getFeatures(lines)
+	}
+}
+
 /******************************************************************************
 
-Gbk/gb/genbank related benchmarks end here.
+Gbk/gb/genbank related tests and benchmarks end here.
 
 ******************************************************************************/
 
@@ -121,8 +1247,428 @@ func TestJSONIO(t *testing.T) {
 	}
 }
 
+func TestWriteJSONResolved(t *testing.T) {
+	testSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: "ATGAAACCCGGGTTT"},
+		Features: []Feature{
+			{Type: "CDS", Location: "complement(join(1..3,7..9))"},
+			{Type: "gene", Start: 4, End: 6, Strand: "+"},
+		},
+	}
+	if err := WriteJSONResolved(testSequence, "data/test_resolved.json"); err != nil {
+		t.Fatalf("WriteJSONResolved() returned an unexpected error: %s", err)
+	}
+	readTestSequence := ReadJSON("data/test_resolved.json")
+
+	// cleaning up test data
+	os.Remove("data/test_resolved.json")
+
+	if readTestSequence.Features[0].Sequence != "GGGCAT" || readTestSequence.Features[1].Sequence != "AAA" {
+		t.Errorf("WriteJSONResolved() did not resolve feature sequences. Got %q and %q", readTestSequence.Features[0].Sequence, readTestSequence.Features[1].Sequence)
+	}
+	if testSequence.Features[0].Sequence != "" {
+		t.Errorf("WriteJSONResolved() modified the features of its input")
+	}
+}
+
+func TestJSONChecksum(t *testing.T) {
+	testSequence := AnnotatedSequence{Sequence: Sequence{Sequence: "ATGAAACCCGGGTTT"}}
+	if err := WriteJSONWithOptions(testSequence, JSONOptions{IncludeChecksum: true}, "data/test_checksum.json"); err != nil {
+		t.Fatalf("WriteJSONWithOptions() returned an unexpected error: %s", err)
+	}
+	readTestSequence := ReadJSON("data/test_checksum.json")
+	os.Remove("data/test_checksum.json")
+
+	if readTestSequence.Sequence.Seguid != Seguid(testSequence.Sequence.Sequence) || readTestSequence.VerifySeguid() != nil {
+		t.Errorf("WriteJSONWithOptions() should embed a SEGUID that verifies. Got %q", readTestSequence.Sequence.Seguid)
+	}
+	readTestSequence.Sequence.Sequence = "ATGAAACCC"
+	if readTestSequence.VerifySeguid() == nil {
+		t.Errorf("VerifySeguid() should fail for a changed sequence")
+	}
+}
+
 /******************************************************************************
 
 JSON related tests end here.
 
 ******************************************************************************/
+
+/******************************************************************************
+
+Feature table related tests begin here.
+
+******************************************************************************/
+
+func TestBuildFeatureTable(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Features: []Feature{
+			{Type: "CDS", Location: "complement(join(10..20,30..40))", Attributes: map[string]string{"gene": "abc", "note": "first line\nsecond\tline"}},
+			{Type: "gene", Start: 5, End: 7, Strand: "+", Attributes: map[string]string{"ID": "gene1"}},
+		},
+	}
+
+	table := string(BuildFeatureTable(annotatedSequence, []string{"type", "start", "end", "strand", "gene", "note", "EC_number"}))
+	expected := "type\tstart\tend\tstrand\tgene\tnote\tEC_number\n" +
+		"CDS\t10\t40\t-\tabc\tfirst line second line\t\n" +
+		"gene\t5\t7\t+\t\t\t\n"
+	if table != expected {
+		t.Errorf("BuildFeatureTable() expected:\n%q\nGot:\n%q", expected, table)
+	}
+}
+
+/******************************************************************************
+
+Feature table related tests end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+GenePred related tests begin here.
+
+******************************************************************************/
+
+func TestBuildGenePred(t *testing.T) {
+	gff := AnnotatedSequence{
+		Meta: Meta{Name: "chr1"},
+		Features: []Feature{
+			{Name: "chr1", Type: "gene", Start: 101, End: 500, Strand: "-", Attributes: map[string]string{"ID": "gene1"}},
+			{Name: "chr1", Type: "mRNA", Start: 101, End: 500, Strand: "-", Attributes: map[string]string{"ID": "mRNA1", "Parent": "gene1"}},
+			{Name: "chr1", Type: "exon", Start: 401, End: 500, Strand: "-", Attributes: map[string]string{"Parent": "mRNA1"}},
+			{Name: "chr1", Type: "exon", Start: 101, End: 200, Strand: "-", Attributes: map[string]string{"Parent": "mRNA1"}},
+			{Name: "chr1", Type: "CDS", Start: 151, End: 200, Strand: "-", Attributes: map[string]string{"Parent": "mRNA1"}},
+			{Name: "chr1", Type: "CDS", Start: 401, End: 450, Strand: "-", Attributes: map[string]string{"Parent": "mRNA1"}},
+			{Name: "chr1", Type: "ncRNA", Start: 601, End: 700, Strand: "+", Attributes: map[string]string{"ID": "ncRNA1"}},
+			{Name: "chr1", Type: "exon", Start: 601, End: 700, Strand: "+", Attributes: map[string]string{"Parent": "ncRNA1"}},
+		},
+	}
+	expected := "mRNA1\tchr1\t-\t100\t500\t150\t450\t2\t100,400,\t200,500,\n" +
+		"ncRNA1\tchr1\t+\t600\t700\t700\t700\t1\t600,\t700,\n"
+	if genePred := string(BuildGenePred(gff)); genePred != expected {
+		t.Errorf("BuildGenePred() of a gff expected:\n%s\nGot:\n%s", expected, genePred)
+	}
+
+	genbank := AnnotatedSequence{
+		Meta: Meta{Locus: Locus{Name: "NC_000001"}},
+		Features: []Feature{
+			{Type: "mRNA", Location: "complement(join(1..100,201..300))", Attributes: map[string]string{"locus_tag": "b0001"}},
+			{Type: "CDS", Location: "complement(join(51..100,201..250))", Attributes: map[string]string{"locus_tag": "b0001"}},
+			{Type: "CDS", Location: "401..490", Attributes: map[string]string{"locus_tag": "b0002"}},
+			// two isoforms of one gene, each with its own CDS.
+			{Type: "mRNA", Location: "join(1001..1100,1201..1300)", Attributes: map[string]string{"gene": "xyz", "transcript_id": "NM_1"}},
+			{Type: "mRNA", Location: "join(1001..1100,1401..1500)", Attributes: map[string]string{"gene": "xyz", "transcript_id": "NM_2"}},
+			{Type: "CDS", Location: "join(1051..1100,1401..1450)", Attributes: map[string]string{"gene": "xyz"}},
+			{Type: "CDS", Location: "join(1061..1100,1201..1250)", Attributes: map[string]string{"gene": "xyz", "transcript_id": "NM_1"}},
+			// names can't contain whitespace, and transcripts without any identifier are named after their span.
+			{Type: "CDS", Location: "2001..2090", Attributes: map[string]string{"locus_tag": " b 0003 "}},
+			{Type: "CDS", Location: "3001..3090", Attributes: map[string]string{"gene": "abc"}},
+		},
+	}
+	expected = "b0001\tNC_000001\t-\t0\t300\t50\t250\t2\t0,200,\t100,300,\n" +
+		"NM_1\tNC_000001\t+\t1000\t1300\t1060\t1250\t2\t1000,1200,\t1100,1300,\n" +
+		"NM_2\tNC_000001\t+\t1000\t1500\t1050\t1450\t2\t1000,1400,\t1100,1500,\n" +
+		"b0002\tNC_000001\t+\t400\t490\t400\t490\t1\t400,\t490,\n" +
+		"b_0003\tNC_000001\t+\t2000\t2090\t2000\t2090\t1\t2000,\t2090,\n" +
+		"CDS_3000_3090\tNC_000001\t+\t3000\t3090\t3000\t3090\t1\t3000,\t3090,\n"
+	if genePred := string(BuildGenePred(genbank)); genePred != expected {
+		t.Errorf("BuildGenePred() of a genbank expected:\n%s\nGot:\n%s", expected, genePred)
+	}
+}
+
+/******************************************************************************
+
+GenePred related tests end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Fasta related tests begin here.
+
+******************************************************************************/
+
+func TestBuildFasta(t *testing.T) {
+	fasta := string(BuildFasta([]Sequence{
+		{Description: ">seq1 a description", Sequence: strings.Repeat("A", 75)},
+		{Description: "seq2", Sequence: "ACGT"},
+	}))
+	expected := ">seq1 a description\n" + strings.Repeat("A", 70) + "\nAAAAA\n>seq2\nACGT\n"
+	if fasta != expected {
+		t.Errorf("BuildFasta() expected %q. Got %q", expected, fasta)
+	}
+}
+
+func TestWriteFeatureSequences(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: "ATGAAATAAGGGTTATTTCAT"},
+		Features: []Feature{
+			{Type: "CDS", Location: "1..9", Attributes: map[string]string{"locus_tag": "b0001"}},
+			{Type: "CDS", Location: "complement(13..21)", Attributes: map[string]string{"locus_tag": "b0001"}},
+			{Type: "CDS", Location: "4..12", Attributes: map[string]string{"ID": "cds:3/a"}},
+			{Type: "gene", Location: "1..21", Attributes: map[string]string{"locus_tag": "b0001"}},
+		},
+	}
+	testOutputDir := "data/test_feature_sequences"
+	defer os.RemoveAll(testOutputDir)
+	if err := annotatedSequence.WriteFeatureSequences(testOutputDir, "CDS"); err != nil {
+		t.Fatalf("WriteFeatureSequences() returned an unexpected error: %s", err)
+	}
+
+	files, _ := ioutil.ReadDir(testOutputDir)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	if diff := cmp.Diff([]string{"b0001.fasta", "b0001_13-21.fasta", "cds_3_a.fasta"}, names); diff != "" {
+		t.Errorf("WriteFeatureSequences() wrote the wrong files (-want +got):\n%s", diff)
+	}
+	minusStrand, _ := ioutil.ReadFile(testOutputDir + "/b0001_13-21.fasta")
+	if expected := ">b0001_13-21 CDS at complement(13..21)\nATGAAATAA\n"; string(minusStrand) != expected {
+		t.Errorf("WriteFeatureSequences() expected %q. Got %q", expected, minusStrand)
+	}
+}
+
+func TestParseFasta(t *testing.T) {
+	sequences := []Sequence{{Description: "first record", Sequence: strings.Repeat("ACGT", 30)}, {Description: "second", Sequence: "GGCC"}}
+	if diff := cmp.Diff(sequences, ParseFasta(string(BuildFasta(sequences)))); diff != "" {
+		t.Errorf("ParseFasta() didn't round trip BuildFasta() (-want +got):\n%s", diff)
+	}
+	expected := []Sequence{{Description: "chr1", Sequence: "ACGTAC"}, {Description: "empty"}}
+	if diff := cmp.Diff(expected, ParseFasta("stray\n>chr1\r\nACG\r\n;comment\nT AC\n>empty\n")); diff != "" {
+		t.Errorf("ParseFasta() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriterCase(t *testing.T) {
+	sequences := []Sequence{{Description: "masked", Sequence: "ACGTacgtNN"}}
+	for sequenceCase, expected := range map[SequenceCase]string{CaseAsIs: "ACGTacgtNN", CaseUpper: "ACGTACGTNN", CaseLower: "acgtacgtnn"} {
+		if fasta := string(BuildFastaWithOptions(sequences, FastaOptions{Case: sequenceCase})); fasta != ">masked\n"+expected+"\n" {
+			t.Errorf("BuildFastaWithOptions() with case %d expected %s. Got %s", sequenceCase, expected, fasta)
+		}
+		annotatedSequence := AnnotatedSequence{Meta: Meta{Name: "masked"}, Sequence: sequences[0]}
+		if gff := string(BuildGffWithOptions(annotatedSequence, GffOptions{IncludeFasta: true, Case: sequenceCase})); !strings.HasSuffix(gff, "\n"+expected+"\n") {
+			t.Errorf("BuildGffWithOptions() with case %d expected %s. Got %s", sequenceCase, expected, gff)
+		}
+	}
+	if sequences[0].Sequence != "ACGTacgtNN" {
+		t.Errorf("BuildFastaWithOptions() modified its input")
+	}
+}
+
+func TestFetchRegion(t *testing.T) {
+	testOutputPath := "data/test_faidx.fasta"
+	fasta := ">chr1 first\nACGTACGTAC\nGTACGTACGT\nACG\n>chr2\r\nTTTTGGGG\r\nCC\r\n"
+	_ = ioutil.WriteFile(testOutputPath, []byte(fasta), 0644)
+	defer os.Remove(testOutputPath)
+
+	if err := BuildFaidx(testOutputPath); err != nil {
+		t.Fatalf("BuildFaidx() returned an unexpected error: %s", err)
+	}
+	defer os.Remove(testOutputPath + ".fai")
+	fai, _ := ioutil.ReadFile(testOutputPath + ".fai")
+	if expected := "chr1\t23\t12\t10\t11\nchr2\t10\t45\t8\t10\n"; string(fai) != expected {
+		t.Errorf("BuildFaidx() expected %q. Got %q", expected, fai)
+	}
+
+	expectedRegions := []struct {
+		name       string
+		start, end int
+		expected   string
+	}{
+		{"chr1", 0, 23, "ACGTACGTACGTACGTACGTACG"},
+		{"chr1", 8, 12, "ACGT"},
+		{"chr1", 20, 23, "ACG"},
+		{"chr2", 6, 10, "GGCC"},
+		{"chr2", 3, 3, ""},
+	}
+	for _, expectedRegion := range expectedRegions {
+		bases, err := FetchRegion(testOutputPath, testOutputPath+".fai", expectedRegion.name, expectedRegion.start, expectedRegion.end)
+		if err != nil {
+			t.Errorf("FetchRegion(%q, %d, %d) returned an unexpected error: %s", expectedRegion.name, expectedRegion.start, expectedRegion.end, err)
+		}
+		if bases != expectedRegion.expected {
+			t.Errorf("FetchRegion(%q, %d, %d) expected %q. Got %q", expectedRegion.name, expectedRegion.start, expectedRegion.end, expectedRegion.expected, bases)
+		}
+	}
+
+	if _, err := FetchRegion(testOutputPath, testOutputPath+".fai", "chr2", 5, 11); err == nil {
+		t.Errorf("FetchRegion() should return an error for a region past the end of a sequence")
+	}
+	if _, err := FetchRegion(testOutputPath, testOutputPath+".fai", "chr3", 0, 1); err == nil {
+		t.Errorf("FetchRegion() should return an error for a sequence that isn't indexed")
+	}
+
+	_ = ioutil.WriteFile(testOutputPath, []byte(">ragged\nACG\nACGT\n"), 0644)
+	if err := BuildFaidx(testOutputPath); err == nil {
+		t.Errorf("BuildFaidx() should return an error for lines of different lengths")
+	}
+
+	_ = ioutil.WriteFile(testOutputPath, []byte(">gapped\nACGT\n\nACGT\n"), 0644)
+	if err := BuildFaidx(testOutputPath); err == nil {
+		t.Errorf("BuildFaidx() should return an error for a blank line inside a record")
+	}
+	_ = ioutil.WriteFile(testOutputPath, []byte(">first\nACGT\n\n>second\nAC\n\n"), 0644)
+	if err := BuildFaidx(testOutputPath); err != nil {
+		t.Errorf("BuildFaidx() should allow blank lines at the end of a record. Got %s", err)
+	}
+}
+
+/******************************************************************************
+
+Fasta related tests end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+2bit related tests begin here.
+
+******************************************************************************/
+
+func Test2bitIO(t *testing.T) {
+	testOutputPath := "data/test.2bit"
+	sequences := []Sequence{
+		{Description: ">chr1 first test sequence", Sequence: "ACGTNNNNacgtRYACG"},
+		{Description: "chr2", Sequence: "GGGGCCCCAAAATTTTG"},
+	}
+	if err := Write2bit(sequences, testOutputPath); err != nil {
+		t.Fatalf("Write2bit() returned an unexpected error: %s", err)
+	}
+	defer os.Remove(testOutputPath)
+
+	index, err := ReadTwoBitIndex(testOutputPath)
+	if err != nil {
+		t.Fatalf("ReadTwoBitIndex() returned an unexpected error: %s", err)
+	}
+	if diff := cmp.Diff([]string{"chr1", "chr2"}, index.Names()); diff != "" {
+		t.Errorf("Names() mismatch (-want +got):\n%s", diff)
+	}
+
+	// ambiguous bases come back as N and lowercase bases stay lowercase.
+	expectedRanges := []struct {
+		name       string
+		start, end int
+		expected   string
+	}{
+		{"chr1", 0, 17, "ACGTNNNNacgtNNACG"},
+		{"chr1", 3, 9, "TNNNNa"},
+		{"chr1", 5, 5, ""},
+		{"chr2", 13, 17, "TTTG"},
+	}
+	for _, expectedRange := range expectedRanges {
+		bases, err := index.Range(expectedRange.name, expectedRange.start, expectedRange.end)
+		if err != nil {
+			t.Errorf("Range(%q, %d, %d) returned an unexpected error: %s", expectedRange.name, expectedRange.start, expectedRange.end, err)
+		}
+		if bases != expectedRange.expected {
+			t.Errorf("Range(%q, %d, %d) expected %q. Got %q", expectedRange.name, expectedRange.start, expectedRange.end, expectedRange.expected, bases)
+		}
+	}
+
+	if _, err := index.Range("chr2", 10, 18); err == nil {
+		t.Errorf("Range() should return an error for a region past the end of a sequence")
+	}
+	if _, err := index.Range("chr3", 0, 1); err == nil {
+		t.Errorf("Range() should return an error for a sequence that isn't in the file")
+	}
+}
+
+/******************************************************************************
+
+2bit related tests end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Conversion related tests begin here.
+
+******************************************************************************/
+
+func TestConvertFiles(t *testing.T) {
+	gbk := ReadGbk("data/bsub.gbk")
+
+	gffOutputPath := "data/test_convert.gff"
+	if err := Convert("data/bsub.gbk", gffOutputPath); err != nil {
+		t.Fatalf("Convert() gbk to gff returned an unexpected error: %s", err)
+	}
+	defer os.Remove(gffOutputPath)
+	gff, _ := ioutil.ReadFile(gffOutputPath)
+	if !bytes.Equal(gff, BuildGff(convertQualifierNames(gbk, "gbk", "gff"))) {
+		t.Errorf("Convert() gbk to gff wrote an unexpected gff file")
+	}
+
+	jsonOutputPath := "data/test_convert.json"
+	if err := Convert("data/ecoli-mg1655.gff", jsonOutputPath); err != nil {
+		t.Fatalf("Convert() gff to json returned an unexpected error: %s", err)
+	}
+	defer os.Remove(jsonOutputPath)
+	if diff := cmp.Diff(ReadGff("data/ecoli-mg1655.gff"), ReadJSON(jsonOutputPath)); diff != "" {
+		t.Errorf("Convert() gff to json mismatch (-want +got):\n%s", diff)
+	}
+
+	fastaOutputPath := "data/test_convert.fasta"
+	if err := Convert("data/bsub.gbk", fastaOutputPath); err != nil {
+		t.Fatalf("Convert() gbk to fasta returned an unexpected error: %s", err)
+	}
+	defer os.Remove(fastaOutputPath)
+	fasta, _ := ioutil.ReadFile(fastaOutputPath)
+	if !bytes.Equal(fasta, BuildFasta([]Sequence{{Description: gbk.Meta.Locus.Name, Sequence: gbk.Sequence.Sequence}})) {
+		t.Errorf("Convert() gbk to fasta wrote an unexpected fasta file")
+	}
+
+	gbkOutputPath := "data/test_convert.gbk"
+	if err := Convert("data/ecoli-mg1655.gff", gbkOutputPath); err != nil {
+		t.Fatalf("Convert() gff to gbk returned an unexpected error: %s", err)
+	}
+	defer os.Remove(gbkOutputPath)
+	if featureCount, convertedCount := len(ReadGff("data/ecoli-mg1655.gff").Features), len(ReadGbk(gbkOutputPath).Features); featureCount != convertedCount {
+		t.Errorf("Convert() gff to gbk expected %d features. Got %d", featureCount, convertedCount)
+	}
+	if err := Convert("data/bsub.gbk", "data/test_convert.txt"); err == nil {
+		t.Errorf("Convert() should return an error for an unknown output extension")
+	}
+	if err := Convert("data/does_not_exist.gbk", gffOutputPath); err == nil {
+		t.Errorf("Convert() should return an error for a missing input file")
+	}
+}
+
+func TestGffGenbankQualifiers(t *testing.T) {
+	gffAttributes := map[string]string{"ID": "b0001", "Name": "thrL", "Parent": "gene0", "Note": "leader", "product": "thr operon leader peptide"}
+	genbankQualifiers, collisions := GffToGenbankQualifiers(gffAttributes)
+	expected := map[string]string{"locus_tag": "b0001", "gene": "thrL", "Parent": "gene0", "note": "leader", "product": "thr operon leader peptide"}
+	if diff := cmp.Diff(expected, genbankQualifiers); diff != "" || collisions != nil {
+		t.Errorf("GffToGenbankQualifiers() mismatch (-want +got):\n%s, collisions %v", diff, collisions)
+	}
+	if gffAttributesBack, _ := GenbankToGffQualifiers(genbankQualifiers); !cmp.Equal(gffAttributes, gffAttributesBack) {
+		t.Errorf("GenbankToGffQualifiers() did not invert GffToGenbankQualifiers (-want +got):\n%s", cmp.Diff(gffAttributes, gffAttributesBack))
+	}
+
+	conflicting, collisions := GffToGenbankQualifiers(map[string]string{"ID": "cds0", "locus_tag": "b0001"})
+	if diff := cmp.Diff(map[string]string{"ID": "cds0", "locus_tag": "b0001"}, conflicting); diff != "" {
+		t.Errorf("GffToGenbankQualifiers() should keep both values when they collide (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"ID"}, collisions); diff != "" {
+		t.Errorf("GffToGenbankQualifiers() should report the collision (-want +got):\n%s", diff)
+	}
+
+	annotatedSequence := convertQualifierNames(AnnotatedSequence{Features: []Feature{{
+		Type:               "CDS",
+		Attributes:         map[string]string{"locus_tag": "b0001", "db_xref": "GI:1", "gene": "thrL", "Name": "thrL"},
+		RepeatedQualifiers: map[string][]string{"db_xref": {"GI:2"}, "gene": {"thr"}},
+	}}}, "gbk", "gff")
+	feature := annotatedSequence.Features[0]
+	if diff := cmp.Diff(map[string]string{"ID": "b0001", "Dbxref": "GI:1", "gene": "thrL", "Name": "thrL"}, feature.Attributes); diff != "" {
+		t.Errorf("convertQualifierNames() attributes mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string][]string{"Dbxref": {"GI:2"}, "gene": {"thr"}}, feature.RepeatedQualifiers); diff != "" {
+		t.Errorf("convertQualifierNames() repeated qualifiers mismatch (-want +got):\n%s", diff)
+	}
+}
+
+/******************************************************************************
+
+Conversion related tests end here.
+
+******************************************************************************/
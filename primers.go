@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+)
+
+/******************************************************************************
+
+File is structured as so:
+
+	Secondary structure - hairpin and self dimer checks for primer QC.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Secondary structure related things begin here.
+
+******************************************************************************/
+
+// minHairpinLoop is the smallest number of unpaired bases a hairpin loop can physically have.
+const minHairpinLoop = 3
+
+// HasHairpin reports whether an oligo can fold back on itself into a stem of at least minStem base pairs.
+func HasHairpin(oligo string, minStem int) bool {
+	_, length := WorstHairpin(oligo)
+	return length > 0 && length >= minStem
+}
+
+// WorstHairpin finds the longest hairpin stem an oligo can form. It returns the 0-based position of the stem's 5' arm and the stem length. Length is 0 if no stem can form.
+func WorstHairpin(oligo string) (position, length int) {
+	bases := []byte(strings.ToUpper(oligo))
+	for fivePrimeIndex := range bases {
+		for threePrimeIndex := len(bases) - 1; threePrimeIndex > fivePrimeIndex; threePrimeIndex-- {
+			stemLength := 0
+			// extend the stem inwards as long as the bases pair and there's still room for a loop.
+			for threePrimeIndex-fivePrimeIndex-2*stemLength-1 >= minHairpinLoop &&
+				basesPair(bases[fivePrimeIndex+stemLength], bases[threePrimeIndex-stemLength]) {
+				stemLength++
+			}
+			if stemLength > length {
+				position, length = fivePrimeIndex, stemLength
+			}
+		}
+	}
+	return position, length
+}
+
+// SelfDimer reports whether two copies of an oligo can anneal to each other over at least minComplement contiguous bases.
+func SelfDimer(oligo string, minComplement int) bool {
+	_, length := WorstSelfDimer(oligo)
+	return length > 0 && length >= minComplement
+}
+
+// WorstSelfDimer finds the longest stretch of an oligo that is complementary to another copy of itself.
+// It returns the 0-based position of that stretch in the oligo and its length. Length is 0 if no bases pair.
+func WorstSelfDimer(oligo string) (position, length int) {
+	bases := []byte(strings.ToUpper(oligo))
+	reversedBases := make([]byte, len(bases))
+	for baseIndex, base := range bases {
+		reversedBases[len(bases)-1-baseIndex] = base
+	}
+
+	// longest stretch where the oligo pairs with a reversed copy of itself. Ambiguity codes, Ns, and gaps never pair.
+	previousRow := make([]int, len(reversedBases)+1)
+	for baseIndex := 1; baseIndex <= len(bases); baseIndex++ {
+		currentRow := make([]int, len(reversedBases)+1)
+		for reverseIndex := 1; reverseIndex <= len(reversedBases); reverseIndex++ {
+			if basesPair(bases[baseIndex-1], reversedBases[reverseIndex-1]) {
+				currentRow[reverseIndex] = previousRow[reverseIndex-1] + 1
+				if currentRow[reverseIndex] > length {
+					length = currentRow[reverseIndex]
+					position = baseIndex - length
+				}
+			}
+		}
+		previousRow = currentRow
+	}
+	return position, length
+}
+
+/******************************************************************************
+
+Secondary structure related things end here.
+
+******************************************************************************/
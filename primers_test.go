@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestHasHairpin(t *testing.T) {
+	// GCGCGC stem, AAAA loop, GCGCGC stem.
+	hairpinOligo := "TTGCGCGCAAAAGCGCGCTT"
+	if !HasHairpin(hairpinOligo, 6) {
+		t.Errorf("HasHairpin() did not detect the 6bp stem in %s", hairpinOligo)
+	}
+	position, length := WorstHairpin(hairpinOligo)
+	if position != 2 || length != 6 {
+		t.Errorf("WorstHairpin() returned position %d length %d, expected position 2 length 6", position, length)
+	}
+
+	if HasHairpin("AAAAAAAAAAAAAAAAAAAA", 3) {
+		t.Errorf("HasHairpin() found a hairpin in a homopolymer")
+	}
+
+	// stems that would need a loop shorter than 3 bases can't form.
+	if HasHairpin("GCGC", 2) {
+		t.Errorf("HasHairpin() found a hairpin without room for a loop")
+	}
+
+	// ambiguity codes only pair with themselves in a reverse complement, not in a stem.
+	if _, length := WorstHairpin("RRRRRTTTTTYYYYY"); length != 0 {
+		t.Errorf("WorstHairpin() paired ambiguity codes into a %dbp stem", length)
+	}
+}
+
+func TestSelfDimer(t *testing.T) {
+	// GAATTC is its own reverse complement.
+	dimerOligo := "AAAAGAATTCAAAA"
+	if !SelfDimer(dimerOligo, 6) {
+		t.Errorf("SelfDimer() did not detect the palindrome in %s", dimerOligo)
+	}
+	position, length := WorstSelfDimer(dimerOligo)
+	if position != 4 || length != 6 {
+		t.Errorf("WorstSelfDimer() returned position %d length %d, expected position 4 length 6", position, length)
+	}
+
+	if SelfDimer("AAAAAAAAAA", 1) {
+		t.Errorf("SelfDimer() found a dimer in a homopolymer")
+	}
+
+	if SelfDimer("RRRRRRYYYYYYNNNN", 1) {
+		t.Errorf("SelfDimer() paired ambiguity codes")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+/******************************************************************************
+
+File is structured as so:
+
+	Fastq quality - per read and per batch quality score summaries, and decoding reads into sequences.
+
+Quality strings are decoded by subtracting an ASCII offset from every
+character. Sanger and Illumina 1.8+ reads use an offset of 33, older Illumina
+reads use 64.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Fastq quality related things begin here.
+
+******************************************************************************/
+
+// FastqSummary holds quality and length statistics over a batch of reads.
+type FastqSummary struct {
+	ReadCount              int
+	MeanQuality            float64
+	MinQuality             int
+	MaxQuality             int
+	LengthDistribution     map[int]int // read length -> number of reads with that length.
+	PerPositionMeanQuality []float64   // mean quality of every base at a given 0-based read position.
+}
+
+// MeanQuality returns the mean phred quality score of a read. Returns 0 for reads without quality scores.
+func (read Read) MeanQuality(offset int) float64 {
+	if len(read.Quality) == 0 {
+		return 0
+	}
+	total := 0
+	for _, qualityCharacter := range []byte(read.Quality) {
+		total += int(qualityCharacter) - offset
+	}
+	return float64(total) / float64(len(read.Quality))
+}
+
+// DecodeQuality returns the phred quality score of every character of a fastq quality string.
+func DecodeQuality(quality string, offset int) []int {
+	if quality == "" {
+		return nil
+	}
+	scores := make([]int, len(quality))
+	for position, qualityCharacter := range []byte(quality) {
+		scores[position] = int(qualityCharacter) - offset
+	}
+	return scores
+}
+
+// AsSequence returns a read as a Sequence with its quality decoded into Quality, so quality is carried through
+// AnnotatedSequences built from reads, like a Sanger trace or consensus.
+func (read Read) AsSequence(offset int) Sequence {
+	return Sequence{Description: read.Identifier, Sequence: read.Sequence, Quality: DecodeQuality(read.Quality, offset)}
+}
+
+// FastqStats summarizes the quality scores and lengths of a batch of reads.
+func FastqStats(reads []Read, offset int) FastqSummary {
+	summary := FastqSummary{}
+	summary.ReadCount = len(reads)
+	summary.LengthDistribution = make(map[int]int)
+
+	var qualityTotal, qualityCount int
+	var positionTotals, positionCounts []int
+	for _, read := range reads {
+		summary.LengthDistribution[len(read.Sequence)]++
+		for position, qualityCharacter := range []byte(read.Quality) {
+			quality := int(qualityCharacter) - offset
+			if qualityCount == 0 || quality < summary.MinQuality {
+				summary.MinQuality = quality
+			}
+			if qualityCount == 0 || quality > summary.MaxQuality {
+				summary.MaxQuality = quality
+			}
+			qualityTotal += quality
+			qualityCount++
+
+			// grow per position tallies to fit the longest read seen so far.
+			if position == len(positionTotals) {
+				positionTotals = append(positionTotals, 0)
+				positionCounts = append(positionCounts, 0)
+			}
+			positionTotals[position] += quality
+			positionCounts[position]++
+		}
+	}
+
+	if qualityCount > 0 {
+		summary.MeanQuality = float64(qualityTotal) / float64(qualityCount)
+	}
+	summary.PerPositionMeanQuality = make([]float64, len(positionTotals))
+	for position := range positionTotals {
+		summary.PerPositionMeanQuality[position] = float64(positionTotals[position]) / float64(positionCounts[position])
+	}
+	return summary
+}
+
+/******************************************************************************
+
+Fastq quality related things end here.
+
+******************************************************************************/
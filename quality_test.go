@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFastqStats(t *testing.T) {
+	// with an offset of 33 '!' is quality 0, '+' is quality 10, and '5' is quality 20.
+	fastq := "@read1\nACGT\n+\n!!55\n@read2\nACG\n+\n+++\n"
+	reads, err := ParseFastq(fastq)
+	if err != nil || len(reads) != 2 {
+		t.Fatalf("ParseFastq() expected 2 reads. Got %d, %v", len(reads), err)
+	}
+	if reads[0].Identifier != "read1" {
+		t.Errorf("ParseFastq() expected identifier read1. Got %q", reads[0].Identifier)
+	}
+	strict, err := ParseFastq("@empty\n\n+\n\n@read3\nAC\n+\n@@\n@last\n\n+\n\n")
+	expectedReads := []Read{{Identifier: "empty"}, {Identifier: "read3", Sequence: "AC", Quality: "@@"}, {Identifier: "last"}}
+	if diff := cmp.Diff(expectedReads, strict); err != nil || diff != "" {
+		t.Errorf("ParseFastq() should read records as exactly four lines, %v (-want +got):\n%s", err, diff)
+	}
+	for _, malformed := range []string{"@read1\nACGT\n+\n", "read1\nACGT\n+\n!!55\n", "@read1\nACGT\n+\n!!5\n", "@read1\nACGT\n+\n!!55\n\n"} {
+		if malformedReads, err := ParseFastq(malformed); err == nil {
+			t.Errorf("ParseFastq() expected an error for %q. Got %v", malformed, malformedReads)
+		}
+	}
+
+	if meanQuality := reads[0].MeanQuality(33); meanQuality != 10 {
+		t.Errorf("MeanQuality() expected 10. Got %f", meanQuality)
+	}
+
+	summary := FastqStats(reads, 33)
+	expected := FastqSummary{
+		ReadCount:              2,
+		MeanQuality:            70.0 / 7.0,
+		MinQuality:             0,
+		MaxQuality:             20,
+		LengthDistribution:     map[int]int{4: 1, 3: 1},
+		PerPositionMeanQuality: []float64{5, 5, 15, 20},
+	}
+	if diff := cmp.Diff(expected, summary); diff != "" {
+		t.Errorf("FastqStats() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,747 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/******************************************************************************
+
+File is structured as so:
+
+	Complement - base complement map, including IUPAC codes, and reverse complement helpers.
+	Codon tables - NCBI genetic codes.
+	Translation - nucleotide to protein sequence.
+	Back translation - protein to degenerate nucleotide sequence.
+	Variants - applying small edits to an AnnotatedSequence.
+	Trimming - removing Ns from the ends of a sequence.
+	Clamping - fixing features that fall outside the bounds of a sequence.
+	Renaming - changing the name of a record everywhere it's written out.
+	Linearizing - splitting features that cross the origin of a circular sequence.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Complement related things begin here.
+
+******************************************************************************/
+
+// complementBaseRuneMap maps each nucleotide to the nucleotide it pairs with. Only A, C, G, and T are listed since
+// primer and hairpin checks use it to decide which bases pair.
+var complementBaseRuneMap = map[rune]rune{
+	'A': 'T',
+	'T': 'A',
+	'G': 'C',
+	'C': 'G',
+	'a': 't',
+	't': 'a',
+	'g': 'c',
+	'c': 'g',
+}
+
+// ambiguityComplementRuneMap maps each IUPAC ambiguity code to the code of the bases that complement it, like R (A or
+// G) to Y (C or T). S, W, and N are their own complements so they aren't listed. Only reverse complementing uses it,
+// since an ambiguity code doesn't actually pair with anything.
+var ambiguityComplementRuneMap = map[rune]rune{
+	'R': 'Y',
+	'Y': 'R',
+	'K': 'M',
+	'M': 'K',
+	'B': 'V',
+	'V': 'B',
+	'D': 'H',
+	'H': 'D',
+	'r': 'y',
+	'y': 'r',
+	'k': 'm',
+	'm': 'k',
+	'b': 'v',
+	'v': 'b',
+	'd': 'h',
+	'h': 'd',
+}
+
+// complementBase returns the complement of a single base. Characters without a complement are returned unchanged.
+func complementBase(base rune) rune {
+	if complement, ok := complementBaseRuneMap[base]; ok {
+		return complement
+	}
+	return base
+}
+
+// returns the complement of a single base or IUPAC ambiguity code. Characters without a complement are returned
+// unchanged.
+func complementAmbiguousBase(base rune) rune {
+	if complement, ok := ambiguityComplementRuneMap[base]; ok {
+		return complement
+	}
+	return complementBase(base)
+}
+
+// ReverseComplement returns the reverse complement of a nucleotide string. Case is kept, IUPAC ambiguity codes are
+// complemented to the codes they pair with, like R to Y, and anything else, like gaps, is passed through unchanged.
+func ReverseComplement(sequence string) string {
+	var reverseComplementBuilder strings.Builder
+	reverseComplementBuilder.Grow(len(sequence))
+	runes := []rune(sequence)
+	for runeIndex := len(runes) - 1; runeIndex >= 0; runeIndex-- {
+		reverseComplementBuilder.WriteRune(complementAmbiguousBase(runes[runeIndex]))
+	}
+	return reverseComplementBuilder.String()
+}
+
+// ReverseComplement returns a copy of a Sequence holding the reverse complement of its bases, with its quality scores
+// reversed to match. The Seguid checksum is cleared since it no longer matches.
+func (sequence Sequence) ReverseComplement() Sequence {
+	reversed := sequence
+	reversed.Sequence = ReverseComplement(sequence.Sequence)
+	if sequence.Quality != nil {
+		reversed.Quality = make([]int, len(sequence.Quality))
+		for qualityIndex, score := range sequence.Quality {
+			reversed.Quality[len(sequence.Quality)-1-qualityIndex] = score
+		}
+	}
+	reversed.Seguid = ""
+	return reversed
+}
+
+/******************************************************************************
+
+Complement related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Codon table related things begin here.
+
+Genetic codes are stored in the same compact format NCBI uses to publish them
+at https://www.ncbi.nlm.nih.gov/Taxonomy/Utils/wprintgc.cgi. Each of the 64
+positions across the base strings spells out a codon, and the same position
+in a table's amino acid string and start string gives its translation and
+whether it can act as a start codon.
+
+******************************************************************************/
+
+const (
+	ncbiBase1 = "TTTTTTTTTTTTTTTTCCCCCCCCCCCCCCCCAAAAAAAAAAAAAAAAGGGGGGGGGGGGGGGG"
+	ncbiBase2 = "TTTTCCCCAAAAGGGGTTTTCCCCAAAAGGGGTTTTCCCCAAAAGGGGTTTTCCCCAAAAGGGG"
+	ncbiBase3 = "TCAGTCAGTCAGTCAGTCAGTCAGTCAGTCAGTCAGTCAGTCAGTCAGTCAGTCAGTCAGTCAG"
+)
+
+// amino acid and start strings for each NCBI genetic code, keyed by the NCBI table number.
+var ncbiCodonTableStrings = map[int][2]string{
+	1:  {"FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", "---M------**--*----M---------------M----------------------------"}, // standard
+	2:  {"FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIMMTTTTNNKKSS**VVVVAAAADDEEGGGG", "----------**--------------------MMMM----------**---M------------"}, // vertebrate mitochondrial
+	3:  {"FFLLSSSSYY**CCWWTTTTPPPPHHQQRRRRIIMMTTTTNNKKSSRRVVVVAAAADDEEGGGG", "----------**----------------------MM---------------M------------"}, // yeast mitochondrial
+	4:  {"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", "--MM------**-------M------------MMMM---------------M------------"}, // mold, protozoan, and coelenterate mitochondrial and mycoplasma/spiroplasma
+	5:  {"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSSSSVVVVAAAADDEEGGGG", "---M------**--------------------MMMM---------------M------------"}, // invertebrate mitochondrial
+	6:  {"FFLLSSSSYYQQCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", "--------------*--------------------M----------------------------"}, // ciliate, dasycladacean and hexamita nuclear
+	9:  {"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNNKSSSSVVVVAAAADDEEGGGG", "----------**-----------------------M---------------M------------"}, // echinoderm and flatworm mitochondrial
+	10: {"FFLLSSSSYY**CCCWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", "----------**-----------------------M----------------------------"}, // euplotid nuclear
+	11: {"FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", "---M------**--*----M------------MMMM---------------M------------"}, // bacterial, archaeal and plant plastid
+	12: {"FFLLSSSSYY**CC*WLLLSPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", "----------**--*----M---------------M----------------------------"}, // alternative yeast nuclear
+	13: {"FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSSGGVVVVAAAADDEEGGGG", "---M------**----------------------MM---------------M------------"}, // ascidian mitochondrial
+	14: {"FFLLSSSSYYY*CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNNKSSSSVVVVAAAADDEEGGGG", "-----------*-----------------------M----------------------------"}, // alternative flatworm mitochondrial
+	16: {"FFLLSSSSYY*LCC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG", "----------*---*--------------------M----------------------------"}, // chlorophycean mitochondrial
+}
+
+// codonTable holds a single genetic code.
+type codonTable struct {
+	Translations map[string]rune // codon -> one letter amino acid. Stop codons translate to '*'.
+	StartCodons  map[string]bool
+}
+
+// built once from ncbiCodonTableStrings.
+var codonTables = buildCodonTables()
+
+func buildCodonTables() map[int]codonTable {
+	tables := make(map[int]codonTable)
+	for tableNumber, tableStrings := range ncbiCodonTableStrings {
+		aminoAcids, starts := tableStrings[0], tableStrings[1]
+		table := codonTable{Translations: make(map[string]rune), StartCodons: make(map[string]bool)}
+		for codonIndex := range ncbiBase1 {
+			codon := string([]byte{ncbiBase1[codonIndex], ncbiBase2[codonIndex], ncbiBase3[codonIndex]})
+			table.Translations[codon] = rune(aminoAcids[codonIndex])
+			if starts[codonIndex] == 'M' {
+				table.StartCodons[codon] = true
+			}
+		}
+		tables[tableNumber] = table
+	}
+	return tables
+}
+
+// getCodonTable returns the genetic code for an NCBI translation table number.
+func getCodonTable(table int) (codonTable, error) {
+	geneticCode, ok := codonTables[table]
+	if !ok {
+		return geneticCode, fmt.Errorf("unknown NCBI translation table %d", table)
+	}
+	return geneticCode, nil
+}
+
+/******************************************************************************
+
+Codon table related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Translation related things begin here.
+
+******************************************************************************/
+
+// Translate turns a nucleotide sequence into a protein sequence using the given NCBI translation table. Translation starts
+// at the first base and any trailing bases that don't make up a whole codon are ignored. Stop codons become '*' and codons
+// containing ambiguous bases become 'X'. RNA is translated as if it were DNA.
+func Translate(sequence string, table int) (string, error) {
+	return translate(sequence, table, false)
+}
+
+// TranslateCDS translates a complete coding sequence like Translate, except that the first codon is translated as M if it
+// is a start codon in the table. Ribosomes begin every protein with methionine, so alternative starts like GTG and TTG in
+// bacterial table 11 code for M rather than the V and L they code for elsewhere in a gene.
+func TranslateCDS(sequence string, table int) (string, error) {
+	return translate(sequence, table, true)
+}
+
+func translate(sequence string, table int, firstCodonIsStart bool) (string, error) {
+	geneticCode, err := getCodonTable(table)
+	if err != nil {
+		return "", err
+	}
+
+	sequence = strings.Replace(strings.ToUpper(sequence), "U", "T", -1)
+	var proteinBuilder strings.Builder
+	proteinBuilder.Grow(len(sequence) / 3)
+	for codonStart := 0; codonStart+3 <= len(sequence); codonStart += 3 {
+		codon := sequence[codonStart : codonStart+3]
+		aminoAcid, ok := geneticCode.Translations[codon]
+		if !ok {
+			aminoAcid = 'X'
+		}
+		if codonStart == 0 && firstCodonIsStart && geneticCode.StartCodons[codon] {
+			aminoAcid = 'M'
+		}
+		proteinBuilder.WriteRune(aminoAcid)
+	}
+	return proteinBuilder.String(), nil
+}
+
+/******************************************************************************
+
+Translation related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Back translation related things begin here.
+
+******************************************************************************/
+
+// IUPAC nucleotide codes indexed by a bitmask of the bases they stand for (A=1, C=2, G=4, T=8).
+const iupacCodesByBaseMask = "-ACMGRSVTWYHKDBN"
+
+// returns the bitmask of a single unambiguous base for use with iupacCodesByBaseMask.
+func baseMask(base byte) int {
+	return strings.IndexByte(iupacCodesByBaseMask, base)
+}
+
+// BackTranslate turns a protein sequence into the shortest degenerate nucleotide sequence that covers every codon for each
+// amino acid in the given NCBI translation table. Each amino acid becomes a single codon written with IUPAC ambiguity codes,
+// so amino acids with codons that differ at more than one position (like serine) will also cover some codons for other amino
+// acids. Use '*' for stop codons. Unknown amino acids (like X) become NNN.
+func BackTranslate(protein string, table int) (string, error) {
+	geneticCode, err := getCodonTable(table)
+	if err != nil {
+		return "", err
+	}
+
+	// merge the bases used at each codon position by every codon of an amino acid.
+	degenerateCodonMasks := make(map[rune][3]int)
+	for codon, aminoAcid := range geneticCode.Translations {
+		codonMask := degenerateCodonMasks[aminoAcid]
+		for position := 0; position < 3; position++ {
+			codonMask[position] |= baseMask(codon[position])
+		}
+		degenerateCodonMasks[aminoAcid] = codonMask
+	}
+
+	var sequenceBuilder strings.Builder
+	sequenceBuilder.Grow(len(protein) * 3)
+	for _, aminoAcid := range strings.ToUpper(protein) {
+		codonMask, ok := degenerateCodonMasks[aminoAcid]
+		if !ok {
+			sequenceBuilder.WriteString("NNN")
+			continue
+		}
+		for _, positionMask := range codonMask {
+			sequenceBuilder.WriteByte(iupacCodesByBaseMask[positionMask])
+		}
+	}
+	return sequenceBuilder.String(), nil
+}
+
+/******************************************************************************
+
+Back translation related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Variant related things begin here.
+
+******************************************************************************/
+
+// Variant is a simple edit to a sequence in the style of a VCF record. The Ref bases starting at the 1-based Position are
+// replaced with the Alt bases, so substitutions, insertions, and deletions can all be described.
+type Variant struct {
+	Position int
+	Ref      string
+	Alt      string
+	// Haplotype is the 1-based copy of the sequence a phased variant is on, like the 1 of a 1|0 VCF genotype. 0 means
+	// the variant is on every copy, as it is for homozygous or unphased variants. Only ApplyPhasedVariants looks at it.
+	Haplotype int
+}
+
+// ApplyPhasedVariants returns one copy of an AnnotatedSequence for each of ploidy haplotypes, 2 for a diploid genome,
+// with the variants on that haplotype applied to it by ApplyVariants. Variants with Haplotype 0 are applied to every
+// copy. Variants on different haplotypes may overlap, like the two alleles of a heterozygous site. Returns an error if a
+// variant's Haplotype is greater than ploidy or if ApplyVariants fails for any haplotype.
+func ApplyPhasedVariants(annotatedSequence AnnotatedSequence, variants []Variant, ploidy int) ([]AnnotatedSequence, error) {
+	if ploidy < 1 {
+		return nil, fmt.Errorf("ploidy must be at least 1. Got %d", ploidy)
+	}
+	haplotypeVariants := make([][]Variant, ploidy)
+	for _, variant := range variants {
+		if variant.Haplotype < 0 || variant.Haplotype > ploidy {
+			return nil, fmt.Errorf("variant at position %d is on haplotype %d of a sequence with ploidy %d", variant.Position, variant.Haplotype, ploidy)
+		}
+		for haplotypeIndex := range haplotypeVariants {
+			if variant.Haplotype == 0 || variant.Haplotype == haplotypeIndex+1 {
+				haplotypeVariants[haplotypeIndex] = append(haplotypeVariants[haplotypeIndex], variant)
+			}
+		}
+	}
+
+	haplotypes := make([]AnnotatedSequence, ploidy)
+	for haplotypeIndex, variants := range haplotypeVariants {
+		haplotype, err := ApplyVariants(annotatedSequence, variants)
+		if err != nil {
+			return nil, fmt.Errorf("could not build haplotype %d: %w", haplotypeIndex+1, err)
+		}
+		haplotypes[haplotypeIndex] = haplotype
+	}
+	return haplotypes, nil
+}
+
+// ApplyVariants returns a copy of an AnnotatedSequence with every variant applied to its sequence, whatever its
+// Haplotype. Feature coordinates after an insertion or deletion are shifted so they still cover the same bases, and
+// features that end inside a deleted region are trimmed to it. Features that no variant moves keep their Location as it
+// was written, as do features whose location is entirely on other records since there's nothing on this sequence to
+// shift. Quality scores are dropped since the quality of the new bases isn't known. Returns an error if a variant's Ref
+// doesn't match the sequence, two variants overlap, or a feature's location can't be parsed.
+func ApplyVariants(annotatedSequence AnnotatedSequence, variants []Variant) (AnnotatedSequence, error) {
+	sortedVariants := make([]Variant, len(variants))
+	copy(sortedVariants, variants)
+	sort.SliceStable(sortedVariants, func(i, j int) bool { return sortedVariants[i].Position < sortedVariants[j].Position })
+
+	sequence := annotatedSequence.Sequence.Sequence
+	var sequenceBuilder strings.Builder
+	previousEnd := 0 // 0-based end of the last variant's Ref, exclusive.
+	for _, variant := range sortedVariants {
+		refStart := variant.Position - 1
+		refEnd := refStart + len(variant.Ref)
+		if refStart < previousEnd {
+			return AnnotatedSequence{}, fmt.Errorf("variant at position %d overlaps the variant before it", variant.Position)
+		}
+		if refStart < 0 || refEnd > len(sequence) {
+			return AnnotatedSequence{}, fmt.Errorf("variant at position %d is outside of a sequence of length %d", variant.Position, len(sequence))
+		}
+		if !strings.EqualFold(sequence[refStart:refEnd], variant.Ref) {
+			return AnnotatedSequence{}, fmt.Errorf("variant at position %d expected ref %s but the sequence has %s", variant.Position, variant.Ref, sequence[refStart:refEnd])
+		}
+		sequenceBuilder.WriteString(sequence[previousEnd:refStart])
+		sequenceBuilder.WriteString(variant.Alt)
+		previousEnd = refEnd
+	}
+	sequenceBuilder.WriteString(sequence[previousEnd:])
+
+	features := make([]Feature, len(annotatedSequence.Features))
+	for featureIndex, feature := range annotatedSequence.Features {
+		features[featureIndex] = feature
+		if isRemoteLocation(feature.Location) {
+			continue
+		}
+		var featureLocation Location
+		if feature.Location != "" {
+			var err error
+			featureLocation, err = parseLocation(feature.Location)
+			if err != nil {
+				return AnnotatedSequence{}, fmt.Errorf("could not shift %s: %w", describeFeature(feature), err)
+			}
+		}
+		shifted := false
+		shift := func(coordinate int) int {
+			shiftedCoordinate := coordinate
+			// going backwards means each shift only moves coordinates that no earlier variant has looked at yet.
+			for variantIndex := len(sortedVariants) - 1; variantIndex >= 0; variantIndex-- {
+				shiftedCoordinate = shiftCoordinate(shiftedCoordinate, sortedVariants[variantIndex])
+			}
+			shifted = shifted || shiftedCoordinate != coordinate
+			return shiftedCoordinate
+		}
+		if feature.Location != "" {
+			// only features that moved are rewritten so the rest keep their location exactly as it was written.
+			if featureLocation = featureLocation.mapCoordinates(shift); shifted {
+				feature.setLocation(featureLocation)
+			}
+		} else {
+			feature.Start, feature.End = shift(feature.Start), shift(feature.End)
+		}
+		features[featureIndex] = feature
+	}
+
+	annotatedSequence.Features = features
+	annotatedSequence.Sequence.Sequence = sequenceBuilder.String()
+	annotatedSequence.Sequence.Seguid = ""
+	annotatedSequence.Sequence.Quality = nil
+	if annotatedSequence.Meta.Locus.SequenceLength != "" {
+		annotatedSequence.Meta.Locus.SequenceLength = strconv.Itoa(len(annotatedSequence.Sequence.Sequence)) + " bp"
+	}
+	return annotatedSequence, nil
+}
+
+// moves a 1-based coordinate to where its base ends up after a variant is applied. Coordinates inside a Ref that is
+// longer than its Alt are pulled back to the last base of the Alt.
+func shiftCoordinate(coordinate int, variant Variant) int {
+	if coordinate < variant.Position || coordinate == UndefinedCoordinate {
+		return coordinate
+	}
+	refEnd := variant.Position + len(variant.Ref) - 1
+	if coordinate > refEnd {
+		return coordinate + len(variant.Alt) - len(variant.Ref)
+	}
+	return variant.Position + minInt(coordinate-variant.Position, maxInt(len(variant.Alt)-1, 0))
+}
+
+// returns a copy of a location with every coordinate, including those of its segments, passed through mapping.
+func (featureLocation Location) mapCoordinates(mapping func(coordinate int) int) Location {
+	featureLocation.Start = mapping(featureLocation.Start)
+	featureLocation.End = mapping(featureLocation.End)
+	if featureLocation.SubLocations != nil {
+		subLocations := make([]Location, len(featureLocation.SubLocations))
+		for subLocationIndex, subLocation := range featureLocation.SubLocations {
+			subLocations[subLocationIndex] = subLocation.mapCoordinates(mapping)
+		}
+		featureLocation.SubLocations = subLocations
+	}
+	return featureLocation
+}
+
+/******************************************************************************
+
+Variant related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Trimming related things begin here.
+
+******************************************************************************/
+
+// TrimNs removes runs of N or n from both ends of a sequence, as is usual when cleaning up assembled contigs, and returns
+// the trimmed sequence along with how many bases were removed from the start (leading) and end (trailing).
+func TrimNs(sequence string) (trimmed string, leading, trailing int) {
+	trimmed = strings.TrimLeft(sequence, "Nn")
+	leading = len(sequence) - len(trimmed)
+	untrimmedLength := len(trimmed)
+	trimmed = strings.TrimRight(trimmed, "Nn")
+	trailing = untrimmedLength - len(trimmed)
+	return trimmed, leading, trailing
+}
+
+// TrimNs returns a copy of an AnnotatedSequence with runs of N trimmed from both ends of its sequence, along with the
+// features that were removed because they lay entirely within the trimmed Ns. Feature coordinates are shifted back by the
+// number of leading Ns removed, and features cut short by the trim are marked partial at the cut ends, the same way
+// ExtractWithContext does. Quality scores are trimmed along with their bases, and dropped if there isn't one per base.
+func (annotatedSequence AnnotatedSequence) TrimNs() (AnnotatedSequence, []Feature, error) {
+	trimmed, leading, _ := TrimNs(annotatedSequence.Sequence.Sequence)
+	windowStart, windowEnd := leading+1, leading+len(trimmed)
+
+	var features, dropped []Feature
+	for featureIndex, feature := range annotatedSequence.Features {
+		if feature.Location == "" && (feature.Start < 1 || feature.End < 1) {
+			features = append(features, feature) // unset or undefined coordinates have nothing to trim.
+			continue
+		}
+		featureLocation, err := feature.location()
+		if err != nil {
+			return AnnotatedSequence{}, nil, fmt.Errorf("could not trim %s feature %d: %w", feature.Type, featureIndex, err)
+		}
+		windowedLocation, ok := featureLocation.window(windowStart, windowEnd)
+		if !ok {
+			dropped = append(dropped, feature)
+			continue
+		}
+		if feature.Location != "" {
+			feature.setLocation(windowedLocation)
+		} else {
+			feature.Start, feature.End = windowedLocation.Start, windowedLocation.End
+		}
+		features = append(features, feature)
+	}
+
+	annotatedSequence.Features = features
+	if len(annotatedSequence.Sequence.Quality) == len(annotatedSequence.Sequence.Sequence) {
+		annotatedSequence.Sequence.Quality = annotatedSequence.Sequence.Quality[leading : leading+len(trimmed)]
+	} else {
+		annotatedSequence.Sequence.Quality = nil
+	}
+	annotatedSequence.Sequence.Sequence = trimmed
+	annotatedSequence.Sequence.Seguid = ""
+	if annotatedSequence.Meta.Locus.SequenceLength != "" {
+		annotatedSequence.Meta.Locus.SequenceLength = strconv.Itoa(len(trimmed)) + " bp"
+	}
+	return annotatedSequence, dropped, nil
+}
+
+/******************************************************************************
+
+Trimming related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Clamping related things begin here.
+
+******************************************************************************/
+
+// ClampFeatures fixes features with coordinates outside the bounds of the sequence, which upstream tools sometimes
+// write. The bounds are Meta.RegionStart (or 1) to Meta.RegionEnd, narrowed to the coordinates the sequence covers,
+// which for a gff of a region of a larger contig start after the region's start the same way FeatureSequence offsets
+// them. There's no upper bound if there's neither a RegionEnd nor a sequence. If drop is true features that stick out
+// of the bounds are removed, otherwise their coordinates are clamped to the bounds and only features lying entirely
+// outside them are removed. The removed features are returned alongside the cleaned AnnotatedSequence. Features whose
+// location can't be parsed are kept.
+func (annotatedSequence AnnotatedSequence) ClampFeatures(drop bool) (AnnotatedSequence, []Feature) {
+	lower := maxInt(annotatedSequence.Meta.RegionStart, 1)
+	upper := annotatedSequence.Meta.RegionEnd
+	if sequenceLength := len(annotatedSequence.Sequence.Sequence); sequenceLength > 0 {
+		offset := annotatedSequence.regionOffset()
+		lower = maxInt(lower, offset+1)
+		if upper == 0 || upper > offset+sequenceLength {
+			upper = offset + sequenceLength
+		}
+	}
+	if upper == 0 {
+		upper = math.MaxInt32
+	}
+	clampCoordinate := func(coordinate int) int {
+		if coordinate < 1 {
+			return coordinate // unset or undefined.
+		}
+		return maxInt(lower, minInt(coordinate, upper))
+	}
+
+	var features, dropped []Feature
+	for _, feature := range annotatedSequence.Features {
+		featureLocation, err := feature.location()
+		if err != nil {
+			features = append(features, feature)
+			continue
+		}
+		segments := featureLocation.segments()
+		outside, allOutside := false, true
+		for _, segment := range segments {
+			outside = outside || segment[0] < lower || segment[1] > upper
+			allOutside = allOutside && (segment[1] < lower || segment[0] > upper)
+		}
+		if !outside {
+			features = append(features, feature)
+			continue
+		}
+		if drop || allOutside {
+			dropped = append(dropped, feature)
+			continue
+		}
+
+		if feature.Location != "" {
+			feature.setLocation(featureLocation.mapCoordinates(clampCoordinate))
+		} else {
+			feature.Start = clampCoordinate(feature.Start)
+			feature.End = clampCoordinate(feature.End)
+		}
+		features = append(features, feature)
+	}
+	annotatedSequence.Features = features
+	return annotatedSequence, dropped
+}
+
+/******************************************************************************
+
+Clamping related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Renaming related things begin here.
+
+******************************************************************************/
+
+// Rename sets the name of a record everywhere writers look for it: Meta.Name, Meta.Locus.Name, the seqid (Name) of every
+// feature that was on the old name, and the first word of the sequence's fasta Description. Features on other sequences
+// are left alone. Meta.Accession is an identifier rather than a name, so it isn't changed.
+func (annotatedSequence *AnnotatedSequence) Rename(newName string) {
+	oldNames := map[string]bool{"": true}
+	for _, oldName := range []string{annotatedSequence.Meta.Name, annotatedSequence.Meta.Locus.Name} {
+		if oldName != "" {
+			oldNames[oldName] = true
+		}
+	}
+
+	annotatedSequence.Meta.Name = newName
+	annotatedSequence.Meta.Locus.Name = newName
+	for featureIndex := range annotatedSequence.Features {
+		if oldNames[annotatedSequence.Features[featureIndex].Name] {
+			annotatedSequence.Features[featureIndex].Name = newName
+		}
+	}
+
+	description := strings.TrimPrefix(annotatedSequence.Sequence.Description, ">")
+	descriptionFields := strings.SplitN(description, " ", 2)
+	if description != "" && oldNames[descriptionFields[0]] {
+		descriptionFields[0] = newName
+		annotatedSequence.Sequence.Description = ">" + strings.Join(descriptionFields, " ")
+	}
+}
+
+/******************************************************************************
+
+Renaming related things end here.
+
+******************************************************************************/
+
+/******************************************************************************
+
+Linearizing related things begin here.
+
+******************************************************************************/
+
+// LinearizeFeatures returns the features of a circular sequence with every feature that crosses the origin, like
+// join(4500..5028,1..200), split into two linear pieces that can be written as single intervals to formats like gff or
+// bed. Both pieces share the feature's ID, which is set from its locus_tag or protein_id if it doesn't have one, and
+// pieces of features with none of these are left without an ID. A feature crosses the origin if its joined segments
+// jump back towards the start of the sequence, or for single spans if it ends past the end of the sequence or, for gff
+// features, its End is before its Start. Linear sequences, features that don't cross the origin, and gff features with
+// an undefined Start or End are returned unchanged.
+func (annotatedSequence AnnotatedSequence) LinearizeFeatures() []Feature {
+	sequenceLength := len(annotatedSequence.Sequence.Sequence)
+	features := make([]Feature, 0, len(annotatedSequence.Features))
+	for _, feature := range annotatedSequence.Features {
+		if !annotatedSequence.Meta.Locus.Circular {
+			features = append(features, feature)
+			continue
+		}
+		features = append(features, splitAtOrigin(feature, sequenceLength)...)
+	}
+	return features
+}
+
+// splits a feature that crosses the origin of a circular sequence into its pieces on either side of the origin, or returns
+// it alone if it doesn't cross.
+func splitAtOrigin(feature Feature, sequenceLength int) []Feature {
+	var firstPiece, secondPiece Feature
+	firstPiece, secondPiece = feature, feature
+
+	if feature.Location == "" {
+		switch {
+		case feature.Start == UndefinedCoordinate || feature.End == UndefinedCoordinate:
+			return []Feature{feature}
+		case feature.Start > feature.End:
+			firstPiece.End = sequenceLength
+			secondPiece.Start = 1
+		case sequenceLength > 0 && feature.End > sequenceLength:
+			firstPiece.End = sequenceLength
+			secondPiece.Start, secondPiece.End = 1, feature.End-sequenceLength
+		default:
+			return []Feature{feature}
+		}
+	} else {
+		featureLocation, err := parseLocation(feature.Location)
+		if err != nil {
+			return []Feature{feature}
+		}
+		firstLocation, secondLocation, crosses := splitLocationAtOrigin(featureLocation, sequenceLength)
+		if !crosses {
+			return []Feature{feature}
+		}
+		firstPiece.setLocation(firstLocation)
+		secondPiece.setLocation(secondLocation)
+	}
+
+	firstPiece, secondPiece = firstPiece.clone(), secondPiece.clone()
+	for _, identifierKey := range []string{"ID", "locus_tag", "protein_id"} {
+		if identifier := feature.Attributes[identifierKey]; identifier != "" {
+			firstPiece.Attributes["ID"], secondPiece.Attributes["ID"] = identifier, identifier
+			break
+		}
+	}
+	return []Feature{firstPiece, secondPiece}
+}
+
+// splits a genbank location at the origin, returning the part written before the origin, the part written after it, and
+// whether it crossed the origin at all.
+func splitLocationAtOrigin(featureLocation Location, sequenceLength int) (Location, Location, bool) {
+	if !featureLocation.Join {
+		if sequenceLength == 0 || featureLocation.End <= sequenceLength {
+			return featureLocation, Location{}, false
+		}
+		firstLocation, secondLocation := featureLocation, featureLocation
+		firstLocation.End, firstLocation.ThreePrimePartial = sequenceLength, false
+		secondLocation.Start, secondLocation.End, secondLocation.FivePrimePartial = 1, featureLocation.End-sequenceLength, false
+		return firstLocation, secondLocation, true
+	}
+
+	// joins of complemented segments like join(complement(1..200),complement(4500..5028)) are written from the highest
+	// coordinate down, so they cross the origin where the next segment starts higher rather than lower.
+	descending := featureLocation.isMinusStrand() && !featureLocation.Complement
+	subLocations := featureLocation.SubLocations
+	for index := 1; index < len(subLocations); index++ {
+		jumpsBack := subLocations[index].Start < subLocations[index-1].Start
+		if descending {
+			jumpsBack = subLocations[index].Start > subLocations[index-1].Start
+		}
+		if jumpsBack {
+			return joinSubLocations(featureLocation, subLocations[:index]), joinSubLocations(featureLocation, subLocations[index:]), true
+		}
+	}
+	return featureLocation, Location{}, false
+}
+
+// returns a location covering some of the segments of a joined location, with the joined location's strand.
+func joinSubLocations(joinedLocation Location, subLocations []Location) Location {
+	if len(subLocations) == 1 {
+		subLocation := subLocations[0]
+		subLocation.Complement = subLocation.Complement != joinedLocation.Complement
+		return subLocation
+	}
+	return Location{Complement: joinedLocation.Complement, Join: true, SubLocations: subLocations}
+}
+
+/******************************************************************************
+
+Linearizing related things end here.
+
+******************************************************************************/
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReverseComplement(t *testing.T) {
+	if reverseComplement := ReverseComplement("ATGCatgcRYKMBVDHSWN-rykmbvdhswn"); reverseComplement != "nwsdhbvkmry-NWSDHBVKMRYgcatGCAT" {
+		t.Errorf("ReverseComplement() mismatch. Got %s", reverseComplement)
+	}
+
+	sequence := Sequence{Description: "test", Sequence: "AACG", Quality: []int{10, 20, 30, 40}, Seguid: Seguid("AACG")}
+	expected := Sequence{Description: "test", Sequence: "CGTT", Quality: []int{40, 30, 20, 10}}
+	if diff := cmp.Diff(expected, sequence.ReverseComplement()); diff != "" {
+		t.Errorf("Sequence.ReverseComplement() mismatch (-want +got):\n%s", diff)
+	}
+	if sequence.Sequence != "AACG" || sequence.Quality[0] != 10 {
+		t.Errorf("Sequence.ReverseComplement() should not change the original sequence. Got %+v", sequence)
+	}
+}
+
+func TestCodonTables(t *testing.T) {
+	for tableNumber, tableStrings := range ncbiCodonTableStrings {
+		if len(tableStrings[0]) != 64 || len(tableStrings[1]) != 64 {
+			t.Errorf("codon table %d does not have 64 codons", tableNumber)
+		}
+	}
+
+	bacterialTable, err := getCodonTable(11)
+	if err != nil {
+		t.Fatalf("getCodonTable() returned an unexpected error: %s", err)
+	}
+	if bacterialTable.Translations["ATG"] != 'M' || bacterialTable.Translations["TAA"] != '*' {
+		t.Errorf("codon table 11 has the wrong translations for ATG or TAA")
+	}
+	if !bacterialTable.StartCodons["GTG"] || !bacterialTable.StartCodons["TTG"] {
+		t.Errorf("codon table 11 should allow GTG and TTG start codons")
+	}
+
+	if _, err := getCodonTable(7); err == nil {
+		t.Errorf("getCodonTable() should return an error for a table that doesn't exist")
+	}
+}
+
+func TestBackTranslate(t *testing.T) {
+	// M has one codon, F is TTY, L is CTN and TTR, S is TCN and AGY, and stops are TAR and TGA.
+	degenerateSequence, err := BackTranslate("mFLS*X", 1)
+	if err != nil {
+		t.Fatalf("BackTranslate() returned an unexpected error: %s", err)
+	}
+	if degenerateSequence != "ATGTTYYTNWSNTRRNNN" {
+		t.Errorf("BackTranslate() expected ATGTTYYTNWSNTRRNNN. Got %s", degenerateSequence)
+	}
+
+	if _, err := BackTranslate("M", 99); err == nil {
+		t.Errorf("BackTranslate() should return an error for an unknown table")
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	protein, err := Translate("atgTTTcgNugaAA", 1)
+	if err != nil {
+		t.Fatalf("Translate() returned an unexpected error: %s", err)
+	}
+	if protein != "MFX*" {
+		t.Errorf("Translate() expected MFX*. Got %s", protein)
+	}
+}
+
+func TestTranslateCDS(t *testing.T) {
+	for _, test := range []struct {
+		sequence string
+		table    int
+		expected string
+	}{
+		{"GTGTTGGTGTAA", 11, "MLV*"},
+		{"TTGGTGTTGTAA", 11, "MVL*"},
+		{"GTGTTGTAA", 1, "VL*"},
+		{"gtgTTGTAA", 1, "VL*"},
+	} {
+		protein, err := TranslateCDS(test.sequence, test.table)
+		if err != nil {
+			t.Fatalf("TranslateCDS() returned an unexpected error: %s", err)
+		}
+		if protein != test.expected {
+			t.Errorf("TranslateCDS(%s, %d) expected %s. Got %s", test.sequence, test.table, test.expected, protein)
+		}
+	}
+
+	annotatedSequence := AnnotatedSequence{Sequence: Sequence{Sequence: "GTGTTGTAATTACAACAC"}}
+	for _, test := range []struct {
+		location string
+		expected string
+	}{
+		{"1..9", "ML*"},
+		{"<1..9", "VL*"},
+		{"complement(10..18)", "ML*"},
+		{"complement(10..>18)", "VL*"},
+	} {
+		protein, err := annotatedSequence.TranslateFeature(Feature{Type: "CDS", Location: test.location}, 11)
+		if err != nil {
+			t.Fatalf("TranslateFeature() returned an unexpected error: %s", err)
+		}
+		if protein != test.expected {
+			t.Errorf("TranslateFeature() of %s expected %s. Got %s", test.location, test.expected, protein)
+		}
+	}
+}
+
+func TestApplyVariants(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Meta:     Meta{Locus: Locus{SequenceLength: "16 bp"}},
+		Sequence: Sequence{Sequence: "AAAACCCCGGGGTTTT"},
+		Features: []Feature{
+			{Type: "gene", Start: 1, End: 4, Strand: "+"},
+			{Type: "gene", Location: "13..16"},
+			{Type: "CDS", Location: "complement(join(5..7,9..11))"},
+		},
+	}
+	variants := []Variant{
+		{Position: 10, Ref: "GGG", Alt: "G"},
+		{Position: 3, Ref: "A", Alt: "G"},
+		{Position: 6, Ref: "C", Alt: "CTT"},
+	}
+
+	mutant, err := ApplyVariants(annotatedSequence, variants)
+	if err != nil {
+		t.Fatalf("ApplyVariants() returned an unexpected error: %s", err)
+	}
+	if mutant.Sequence.Sequence != "AAGACCTTCCGGTTTT" {
+		t.Errorf("ApplyVariants() expected AAGACCTTCCGGTTTT. Got %s", mutant.Sequence.Sequence)
+	}
+	if mutant.Features[0].Start != 1 || mutant.Features[0].End != 4 {
+		t.Errorf("ApplyVariants() moved a feature before every indel. Got %d..%d", mutant.Features[0].Start, mutant.Features[0].End)
+	}
+	if mutant.Features[1].Location != "13..16" {
+		t.Errorf("ApplyVariants() expected 13..16. Got %s", mutant.Features[1].Location)
+	}
+	if mutant.Features[2].Location != "complement(join(5..9,11..12))" {
+		t.Errorf("ApplyVariants() expected complement(join(5..9,11..12)). Got %s", mutant.Features[2].Location)
+	}
+	if tttt, _ := mutant.FeatureSequence(mutant.Features[1]); tttt != "TTTT" {
+		t.Errorf("ApplyVariants() shifted gene no longer covers TTTT. Got %s", tttt)
+	}
+	if annotatedSequence.Features[1].Location != "13..16" {
+		t.Errorf("ApplyVariants() mutated its input")
+	}
+
+	if _, err := ApplyVariants(annotatedSequence, []Variant{{Position: 1, Ref: "C", Alt: "A"}}); err == nil {
+		t.Errorf("ApplyVariants() should return an error when ref doesn't match the sequence")
+	}
+	if _, err := ApplyVariants(annotatedSequence, []Variant{{Position: 5, Ref: "CC", Alt: "C"}, {Position: 6, Ref: "C", Alt: "G"}}); err == nil {
+		t.Errorf("ApplyVariants() should return an error for overlapping variants")
+	}
+
+	// locations that don't move, or are on another record, are kept exactly as written.
+	annotatedSequence.Features = []Feature{
+		{Type: "misc_feature", Location: "order(1..2,3..4)"},
+		{Type: "misc_feature", Location: "J00194.1:100..202"},
+		{Type: "misc_feature", Location: "order(1..2,13..14)"},
+		{Type: "misc_feature", Location: "complement(join(J00194.1:1..10,J00195.1:1..10))"},
+	}
+	mutant, err = ApplyVariants(annotatedSequence, []Variant{{Position: 6, Ref: "C", Alt: "CTT"}})
+	if err != nil {
+		t.Fatalf("ApplyVariants() returned an unexpected error for remote locations: %s", err)
+	}
+	for featureIndex, expected := range []string{"order(1..2,3..4)", "J00194.1:100..202", "order(1..2,15..16)", "complement(join(J00194.1:1..10,J00195.1:1..10))"} {
+		if mutant.Features[featureIndex].Location != expected {
+			t.Errorf("ApplyVariants() expected location %s. Got %s", expected, mutant.Features[featureIndex].Location)
+		}
+	}
+
+	for _, location := range []string{"join(1..2,J00194.1:100..202)", "1..two"} {
+		annotatedSequence.Features = []Feature{{Type: "misc_feature", Location: location}}
+		if _, err := ApplyVariants(annotatedSequence, []Variant{{Position: 6, Ref: "C", Alt: "CTT"}}); err == nil || !strings.Contains(err.Error(), location) {
+			t.Errorf("ApplyVariants() should return an error naming a feature at %s, which can't be shifted. Got %v", location, err)
+		}
+	}
+}
+
+func TestApplyPhasedVariants(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{Sequence: Sequence{Sequence: "AAAACCCCGGGGTTTT"}}
+	variants := []Variant{
+		{Position: 2, Ref: "A", Alt: "T"},                 // homozygous.
+		{Position: 6, Ref: "C", Alt: "G", Haplotype: 1},   // 1|0.
+		{Position: 6, Ref: "CC", Alt: "C", Haplotype: 2},  // 0|1, overlapping the other allele.
+		{Position: 16, Ref: "T", Alt: "TA", Haplotype: 2}, // 0|1.
+	}
+	haplotypes, err := ApplyPhasedVariants(annotatedSequence, variants, 2)
+	if err != nil {
+		t.Fatalf("ApplyPhasedVariants() returned an unexpected error: %s", err)
+	}
+	var got []string
+	for _, haplotype := range haplotypes {
+		got = append(got, haplotype.Sequence.Sequence)
+	}
+	if diff := cmp.Diff([]string{"ATAACGCCGGGGTTTT", "ATAACCCGGGGTTTTA"}, got); diff != "" {
+		t.Errorf("ApplyPhasedVariants() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := ApplyPhasedVariants(annotatedSequence, []Variant{{Position: 1, Ref: "A", Alt: "T", Haplotype: 3}}, 2); err == nil {
+		t.Errorf("ApplyPhasedVariants() should return an error for a haplotype past the ploidy")
+	}
+	overlapping := []Variant{{Position: 6, Ref: "C", Alt: "G", Haplotype: 1}, {Position: 6, Ref: "CC", Alt: "C"}}
+	if _, err := ApplyPhasedVariants(annotatedSequence, overlapping, 2); err == nil {
+		t.Errorf("ApplyPhasedVariants() should return an error for overlapping variants on one haplotype")
+	}
+}
+
+func TestTrimNs(t *testing.T) {
+	trimmed, leading, trailing := TrimNs("NNnACGTNACGTnN")
+	if trimmed != "ACGTNACGT" || leading != 3 || trailing != 2 {
+		t.Errorf("TrimNs() expected ACGTNACGT, 3, 2. Got %s, %d, %d", trimmed, leading, trailing)
+	}
+	if trimmed, leading, trailing := TrimNs("NNNN"); trimmed != "" || leading != 4 || trailing != 0 {
+		t.Errorf("TrimNs() on all Ns expected \"\", 4, 0. Got %q, %d, %d", trimmed, leading, trailing)
+	}
+
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: "NNNATGAAATAANN"},
+		Features: []Feature{
+			{Type: "CDS", Location: "complement(4..12)"},
+			{Type: "gap", Start: 1, End: 3, Strand: "+"},
+			{Type: "misc_feature", Location: "join(2..5,11..14)"},
+			{Type: "misc_feature", Location: "order(4..6,8^9)"},
+		},
+	}
+	trimmedSequence, dropped, err := annotatedSequence.TrimNs()
+	if err != nil {
+		t.Fatalf("TrimNs() returned an unexpected error: %s", err)
+	}
+	if trimmedSequence.Sequence.Sequence != "ATGAAATAA" {
+		t.Errorf("TrimNs() expected ATGAAATAA. Got %s", trimmedSequence.Sequence.Sequence)
+	}
+	if trimmedSequence.Features[0].Location != "complement(1..9)" {
+		t.Errorf("TrimNs() expected complement(1..9). Got %s", trimmedSequence.Features[0].Location)
+	}
+	if len(dropped) != 1 || dropped[0].Type != "gap" {
+		t.Errorf("TrimNs() expected the gap inside the trimmed Ns to be dropped. Got %v", dropped)
+	}
+	if len(trimmedSequence.Features) != 3 {
+		t.Fatalf("TrimNs() expected 3 features. Got %d", len(trimmedSequence.Features))
+	}
+	if trimmedSequence.Features[1].Location != "join(<1..2,8..>9)" {
+		t.Errorf("TrimNs() expected join(<1..2,8..>9). Got %s", trimmedSequence.Features[1].Location)
+	}
+	if trimmedSequence.Features[2].Location != "order(1..3,5^6)" {
+		t.Errorf("TrimNs() expected order(1..3,5^6). Got %s", trimmedSequence.Features[2].Location)
+	}
+
+	withQuality := AnnotatedSequence{Sequence: Sequence{Sequence: "NNATGN", Quality: []int{0, 1, 2, 3, 4, 5}}}
+	if trimmedQuality, _, _ := withQuality.TrimNs(); len(trimmedQuality.Sequence.Quality) != 3 || trimmedQuality.Sequence.Quality[0] != 2 {
+		t.Errorf("TrimNs() expected quality [2 3 4]. Got %v", trimmedQuality.Sequence.Quality)
+	}
+	withQuality.Sequence.Quality = withQuality.Sequence.Quality[:4]
+	if trimmedQuality, _, _ := withQuality.TrimNs(); trimmedQuality.Sequence.Quality != nil {
+		t.Errorf("TrimNs() should drop quality that doesn't have a score for every base. Got %v", trimmedQuality.Sequence.Quality)
+	}
+
+	gbk := "LOCUS       trace                      8 bp    DNA     linear   SYN 01-JAN-2020\n" +
+		"FEATURES             Location/Qualifiers\n" +
+		"     source          1..8\n" +
+		"                     /phred=\"0 0 20 30 40 40 40 0\"\n" +
+		"ORIGIN\n" +
+		"        1 nnatgcan\n" +
+		"//\n"
+	parsed := ParseGbk(gbk)
+	if _, ok := parsed.Features[0].Attributes["phred"]; ok {
+		t.Errorf("ParseGbk() should move /phred into Sequence.Quality rather than keep a second copy on the source feature")
+	}
+	trimmedTrace, _, err := parsed.TrimNs()
+	if err != nil {
+		t.Fatalf("TrimNs() returned an unexpected error: %s", err)
+	}
+	if diff := cmp.Diff([]int{20, 30, 40, 40, 40}, ParseGbk(string(BuildGbk(trimmedTrace))).Sequence.Quality); diff != "" {
+		t.Errorf("quality did not survive TrimNs() and a genbank round trip (-want +got):\n%s", diff)
+	}
+}
+
+func TestRename(t *testing.T) {
+	annotatedSequence, _ := ParseGff("##gff-version 3\n##sequence-region chr1 1 8\n" +
+		"chr1\tfeature\tgene\t1\t8\t.\t+\t.\tID=gene1\n" +
+		"chr2\tfeature\tgene\t1\t8\t.\t+\t.\tID=gene2\n" +
+		"##FASTA\n>chr1 test sequence\nATGCATGC\n")
+	annotatedSequence.Rename("plasmid")
+
+	if annotatedSequence.Meta.Name != "plasmid" || annotatedSequence.Meta.Locus.Name != "plasmid" {
+		t.Errorf("Rename() did not update the meta names. Got %q and %q", annotatedSequence.Meta.Name, annotatedSequence.Meta.Locus.Name)
+	}
+	if annotatedSequence.Features[0].Name != "plasmid" || annotatedSequence.Features[1].Name != "chr2" {
+		t.Errorf("Rename() should only rename features on the old name. Got %q and %q", annotatedSequence.Features[0].Name, annotatedSequence.Features[1].Name)
+	}
+	if annotatedSequence.Sequence.Description != ">plasmid test sequence" {
+		t.Errorf("Rename() expected description >plasmid test sequence. Got %q", annotatedSequence.Sequence.Description)
+	}
+
+	gff := string(BuildGff(annotatedSequence))
+	if !strings.Contains(gff, "##sequence-region plasmid 1 8\nplasmid\tfeature\tgene") || !strings.Contains(gff, "##FASTA\n>plasmid\n") {
+		t.Errorf("BuildGff() after Rename() still uses the old name:\n%s", gff)
+	}
+}
+
+func TestClampFeatures(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Meta:     Meta{RegionStart: 1, RegionEnd: 100},
+		Sequence: Sequence{Sequence: strings.Repeat("A", 100)},
+		Features: []Feature{
+			{Type: "gene", Start: 10, End: 50, Strand: "+"},
+			{Type: "gene", Start: 90, End: 120, Strand: "+"},
+			{Type: "gene", Start: 110, End: 120, Strand: "+"},
+			{Type: "CDS", Location: "join(80..95,98..130)"},
+		},
+	}
+
+	clamped, dropped := annotatedSequence.ClampFeatures(false)
+	if len(clamped.Features) != 3 || len(dropped) != 1 || dropped[0].Start != 110 {
+		t.Fatalf("ClampFeatures(false) should only drop the feature entirely outside the region. Kept %v, dropped %v", clamped.Features, dropped)
+	}
+	if clamped.Features[1].End != 100 || clamped.Features[2].Location != "join(80..95,98..100)" {
+		t.Errorf("ClampFeatures(false) did not clamp to the region. Got %v", clamped.Features)
+	}
+	if annotatedSequence.Features[1].End != 120 {
+		t.Errorf("ClampFeatures() should not modify the features of the AnnotatedSequence")
+	}
+
+	kept, dropped := annotatedSequence.ClampFeatures(true)
+	if len(kept.Features) != 1 || len(dropped) != 3 {
+		t.Errorf("ClampFeatures(true) should drop every feature sticking out of the region. Kept %v, dropped %v", kept.Features, dropped)
+	}
+
+	// a region of a larger contig whose sequence starts at the region's start.
+	region := AnnotatedSequence{
+		Meta:     Meta{RegionStart: 1000},
+		Sequence: Sequence{Sequence: strings.Repeat("A", 500)},
+		Features: []Feature{
+			{Type: "gene", Start: 1100, End: 1200, Strand: "+"},
+			{Type: "gene", Start: 1450, End: 1600, Strand: "+"},
+		},
+	}
+	kept, dropped = region.ClampFeatures(true)
+	if len(kept.Features) != 1 || kept.Features[0].Start != 1100 || len(dropped) != 1 || dropped[0].Start != 1450 {
+		t.Errorf("ClampFeatures(true) should bound a region's features by the coordinates its sequence covers. Kept %v, dropped %v", kept.Features, dropped)
+	}
+	if clamped, _ := region.ClampFeatures(false); clamped.Features[1].End != 1499 {
+		t.Errorf("ClampFeatures(false) expected the region's gene to be clamped to 1499. Got %v", clamped.Features[1])
+	}
+}
+
+func TestLinearizeFeatures(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Meta:     Meta{Locus: Locus{Circular: true}},
+		Sequence: Sequence{Sequence: strings.Repeat("A", 100)},
+		Features: []Feature{
+			{Type: "CDS", Location: "join(90..100,1..20)", Attributes: map[string]string{"locus_tag": "b0001"}},
+			{Type: "CDS", Location: "complement(join(90..100,1..20))", Attributes: map[string]string{"ID": "cds2"}},
+			{Type: "CDS", Location: "join(complement(1..20),complement(90..100))", Attributes: map[string]string{"ID": "cds3"}},
+			{Type: "gene", Location: "95..110"},
+			{Type: "gene", Start: 95, End: 5, Strand: "+", Attributes: map[string]string{"ID": "gene5"}},
+			{Type: "gene", Location: "join(10..20,30..40)"},                         // doesn't cross the origin.
+			{Type: "gene", Location: "join(complement(30..40),complement(10..20))"}, // nor does this.
+			{Type: "gene", Start: 95, End: UndefinedCoordinate, Strand: "+", Attributes: map[string]string{"ID": "gene8"}},
+		},
+	}
+
+	var got []string
+	for _, feature := range annotatedSequence.LinearizeFeatures() {
+		location := feature.Location
+		if location == "" {
+			location = strconv.Itoa(feature.Start) + ".." + strconv.Itoa(feature.End)
+		}
+		got = append(got, location+" "+feature.Attributes["ID"])
+	}
+	expected := []string{
+		"90..100 b0001", "1..20 b0001",
+		"complement(90..100) cds2", "complement(1..20) cds2",
+		"complement(1..20) cds3", "complement(90..100) cds3",
+		"95..100 ", "1..10 ",
+		"95..100 gene5", "1..5 gene5",
+		"join(10..20,30..40) ",
+		"join(complement(30..40),complement(10..20)) ",
+		"95..-1 gene8",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("LinearizeFeatures() expected %v. Got %v", expected, got)
+	}
+	if _, ok := annotatedSequence.Features[0].Attributes["ID"]; ok {
+		t.Errorf("LinearizeFeatures() should not modify the features of the AnnotatedSequence")
+	}
+	// the pieces of a genbank feature get their own spans for gff output rather than keeping the whole feature's.
+	for pieceIndex, piece := range annotatedSequence.LinearizeFeatures()[2:4] {
+		expectedSpan := [][2]int{{90, 100}, {1, 20}}[pieceIndex]
+		if piece.Start != expectedSpan[0] || piece.End != expectedSpan[1] || piece.Strand != "-" {
+			t.Errorf("LinearizeFeatures() piece %d of cds2 has span %d..%d strand %q, expected %d..%d strand \"-\"", pieceIndex, piece.Start, piece.End, piece.Strand, expectedSpan[0], expectedSpan[1])
+		}
+	}
+
+	annotatedSequence.Meta.Locus.Circular = false
+	if features := annotatedSequence.LinearizeFeatures(); len(features) != len(annotatedSequence.Features) {
+		t.Errorf("LinearizeFeatures() should not split features of a linear sequence")
+	}
+}
+
+func TestTransformsClearSeguid(t *testing.T) {
+	annotatedSequence := AnnotatedSequence{
+		Sequence: Sequence{Sequence: "NNATGAAATAANN", Seguid: Seguid("NNATGAAATAANN")},
+		Features: []Feature{{Type: "CDS", Location: "3..11"}},
+	}
+	mutant, err := ApplyVariants(annotatedSequence, []Variant{{Position: 6, Ref: "A", Alt: "G"}})
+	if err != nil || mutant.Sequence.Seguid != "" {
+		t.Errorf("ApplyVariants() kept a stale Seguid %q, %v", mutant.Sequence.Seguid, err)
+	}
+	trimmed, _, err := annotatedSequence.TrimNs()
+	if err != nil || trimmed.Sequence.Seguid != "" {
+		t.Errorf("TrimNs() kept a stale Seguid %q, %v", trimmed.Sequence.Seguid, err)
+	}
+	context, err := annotatedSequence.ExtractWithContext(annotatedSequence.Features[0], 1)
+	if err != nil || context.Sequence.Seguid != "" {
+		t.Errorf("ExtractWithContext() kept a stale Seguid %q, %v", context.Sequence.Seguid, err)
+	}
+	if annotatedSequence.Sequence.Seguid == "" {
+		t.Errorf("transforms cleared the Seguid of their input")
+	}
+}